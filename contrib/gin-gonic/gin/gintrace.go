@@ -7,7 +7,6 @@
 package gin // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/gin-gonic/gin"
 
 import (
-	"fmt"
 	"math"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
@@ -48,9 +47,18 @@ func Middleware(service string, opts ...Option) gin.HandlerFunc {
 			opts = append(opts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
 		}
 		opts = append(opts, tracer.Tag(ext.HTTPRoute, c.FullPath()))
+		if cfg.headerTags != nil {
+			opts = append(opts, httptrace.HeaderTagsFromRequest(c.Request, cfg.headerTags))
+		}
 
 		span, ctx := httptrace.StartRequestSpan(c.Request, opts...)
 		defer func() {
+			httptrace.SetResponseHeaderTags(span, c.Writer.Header(), cfg.headerTags)
+			if r := recover(); r != nil {
+				// record the panic and leave its recovery to the middlewares above
+				httptrace.FinishRequestSpan(span, c.Writer.Status(), tracer.WithPanic(r))
+				panic(r)
+			}
 			httptrace.FinishRequestSpan(span, c.Writer.Status())
 		}()
 
@@ -78,8 +86,7 @@ func HTML(c *gin.Context, code int, name string, obj interface{}) {
 	span.SetTag(ext.Component, componentName)
 	defer func() {
 		if r := recover(); r != nil {
-			err := fmt.Errorf("error rendering tmpl:%s: %s", name, r)
-			span.Finish(tracer.WithError(err))
+			span.Finish(tracer.WithPanic(r))
 			panic(r)
 		} else {
 			span.Finish()
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -236,6 +237,58 @@ func TestError(t *testing.T) {
 	})
 }
 
+func TestPanic(t *testing.T) {
+	assert := assert.New(t)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// setup
+	router := gin.New()
+	router.Use(gin.RecoveryWithWriter(io.Discard), Middleware("foobar"))
+	panicErr := errors.New("oh no")
+
+	t.Run("handler", func(*testing.T) {
+		defer mt.Reset()
+
+		router.GET("/panic", func(c *gin.Context) {
+			panic(panicErr)
+		})
+		r := httptest.NewRequest("GET", "/panic", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(500, w.Result().StatusCode)
+
+		spans := mt.FinishedSpans()
+		assert.Len(spans, 1)
+		if len(spans) < 1 {
+			t.Fatalf("no spans")
+		}
+		// the span records the panic value itself
+		err, _ := spans[0].Tag(ext.Error).(error)
+		assert.ErrorIs(err, panicErr)
+	})
+
+	t.Run("template", func(*testing.T) {
+		defer mt.Reset()
+
+		router.SetHTMLTemplate(template.Must(template.New("hello").Parse("hello {{.Name}}")))
+		router.GET("/template", func(c *gin.Context) {
+			// gin panics with the template execution error
+			HTML(c, 200, "hello", 42)
+		})
+		r := httptest.NewRequest("GET", "/template", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		spans := mt.FinishedSpans()
+		assert.Len(spans, 2)
+		for _, s := range spans {
+			err, _ := s.Tag(ext.Error).(error)
+			assert.ErrorContains(err, "can't evaluate field Name", s.OperationName())
+		}
+	})
+}
+
 func TestHTML(t *testing.T) {
 	assert := assert.New(t)
 	mt := mocktracer.Start()
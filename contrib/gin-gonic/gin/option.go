@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
@@ -23,6 +24,7 @@ type config struct {
 	resourceNamer func(c *gin.Context) string
 	serviceName   string
 	ignoreRequest func(c *gin.Context) bool
+	headerTags    httptrace.HeaderTags
 }
 
 func newConfig(serviceName string) *config {
@@ -83,6 +85,16 @@ func WithIgnoreRequest(f func(c *gin.Context) bool) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 func defaultResourceNamer(c *gin.Context) string {
 	// getName is a hacky way to check whether *gin.Context implements the FullPath()
 	// method introduced in v1.4.0, falling back to the previous implementation otherwise.
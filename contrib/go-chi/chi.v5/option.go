@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
@@ -24,6 +25,7 @@ type config struct {
 	isStatusError      func(statusCode int) bool
 	ignoreRequest      func(r *http.Request) bool
 	modifyResourceName func(resourceName string) string
+	headerTags         httptrace.HeaderTags
 }
 
 // Option represents an option that can be passed to NewRouter.
@@ -87,6 +89,16 @@ func WithStatusCheck(fn func(statusCode int) bool) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 func isServerError(statusCode int) bool {
 	return statusCode >= 500 && statusCode < 600
 }
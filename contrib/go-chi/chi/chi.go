@@ -49,9 +49,14 @@ func Middleware(opts ...Option) func(next http.Handler) http.Handler {
 			if !math.IsNaN(cfg.analyticsRate) {
 				opts = append(opts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
 			}
+			if cfg.headerTags != nil {
+				// opts may share its backing array with other requests
+				opts = append(opts[:len(opts):len(opts)], httptrace.HeaderTagsFromRequest(r, cfg.headerTags))
+			}
 			span, ctx := httptrace.StartRequestSpan(r, opts...)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			defer func() {
+				httptrace.SetResponseHeaderTags(span, ww.Header(), cfg.headerTags)
 				status := ww.Status()
 				var opts []tracer.FinishOption
 				if cfg.isStatusError(status) {
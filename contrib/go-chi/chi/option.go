@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
@@ -23,6 +24,7 @@ type config struct {
 	analyticsRate float64
 	isStatusError func(statusCode int) bool
 	ignoreRequest func(r *http.Request) bool
+	headerTags    httptrace.HeaderTags
 }
 
 // Option represents an option that can be passed to NewRouter.
@@ -85,6 +87,16 @@ func WithStatusCheck(fn func(statusCode int) bool) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 func isServerError(statusCode int) bool {
 	return statusCode >= 500 && statusCode < 600
 }
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -19,6 +20,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 const componentName = "gofiber/fiber.v2"
@@ -54,6 +56,9 @@ func Middleware(opts ...Option) func(c *fiber.Ctx) error {
 		if spanctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(h)); err == nil {
 			opts = append(opts, tracer.ChildOf(spanctx))
 		}
+		httptrace.RequestHeaderTags(cfg.headerTags, h.Get, func(tag, value string) {
+			opts = append(opts, tracer.Tag(tag, utils.CopyString(value)))
+		})
 		opts = append(opts, cfg.spanOpts...)
 		opts = append(opts, tracer.Tag(ext.Component, componentName))
 		opts = append(opts, tracer.Tag(ext.SpanKind, ext.SpanKindServer))
@@ -77,6 +82,9 @@ func Middleware(opts ...Option) func(c *fiber.Ctx) error {
 			status = http.StatusOK
 		}
 		span.SetTag(ext.HTTPCode, strconv.Itoa(status))
+		httptrace.ResponseHeaderTags(cfg.headerTags, func(header string) string { return c.GetRespHeader(header) }, func(tag, value string) {
+			span.SetTag(tag, utils.CopyString(value))
+		})
 
 		if err != nil {
 			span.SetTag(ext.Error, err)
@@ -8,6 +8,7 @@ package fiber
 import (
 	"math"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
@@ -25,6 +26,7 @@ type config struct {
 	spanOpts      []ddtrace.StartSpanOption // additional span options to be applied
 	analyticsRate float64
 	resourceNamer func(*fiber.Ctx) string
+	headerTags    httptrace.HeaderTags
 }
 
 // Option represents an option that can be passed to NewRouter.
@@ -97,6 +99,16 @@ func WithResourceNamer(fn func(*fiber.Ctx) string) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 func defaultResourceNamer(c *fiber.Ctx) string {
 	r := c.Route()
 	return r.Method + " " + r.Path
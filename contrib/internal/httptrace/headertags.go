@@ -0,0 +1,134 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httptrace
+
+import (
+	"net/http"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/normalizer"
+)
+
+// HeaderTags maps lower-cased HTTP header names to the names of the span tags
+// holding their values. An empty tag name means the default tag name of the header,
+// http.request.headers.<header> or http.response.headers.<header>, is used.
+//
+// A nil HeaderTags stands for the headers configured globally through the
+// tracer.WithHeaderTags option or the DD_TRACE_HEADER_TAGS env var, which allows
+// integrations to only set their own HeaderTags when overridden by the user.
+type HeaderTags map[string]string
+
+// NewHeaderTags returns the HeaderTags of the given headers, each being either a header
+// name or of the form "header:tag". The returned value is never nil, so that an empty
+// list of headers disables the global headers.
+func NewHeaderTags(headerAsTags []string) HeaderTags {
+	ht := make(HeaderTags, len(headerAsTags))
+	for _, h := range headerAsTags {
+		header, tag := normalizer.HeaderTag(h)
+		if header == "" {
+			continue
+		}
+		ht[header] = tag
+	}
+	return ht
+}
+
+// forEach calls fn for each header of ht, or for each global header if ht is nil.
+func (ht HeaderTags) forEach(fn func(header, tag string)) {
+	if ht == nil {
+		globalconfig.ForEachHeaderTag(fn)
+		return
+	}
+	for header, tag := range ht {
+		fn(header, tag)
+	}
+}
+
+// len returns the number of headers of ht.
+func (ht HeaderTags) len() int {
+	if ht == nil {
+		return globalconfig.HeaderTagsLen()
+	}
+	return len(ht)
+}
+
+// tags calls fn with the tag name and value of each header of ht which get returns a
+// non-empty value for. prefix is the prefix of the default tag names.
+func (ht HeaderTags) tags(prefix string, get func(header string) string, fn func(tag, value string)) {
+	if ht.len() == 0 {
+		return
+	}
+	ht.forEach(func(header, tag string) {
+		v := get(header)
+		if v == "" {
+			return
+		}
+		if tag == "" {
+			tag = normalizer.HeaderTagName(prefix, header)
+		}
+		fn(tag, v)
+	})
+}
+
+// RequestHeaderTags calls fn with the tag name and value of each request header of ht,
+// using get to retrieve the value of a header. It is meant for integrations which do
+// not rely on net/http.
+func RequestHeaderTags(ht HeaderTags, get func(header string) string, fn func(tag, value string)) {
+	ht.tags(ext.HTTPRequestHeaders, get, fn)
+}
+
+// ResponseHeaderTags calls fn with the tag name and value of each response header of ht,
+// using get to retrieve the value of a header. It is meant for integrations which do
+// not rely on net/http.
+func ResponseHeaderTags(ht HeaderTags, get func(header string) string, fn func(tag, value string)) {
+	ht.tags(ext.HTTPResponseHeaders, get, fn)
+}
+
+// headerValue returns the comma-separated values of the given header of h.
+func headerValue(h http.Header) func(string) string {
+	return func(header string) string {
+		return strings.Join(h.Values(header), ",")
+	}
+}
+
+// HeaderTagsFromRequest returns a span start option which records the headers of ht
+// found in the request r. Given to StartRequestSpan, it replaces the global headers,
+// which are otherwise recorded by default.
+func HeaderTagsFromRequest(r *http.Request, ht HeaderTags) ddtrace.StartSpanOption {
+	return func(cfg *ddtrace.StartSpanConfig) {
+		if cfg.Tags == nil {
+			cfg.Tags = make(map[string]interface{})
+		}
+		if ht != nil {
+			// remove the tags set from the global headers by StartRequestSpan
+			HeaderTags(nil).tags(ext.HTTPRequestHeaders, headerValue(r.Header), func(tag, _ string) {
+				delete(cfg.Tags, tag)
+			})
+		}
+		RequestHeaderTags(ht, headerValue(r.Header), func(tag, value string) {
+			cfg.Tags[tag] = value
+		})
+	}
+}
+
+// SetResponseHeaderTags records the headers of ht found in the response headers h
+// on the span s.
+func SetResponseHeaderTags(s ddtrace.Span, h http.Header, ht HeaderTags) {
+	ResponseHeaderTags(ht, headerValue(h), func(tag, value string) {
+		s.SetTag(tag, value)
+	})
+}
+
+// SetRequestHeaderTags records the headers of ht found in the request headers h
+// on the span s. It is meant for client integrations.
+func SetRequestHeaderTags(s ddtrace.Span, h http.Header, ht HeaderTags) {
+	RequestHeaderTags(ht, headerValue(h), func(tag, value string) {
+		s.SetTag(tag, value)
+	})
+}
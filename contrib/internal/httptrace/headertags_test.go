@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package httptrace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+func TestHeaderTags(t *testing.T) {
+	globalconfig.SetHeaderTag("x-request-id", "req.id")
+	globalconfig.SetHeaderTag("x-session", "")
+	globalconfig.SetHeaderTag("content-type", "")
+	defer globalconfig.ClearHeaderTags()
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/somePath", nil)
+		r.Header.Set("X-Request-Id", "abc")
+		r.Header.Add("X-Session", "s1")
+		r.Header.Add("X-Session", "s2")
+		r.Header.Set("X-Other", "other")
+		return r
+	}
+	respHeader := http.Header{"Content-Type": {"text/plain"}, "X-Request-Id": {"def"}}
+
+	t.Run("global", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		s, _ := StartRequestSpan(newRequest())
+		SetResponseHeaderTags(s, respHeader, nil)
+		FinishRequestSpan(s, http.StatusOK)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		tags := spans[0].Tags()
+		assert.Equal(t, "def", tags["req.id"])
+		assert.Equal(t, "s1,s2", tags["http.request.headers.x-session"])
+		assert.Equal(t, "text/plain", tags["http.response.headers.content-type"])
+		assert.NotContains(t, tags, "http.request.headers.x-other")
+		assert.NotContains(t, tags, "http.request.headers.content-type")
+	})
+
+	t.Run("override", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		r := newRequest()
+		ht := NewHeaderTags([]string{"X-Other", "X-Request-Id:request"})
+		s, _ := StartRequestSpan(r, HeaderTagsFromRequest(r, ht))
+		SetResponseHeaderTags(s, respHeader, ht)
+		FinishRequestSpan(s, http.StatusOK)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		tags := spans[0].Tags()
+		assert.Equal(t, "other", tags["http.request.headers.x-other"])
+		assert.Equal(t, "def", tags["request"])
+		assert.NotContains(t, tags, "req.id")
+		assert.NotContains(t, tags, "http.request.headers.x-session")
+		assert.NotContains(t, tags, "http.response.headers.content-type")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		r := newRequest()
+		ht := NewHeaderTags(nil)
+		s, _ := StartRequestSpan(r, HeaderTagsFromRequest(r, ht))
+		SetResponseHeaderTags(s, respHeader, ht)
+		FinishRequestSpan(s, http.StatusOK)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		for k := range spans[0].Tags() {
+			assert.NotContains(t, k, "headers")
+			assert.NotEqual(t, "req.id", k)
+		}
+	})
+}
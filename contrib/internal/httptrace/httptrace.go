@@ -38,7 +38,8 @@ func InitServerSpanName() {
 }
 
 // StartRequestSpan starts an HTTP request span with the standard list of HTTP request span tags (http.method, http.url,
-// http.useragent). Any further span start option can be added with opts.
+// http.useragent) and the request headers configured globally through tracer.WithHeaderTags. The recorded headers can be
+// overridden by giving a HeaderTagsFromRequest option. Any further span start option can be added with opts.
 func StartRequestSpan(r *http.Request, opts ...ddtrace.StartSpanOption) (tracer.Span, context.Context) {
 	// Append our span options before the given ones so that the caller can "overwrite" them.
 	// TODO(): rework span start option handling (https://github.com/DataDog/dd-trace-go/issues/1352)
@@ -48,6 +49,7 @@ func StartRequestSpan(r *http.Request, opts ...ddtrace.StartSpanOption) (tracer.
 		tracer.Tag(ext.HTTPURL, urlFromRequest(r)),
 		tracer.Tag(ext.HTTPUserAgent, r.UserAgent()),
 		tracer.Measured(),
+		HeaderTagsFromRequest(r, nil),
 	}, opts...)
 	if r.Host != "" {
 		opts = append([]ddtrace.StartSpanOption{
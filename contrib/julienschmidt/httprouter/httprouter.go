@@ -61,9 +61,10 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	resource := req.Method + " " + route
 
 	httptrace.TraceAndServe(r.Router, w, req, &httptrace.ServeConfig{
-		Service:  r.config.serviceName,
-		Resource: resource,
-		SpanOpts: r.config.spanOpts,
-		Route:    route,
+		Service:    r.config.serviceName,
+		Resource:   resource,
+		SpanOpts:   r.config.spanOpts,
+		Route:      route,
+		HeaderTags: r.config.headerTags,
 	})
 }
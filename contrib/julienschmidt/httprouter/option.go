@@ -8,6 +8,7 @@ package httprouter
 import (
 	"math"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
@@ -20,6 +21,7 @@ type routerConfig struct {
 	serviceName   string
 	spanOpts      []ddtrace.StartSpanOption
 	analyticsRate float64
+	headerTags    httptrace.HeaderTags
 }
 
 // RouterOption represents an option that can be passed to New.
@@ -70,3 +72,13 @@ func WithAnalyticsRate(rate float64) RouterOption {
 		}
 	}
 }
+
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
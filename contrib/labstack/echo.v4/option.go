@@ -8,6 +8,7 @@ package echo
 import (
 	"math"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 
@@ -22,6 +23,7 @@ type config struct {
 	noDebugStack      bool
 	ignoreRequestFunc IgnoreRequestFunc
 	isStatusError     func(statusCode int) bool
+	headerTags        httptrace.HeaderTags
 }
 
 // Option represents an option that can be passed to Middleware.
@@ -94,6 +96,16 @@ func WithStatusCheck(fn func(statusCode int) bool) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 func isServerError(statusCode int) bool {
 	return statusCode >= 500 && statusCode < 600
 }
@@ -57,9 +57,13 @@ func Middleware(opts ...Option) echo.MiddlewareFunc {
 				finishOpts = []tracer.FinishOption{tracer.NoDebugStack()}
 			}
 
+			if cfg.headerTags != nil {
+				opts = append(opts, httptrace.HeaderTagsFromRequest(request, cfg.headerTags))
+			}
 			span, ctx := httptrace.StartRequestSpan(request, opts...)
 			defer func() {
 				//httptrace.FinishRequestSpan(span, c.Response().Status, finishOpts...)
+				httptrace.SetResponseHeaderTags(span, c.Response().Header(), cfg.headerTags)
 				span.Finish(finishOpts...)
 			}()
 
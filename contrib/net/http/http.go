@@ -54,10 +54,11 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	TraceAndServe(mux.ServeMux, w, r, &ServeConfig{
-		Service:  mux.cfg.serviceName,
-		Resource: resource,
-		SpanOpts: mux.cfg.spanOpts,
-		Route:    route,
+		Service:    mux.cfg.serviceName,
+		Resource:   resource,
+		SpanOpts:   mux.cfg.spanOpts,
+		Route:      route,
+		HeaderTags: mux.cfg.headerTags,
 	})
 }
 
@@ -86,6 +87,7 @@ func WrapHandler(h http.Handler, service, resource string, opts ...Option) http.
 			Resource:   resource,
 			FinishOpts: cfg.finishOpts,
 			SpanOpts:   cfg.spanOpts,
+			HeaderTags: cfg.headerTags,
 		})
 	})
 }
@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/httptrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -26,6 +27,7 @@ type config struct {
 	finishOpts    []ddtrace.FinishOption
 	ignoreRequest func(*http.Request) bool
 	resourceNamer func(*http.Request) string
+	headerTags    httptrace.HeaderTags
 }
 
 // MuxOption has been deprecated in favor of Option.
@@ -104,6 +106,16 @@ func WithResourceNamer(namer func(req *http.Request) string) Option {
 	}
 }
 
+// WithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func WithHeaderTags(headers []string) Option {
+	return func(cfg *config) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
+
 // NoDebugStack prevents stack traces from being attached to spans finishing
 // with an error. This is useful in situations where errors are frequent and
 // performance is critical.
@@ -131,6 +143,7 @@ type roundTripperConfig struct {
 	ignoreRequest func(*http.Request) bool
 	spanOpts      []ddtrace.StartSpanOption
 	errCheck      func(err error) bool
+	headerTags    httptrace.HeaderTags
 }
 
 func newRoundTripperConfig() *roundTripperConfig {
@@ -244,3 +257,13 @@ func RTWithErrorCheck(fn func(err error) bool) RoundTripperOption {
 		cfg.errCheck = fn
 	}
 }
+
+// RTWithHeaderTags specifies the HTTP request and response headers to record as span tags,
+// overriding the headers configured globally through tracer.WithHeaderTags. Each entry is
+// either a header name or of the form "header:tag". An empty list disables the recording
+// of headers.
+func RTWithHeaderTags(headers []string) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.headerTags = httptrace.NewHeaderTags(headers)
+	}
+}
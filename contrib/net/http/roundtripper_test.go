@@ -495,6 +495,54 @@ func TestSpanOptions(t *testing.T) {
 	assert.Equal(t, tagValue, spans[0].Tag(tagKey))
 }
 
+func TestRoundTripperHeaderTags(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Response-Id", "resp")
+		w.Write([]byte(""))
+	}))
+	defer s.Close()
+
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Request-Id", "req")
+		return req
+	}
+
+	t.Run("global", func(t *testing.T) {
+		globalconfig.SetHeaderTag("x-request-id", "")
+		globalconfig.SetHeaderTag("x-response-id", "response.id")
+		defer globalconfig.ClearHeaderTags()
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		client := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport)}
+		_, err := client.Do(newRequest())
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "req", spans[0].Tag("http.request.headers.x-request-id"))
+		assert.Equal(t, "resp", spans[0].Tag("response.id"))
+	})
+
+	t.Run("override", func(t *testing.T) {
+		globalconfig.SetHeaderTag("x-request-id", "")
+		defer globalconfig.ClearHeaderTags()
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		client := &http.Client{Transport: WrapRoundTripper(http.DefaultTransport, RTWithHeaderTags([]string{"X-Response-Id"}))}
+		_, err := client.Do(newRequest())
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Nil(t, spans[0].Tag("http.request.headers.x-request-id"))
+		assert.Equal(t, "resp", spans[0].Tag("http.response.headers.x-response-id"))
+	})
+}
+
 func TestClientNamingSchema(t *testing.T) {
 	genSpans := namingschematest.GenSpansFn(func(t *testing.T, serviceOverride string) []mocktracer.Span {
 		var opts []RoundTripperOption
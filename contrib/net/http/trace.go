@@ -44,6 +44,11 @@ type ServeConfig struct {
 	FinishOpts []ddtrace.FinishOption
 	// SpanOpts specifies any options to be applied to the request starting span.
 	SpanOpts []ddtrace.StartSpanOption
+	// HeaderTags maps the lower-cased names of the request and response headers to record
+	// to the names of the tags holding them, an empty tag name standing for the default
+	// http.request.headers.<header> and http.response.headers.<header> tags. If nil, the
+	// headers configured globally through tracer.WithHeaderTags are recorded.
+	HeaderTags map[string]string
 }
 
 // TraceAndServe serves the handler h using the given ResponseWriter and Request, applying tracing
@@ -54,9 +59,24 @@ func TraceAndServe(h http.Handler, w http.ResponseWriter, r *http.Request, cfg *
 	}
 	opts := append(cfg.SpanOpts, tracer.ServiceName(cfg.Service), tracer.ResourceName(cfg.Resource))
 	opts = append(opts, tracer.Tag(ext.HTTPRoute, cfg.Route))
+	if cfg.HeaderTags != nil {
+		// opts may share its backing array with other requests
+		opts = append(opts[:len(opts):len(opts)], httptrace.HeaderTagsFromRequest(r, cfg.HeaderTags))
+	}
 	span, ctx := httptrace.StartRequestSpan(r, opts...)
 	rw, ddrw := wrapResponseWriter(w)
 	defer func() {
+		httptrace.SetResponseHeaderTags(span, rw.Header(), cfg.HeaderTags)
+		if r := recover(); r != nil {
+			// record the panic and leave its recovery to the server, unless the
+			// handler aborted the request on purpose
+			opts := cfg.FinishOpts
+			if r != http.ErrAbortHandler {
+				opts = append(opts[:len(opts):len(opts)], tracer.WithPanic(r))
+			}
+			httptrace.FinishRequestSpan(span, ddrw.status, opts...)
+			panic(r)
+		}
 		httptrace.FinishRequestSpan(span, ddrw.status, cfg.FinishOpts...)
 	}()
 
@@ -90,6 +90,46 @@ func TestTraceAndServe(t *testing.T) {
 		assert.Equal("503: Service Unavailable", span.Tag(ext.Error).(error).Error())
 	})
 
+	t.Run("panic", func(t *testing.T) {
+		mt := mocktracer.Start()
+		assert := assert.New(t)
+		defer mt.Stop()
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/path", nil)
+		assert.NoError(err)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}
+		assert.PanicsWithValue("boom", func() {
+			TraceAndServe(http.HandlerFunc(handler), w, r, &ServeConfig{Service: "service"})
+		})
+		spans := mt.FinishedSpans()
+
+		assert.Len(spans, 1)
+		assert.Equal("boom", spans[0].Tag(ext.Error).(error).Error())
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		mt := mocktracer.Start()
+		assert := assert.New(t)
+		defer mt.Stop()
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/path", nil)
+		assert.NoError(err)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}
+		assert.PanicsWithValue(http.ErrAbortHandler, func() {
+			TraceAndServe(http.HandlerFunc(handler), w, r, &ServeConfig{Service: "service"})
+		})
+		spans := mt.FinishedSpans()
+
+		assert.Len(spans, 1)
+		assert.Nil(spans[0].Tag(ext.Error))
+	})
+
 	t.Run("query-params", func(t *testing.T) {
 		mt := mocktracer.Start()
 		assert := assert.New(t)
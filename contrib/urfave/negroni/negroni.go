@@ -37,8 +37,13 @@ func (m *DatadogMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, m.cfg.analyticsRate))
 	}
 
+	if m.cfg.headerTags != nil {
+		// opts may share its backing array with other requests
+		opts = append(opts[:len(opts):len(opts)], httptrace.HeaderTagsFromRequest(r, m.cfg.headerTags))
+	}
 	span, ctx := httptrace.StartRequestSpan(r, opts...)
 	defer func() {
+		httptrace.SetResponseHeaderTags(span, w.Header(), m.cfg.headerTags)
 		// check if the responseWriter is of type negroni.ResponseWriter
 		var (
 			status int
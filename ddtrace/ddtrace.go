@@ -77,6 +77,27 @@ type Span interface {
 	Context() SpanContext
 }
 
+// SpanWithLinks represents a Span which can hold links to other spans. Links
+// express a causal relationship with spans outside of the span's own parent
+// chain, such as the producers of the messages of a batch being consumed.
+type SpanWithLinks interface {
+	Span
+
+	// AddLink adds a link to the given span. Links have to be added before
+	// the span is finished.
+	AddLink(link SpanLink)
+}
+
+// SpanWithEvents represents a Span which can record events which occurred
+// during its lifetime.
+type SpanWithEvents interface {
+	Span
+
+	// AddEvent records an event with the given name on the span. Events have
+	// to be added before the span is finished.
+	AddEvent(name string, opts ...SpanEventOption)
+}
+
 // SpanContext represents a span state that can propagate to descendant spans
 // and across process boundaries. It contains all the information needed to
 // spawn a direct descendant of the span that it belongs to. It can be used
@@ -121,6 +142,21 @@ type FinishConfig struct {
 	SkipStackFrames uint
 }
 
+// SpanEventOption is a configuration option that can be used with a Span's AddEvent method.
+type SpanEventOption func(cfg *SpanEventConfig)
+
+// SpanEventConfig holds the configuration for adding an event to a span. It is usually
+// passed around by reference to one or more SpanEventOption functions which shape it into
+// its final form.
+type SpanEventConfig struct {
+	// Time holds the time at which the event occurred. Implementations should use
+	// the current time when Time.IsZero().
+	Time time.Time
+
+	// Attributes holds a set of key/value pairs that should be set on the event.
+	Attributes map[string]interface{}
+}
+
 // StartSpanConfig holds the configuration for starting a new span. It is usually passed
 // around by reference to one or more StartSpanOption functions which shape it into its
 // final form.
@@ -144,6 +180,9 @@ type StartSpanConfig struct {
 
 	// Context is the parent context where the span should be stored.
 	Context context.Context
+
+	// SpanLinks holds links to other spans which should be set on the new span.
+	SpanLinks []SpanLink
 }
 
 // Logger implementations are able to log given messages that the tracer or profiler might output.
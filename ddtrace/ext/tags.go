@@ -47,6 +47,10 @@ const (
 	// See https://docs.datadoghq.com/tracing/trace_collection/tracing_naming_convention/#http-requests
 	HTTPRequestHeaders = "http.request.headers"
 
+	// HTTPResponseHeaders sets the HTTP response headers partial tag
+	// This tag is meant to be composed, i.e http.response.headers.headerX, http.response.headers.headerY, etc...
+	HTTPResponseHeaders = "http.response.headers"
+
 	// SpanName is a pseudo-key for setting a span's operation name by means of
 	// a tag. It is mostly here to facilitate vendor-agnostic frameworks like Opentracing
 	// and OpenCensus.
@@ -79,6 +83,17 @@ const (
 	// ErrorDetails holds details about an error which implements a formatter.
 	ErrorDetails = "error.details"
 
+	// ErrorFingerprint holds a fingerprint of an error, computed from its type and
+	// the top stack frames of the place it was recorded at.
+	ErrorFingerprint = "error.fingerprint"
+
+	// ErrorChain holds the chain of wrapped errors of an error, as a JSON array.
+	ErrorChain = "error.chain"
+
+	// ErrorGoroutineStacks holds the stacks of all goroutines at the time a panic
+	// was recovered.
+	ErrorGoroutineStacks = "error.goroutine_stacks"
+
 	// Environment specifies the environment to use with a trace.
 	Environment = "env"
 
@@ -115,4 +130,7 @@ const (
 const (
 	// MessagingKafkaPartition defines the Kafka partition the trace is associated with.
 	MessagingKafkaPartition = "messaging.kafka.partition"
+
+	// MessagingDestination defines the name of the queue or topic a message is sent to.
+	MessagingDestination = "messaging.destination"
 )
@@ -16,7 +16,11 @@ import (
 )
 
 var _ ddtrace.Span = (*mockspan)(nil)
+var _ ddtrace.SpanWithLinks = (*mockspan)(nil)
+var _ ddtrace.SpanWithEvents = (*mockspan)(nil)
 var _ Span = (*mockspan)(nil)
+var _ SpanWithLinks = (*mockspan)(nil)
+var _ SpanWithEvents = (*mockspan)(nil)
 
 // Span is an interface that allows querying a span returned by the mock tracer.
 type Span interface {
@@ -51,6 +55,24 @@ type Span interface {
 	fmt.Stringer
 }
 
+// SpanWithLinks is a Span which allows querying the links to other spans it
+// holds. The spans returned by the mock tracer implement it.
+type SpanWithLinks interface {
+	Span
+
+	// Links returns a copy of the links to other spans held by this span.
+	Links() []ddtrace.SpanLink
+}
+
+// SpanWithEvents is a Span which allows querying the events recorded on it. The
+// spans returned by the mock tracer implement it.
+type SpanWithEvents interface {
+	Span
+
+	// Events returns a copy of the events recorded on this span.
+	Events() []ddtrace.SpanEvent
+}
+
 func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig) *mockspan {
 	if cfg.Tags == nil {
 		cfg.Tags = make(map[string]interface{})
@@ -93,6 +115,9 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 	for k, v := range cfg.Tags {
 		s.SetTag(k, v)
 	}
+	for _, l := range cfg.SpanLinks {
+		s.AddLink(l)
+	}
 	return s
 }
 
@@ -102,6 +127,8 @@ type mockspan struct {
 	tags         map[string]interface{}
 	finishTime   time.Time
 	finished     bool
+	links        []ddtrace.SpanLink
+	events       []ddtrace.SpanEvent
 
 	startTime time.Time
 	parentID  uint64
@@ -155,6 +182,55 @@ func (s *mockspan) Tags() map[string]interface{} {
 	return cp
 }
 
+// AddLink adds a link to another span.
+func (s *mockspan) AddLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.links = append(s.links, link)
+}
+
+func (s *mockspan) Links() []ddtrace.SpanLink {
+	s.RLock()
+	defer s.RUnlock()
+	// copy
+	cp := make([]ddtrace.SpanLink, len(s.links))
+	copy(cp, s.links)
+	return cp
+}
+
+// AddEvent records an event with the given name on the span.
+func (s *mockspan) AddEvent(name string, opts ...ddtrace.SpanEventOption) {
+	var cfg ddtrace.SpanEventConfig
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.Time.IsZero() {
+		cfg.Time = time.Now()
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.events = append(s.events, ddtrace.SpanEvent{
+		Name:         name,
+		TimeUnixNano: uint64(cfg.Time.UnixNano()),
+		Attributes:   cfg.Attributes,
+	})
+}
+
+func (s *mockspan) Events() []ddtrace.SpanEvent {
+	s.RLock()
+	defer s.RUnlock()
+	// copy
+	cp := make([]ddtrace.SpanEvent, len(s.events))
+	copy(cp, s.events)
+	return cp
+}
+
 func (s *mockspan) TraceID() uint64 { return s.context.traceID }
 
 func (s *mockspan) SpanID() uint64 { return s.context.spanID }
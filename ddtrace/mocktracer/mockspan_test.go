@@ -200,6 +200,32 @@ func TestSpanWithID(t *testing.T) {
 	assert.Equal(spanID, span.Context().SpanID())
 }
 
+func TestSpanLinks(t *testing.T) {
+	link := ddtrace.SpanLink{TraceID: 1, SpanID: 2}
+	s := newSpan(&mocktracer{}, "http.request", &ddtrace.StartSpanConfig{SpanLinks: []ddtrace.SpanLink{link}})
+	extra := ddtrace.SpanLink{TraceID: 3, SpanID: 4, Attributes: map[string]string{"k": "v"}}
+	s.AddLink(extra)
+	s.Finish()
+	s.AddLink(ddtrace.SpanLink{TraceID: 5, SpanID: 6})
+
+	var span Span = s
+	sl, ok := span.(SpanWithLinks)
+	assert.True(t, ok)
+	assert.Equal(t, []ddtrace.SpanLink{link, extra}, sl.Links())
+}
+
+func TestSpanEvents(t *testing.T) {
+	s := basicSpan("http.request")
+	s.AddEvent("event", tracer.WithSpanEventTimestamp(time.Unix(0, 1)), tracer.WithSpanEventAttributes(map[string]interface{}{"k": "v"}))
+	s.Finish()
+	s.AddEvent("ignored")
+
+	var span Span = s
+	se, ok := span.(SpanWithEvents)
+	assert.True(t, ok)
+	assert.Equal(t, []ddtrace.SpanEvent{{Name: "event", TimeUnixNano: 1, Attributes: map[string]interface{}{"k": "v"}}}, se.Events())
+}
+
 func TestSetUser(t *testing.T) {
 	const (
 		id        = "john.doe#12345"
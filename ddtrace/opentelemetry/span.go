@@ -7,6 +7,8 @@ package opentelemetry
 
 import (
 	"encoding/binary"
+	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
 
@@ -30,9 +32,7 @@ type span struct {
 	*oteltracer
 }
 
-func (s *span) TracerProvider() oteltrace.TracerProvider        { return s.oteltracer.provider }
-func (s *span) AddEvent(_ string, _ ...oteltrace.EventOption)   { /*	no-op */ }
-func (s *span) RecordError(_ error, _ ...oteltrace.EventOption) { /*	no-op */ }
+func (s *span) TracerProvider() oteltrace.TracerProvider { return s.oteltracer.provider }
 
 func (s *span) SetName(name string) { s.SetOperationName(name) }
 
@@ -137,6 +137,62 @@ func (s *span) SetStatus(code otelcodes.Code, description string) {
 	}
 }
 
+// AddEvent records an event with the given name and options on the span.
+// It has no effect if the span is no longer recording.
+func (s *span) AddEvent(name string, options ...oteltrace.EventOption) {
+	if !s.IsRecording() {
+		return
+	}
+	s.addEvent(name, oteltrace.NewEventConfig(options...), nil)
+}
+
+// RecordError records an "exception" event describing err on the span and
+// sets the `error.message` and `error.type` tags. When the oteltrace.WithStackTrace
+// option is used, the stack trace is recorded on both the event and the
+// `error.stack` tag. RecordError does not change the status of the span. It has
+// no effect if err is nil or if the span is no longer recording.
+func (s *span) RecordError(err error, options ...oteltrace.EventOption) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	cfg := oteltrace.NewEventConfig(options...)
+	typ := reflect.TypeOf(err).String()
+	attrs := map[string]interface{}{
+		"exception.type":    typ,
+		"exception.message": err.Error(),
+	}
+	s.SetTag(ext.ErrorMsg, err.Error())
+	s.SetTag(ext.ErrorType, typ)
+	if cfg.StackTrace() {
+		stack := string(debug.Stack())
+		attrs["exception.stacktrace"] = stack
+		s.SetTag(ext.ErrorStack, stack)
+	}
+	s.addEvent("exception", cfg, attrs)
+}
+
+// addEvent adds an event to the underlying Datadog span, merging the attributes
+// from cfg into attrs.
+func (s *span) addEvent(name string, cfg oteltrace.EventConfig, attrs map[string]interface{}) {
+	sp, ok := s.Span.(ddtrace.SpanWithEvents)
+	if !ok {
+		return
+	}
+	if kv := cfg.Attributes(); len(kv) > 0 {
+		if attrs == nil {
+			attrs = make(map[string]interface{}, len(kv))
+		}
+		for _, attr := range kv {
+			attrs[string(attr.Key)] = attr.Value.AsInterface()
+		}
+	}
+	opts := []ddtrace.SpanEventOption{tracer.WithSpanEventTimestamp(cfg.Timestamp())}
+	if len(attrs) > 0 {
+		opts = append(opts, tracer.WithSpanEventAttributes(attrs))
+	}
+	sp.AddEvent(name, opts...)
+}
+
 // SetAttributes sets the key-value pairs as tags on the span.
 // Every value is propagated as an interface.
 func (s *span) SetAttributes(kv ...attribute.KeyValue) {
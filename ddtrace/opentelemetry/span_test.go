@@ -371,6 +371,84 @@ func TestSpanSetAttributes(t *testing.T) {
 	assert.NotContains(payload, "v1_old")
 }
 
+func TestSpanLinks(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, payloads, cleanup := mockTracerProvider(t)
+	tr := otel.Tracer("")
+	defer cleanup()
+
+	linked := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2},
+		SpanID:     oteltrace.SpanID{0, 0, 0, 0, 0, 0, 0, 3},
+		TraceFlags: oteltrace.FlagsSampled,
+	})
+	_, sp := tr.Start(context.Background(), "test", oteltrace.WithLinks(
+		oteltrace.Link{SpanContext: linked, Attributes: []attribute.KeyValue{attribute.String("link.reason", "batch")}},
+		oteltrace.Link{SpanContext: oteltrace.SpanContext{}},
+	))
+	sp.End()
+	tracer.Flush()
+	payload, err := waitForPayload(ctx, payloads)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assert.Contains(payload, `"span_links":[{"trace_id":2,"trace_id_high":1,"span_id":3,"attributes":{"link.reason":"batch"},"flags":2147483649}]`)
+}
+
+func TestSpanAddEvent(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, payloads, cleanup := mockTracerProvider(t)
+	tr := otel.Tracer("")
+	defer cleanup()
+
+	_, sp := tr.Start(context.Background(), "test")
+	sp.AddEvent("cache.miss", oteltrace.WithTimestamp(time.Unix(0, 1)), oteltrace.WithAttributes(attribute.String("cache.key", "user")))
+	sp.End()
+	sp.AddEvent("ignored")
+	tracer.Flush()
+	payload, err := waitForPayload(ctx, payloads)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assert.Contains(payload, `cache.miss`)
+	assert.Contains(payload, `\"time_unix_nano\":1`)
+	assert.Contains(payload, `\"cache.key\":\"user\"`)
+	assert.NotContains(payload, "ignored")
+}
+
+func TestSpanRecordError(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, payloads, cleanup := mockTracerProvider(t)
+	tr := otel.Tracer("")
+	defer cleanup()
+
+	_, sp := tr.Start(context.Background(), "test")
+	sp.RecordError(nil)
+	sp.RecordError(errors.New("boom"), oteltrace.WithStackTrace(true))
+	sp.End()
+	tracer.Flush()
+	payload, err := waitForPayload(ctx, payloads)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assert.Contains(payload, `"error.message":"boom"`)
+	assert.Contains(payload, `"error.type":"*errors.errorString"`)
+	assert.Contains(payload, `"error.stack":`)
+	assert.Contains(payload, `\"name\":\"exception\"`)
+	assert.Contains(payload, `\"exception.message\":\"boom\"`)
+	assert.Contains(payload, `exception.stacktrace`)
+	assert.Contains(payload, `"error":0`)
+}
+
 func TestTracerStartOptions(t *testing.T) {
 	assert := assert.New(t)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
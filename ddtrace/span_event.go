@@ -0,0 +1,20 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package ddtrace
+
+// SpanEvent represents an event which occurred at a given point in time during
+// the lifetime of a span, such as an exception being recorded.
+type SpanEvent struct {
+	// Name holds the name of the event.
+	Name string `json:"name"`
+
+	// TimeUnixNano holds the time at which the event occurred, in nanoseconds
+	// since the Unix epoch.
+	TimeUnixNano uint64 `json:"time_unix_nano"`
+
+	// Attributes holds a set of key/value pairs describing the event.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
@@ -0,0 +1,32 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+//go:generate msgp -marshal=false -o=span_link_msgp.go -tests=false
+
+package ddtrace
+
+// SpanLink represents a reference from a span to another span, which may
+// belong to a different trace.
+type SpanLink struct {
+	// TraceID holds the lower 64 bits of the trace ID of the linked span.
+	TraceID uint64 `msg:"trace_id" json:"trace_id"`
+
+	// TraceIDHigh holds the upper 64 bits of the trace ID of the linked span,
+	// if it has a 128-bit trace ID.
+	TraceIDHigh uint64 `msg:"trace_id_high,omitempty" json:"trace_id_high,omitempty"`
+
+	// SpanID holds the span ID of the linked span.
+	SpanID uint64 `msg:"span_id" json:"span_id"`
+
+	// Attributes holds a set of key/value pairs describing the link.
+	Attributes map[string]string `msg:"attributes,omitempty" json:"attributes,omitempty"`
+
+	// Tracestate holds the W3C tracestate of the linked span, if known.
+	Tracestate string `msg:"tracestate,omitempty" json:"tracestate,omitempty"`
+
+	// Flags holds the W3C trace flags of the linked span, if known. When set, the
+	// 31st bit is also set to signal that the flags are present.
+	Flags uint32 `msg:"flags,omitempty" json:"flags,omitempty"`
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+const (
+	// adaptiveWindow is the period over which the volume of root spans is
+	// observed before the sampling rates are adjusted.
+	adaptiveWindow = time.Second
+
+	// adaptiveDecay is the weight of the previous throughput estimate of a key
+	// when it is updated with the throughput observed over one window.
+	adaptiveDecay = 0.5
+
+	// adaptiveMinTPS is the estimated throughput under which a key which saw no
+	// root spans during the last window is forgotten.
+	adaptiveMinTPS = 0.01
+
+	// adaptiveMaxKeys is the maximum number of keys tracked by the sampler. Root
+	// spans of any additional key share the rate of adaptiveOverflowKey.
+	adaptiveMaxKeys = 1000
+
+	// adaptiveOverflowKey is the key of root spans once adaptiveMaxKeys is reached.
+	adaptiveOverflowKey = ""
+)
+
+// adaptiveSampler samples traces so that the throughput of sampled traces stays
+// within a budget of targetTPS traces per second for each service and resource.
+// It observes the volume of root spans for each service and resource and adjusts
+// the rate of each key at the end of every adaptiveWindow, similarly to what the
+// agent does for the rates provided to the priority sampler. The resource is the
+// one of the root span at the time the sampling decision is made.
+type adaptiveSampler struct {
+	targetTPS float64
+
+	// clock returns the current time; replaced in tests.
+	clock func() time.Time
+
+	mu          sync.Mutex               // guards below fields
+	windowStart time.Time                // start of the current window
+	keys        map[string]*adaptiveRate // per-key state
+}
+
+// adaptiveRate holds the state of a key of the adaptive sampler.
+type adaptiveRate struct {
+	seen float64 // number of root spans seen in the current window
+	tps  float64 // estimated throughput of root spans, in spans per second
+	rate float64 // sampling rate currently applied
+}
+
+func newAdaptiveSampler(targetTPS float64) *adaptiveSampler {
+	return &adaptiveSampler{
+		targetTPS:   targetTPS,
+		clock:       nowTime,
+		windowStart: nowTime(),
+		keys:        make(map[string]*adaptiveRate),
+	}
+}
+
+// adaptiveKey returns the key of the given root span. Callers must guard the span.
+func adaptiveKey(s *span) string {
+	return "service:" + s.Service + ",resource:" + s.Resource
+}
+
+// apply makes a sampling decision for the given root span, using the current rate
+// of its key. Caller must ensure it is safe to modify the span.
+func (as *adaptiveSampler) apply(s *span) {
+	s.RLock()
+	key := adaptiveKey(s)
+	s.RUnlock()
+	rate := as.observe(key)
+	if sampledByRate(s.TraceID, rate) {
+		s.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Adaptive)
+	} else {
+		s.setSamplingPriority(ext.PriorityAutoReject, samplernames.Adaptive)
+	}
+	s.SetTag(keyAdaptiveSamplerRate, rate)
+}
+
+// observe counts a root span for key and returns the rate to sample it with.
+func (as *adaptiveSampler) observe(key string) float64 {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.adjust(as.clock())
+	r, ok := as.keys[key]
+	if !ok {
+		if len(as.keys) >= adaptiveMaxKeys {
+			key = adaptiveOverflowKey
+			r, ok = as.keys[key]
+		}
+		if !ok {
+			// no volume was observed yet, keep everything until the end of the window
+			r = &adaptiveRate{rate: 1}
+			as.keys[key] = r
+		}
+	}
+	r.seen++
+	return r.rate
+}
+
+// adjust updates the throughput estimate and the rate of all keys once the current
+// window is over. as.mu must be held.
+func (as *adaptiveSampler) adjust(now time.Time) {
+	elapsed := now.Sub(as.windowStart)
+	if elapsed < adaptiveWindow {
+		return
+	}
+	as.windowStart = now
+	// the previous estimate decays once for every window which has passed
+	decay := math.Pow(adaptiveDecay, float64(elapsed)/float64(adaptiveWindow))
+	for key, r := range as.keys {
+		observed := r.seen / elapsed.Seconds()
+		if r.tps == 0 {
+			r.tps = observed
+		} else {
+			r.tps = decay*r.tps + (1-decay)*observed
+		}
+		if r.seen == 0 && r.tps < adaptiveMinTPS {
+			delete(as.keys, key)
+			continue
+		}
+		r.seen = 0
+		r.rate = 1
+		if r.tps > as.targetTPS {
+			r.rate = as.targetTPS / r.tps
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// fakeClock is a manually advanced clock for the adaptive sampler.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestAdaptiveSampler(tps float64) (*adaptiveSampler, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	as := newAdaptiveSampler(tps)
+	as.clock = clock.now
+	as.windowStart = clock.now()
+	return as, clock
+}
+
+func TestAdaptiveSampler(t *testing.T) {
+	t.Run("converges", func(t *testing.T) {
+		assert := assert.New(t)
+		as, clock := newTestAdaptiveSampler(10)
+
+		// the volume is unknown during the first window: everything is kept
+		for i := 0; i < 100; i++ {
+			assert.Equal(1., as.observe("a"))
+		}
+		clock.advance(time.Second)
+		assert.InDelta(0.1, as.observe("a"), 1e-9)
+		for i := 1; i < 200; i++ {
+			as.observe("a")
+		}
+		clock.advance(time.Second)
+		// the estimate is the average of 100 and 200 traces per second
+		assert.InDelta(10./150, as.observe("a"), 1e-9)
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		as, clock := newTestAdaptiveSampler(10)
+		var kept int
+		for w := 0; w < 20; w++ {
+			for i := 0; i < 1000; i++ {
+				s := newBasicSpan("web.request")
+				s.TraceID = uint64(w*1000 + i + 1)
+				s.Resource = "GET /"
+				as.apply(s)
+				if w >= 10 && s.Metrics[keySamplingPriority] > 0 {
+					kept++
+				}
+			}
+			clock.advance(time.Second)
+		}
+		// 10 traces per second over the last 10 windows
+		assert.InDelta(t, 100, kept, 30)
+	})
+
+	t.Run("under-budget", func(t *testing.T) {
+		as, clock := newTestAdaptiveSampler(10)
+		for w := 0; w < 5; w++ {
+			for i := 0; i < 5; i++ {
+				assert.Equal(t, 1., as.observe("a"))
+			}
+			clock.advance(time.Second)
+		}
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		assert := assert.New(t)
+		as, clock := newTestAdaptiveSampler(10)
+		for i := 0; i < 1000; i++ {
+			as.observe("busy")
+		}
+		as.observe("quiet")
+		clock.advance(time.Second)
+		assert.InDelta(0.01, as.observe("busy"), 1e-9)
+		assert.Equal(1., as.observe("quiet"))
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		as, clock := newTestAdaptiveSampler(10)
+		as.observe("a")
+		clock.advance(time.Second)
+		as.observe("b")
+		require.Contains(t, as.keys, "a")
+		clock.advance(time.Minute)
+		as.observe("b")
+		assert.NotContains(t, as.keys, "a")
+		assert.Contains(t, as.keys, "b")
+	})
+
+	t.Run("max-keys", func(t *testing.T) {
+		as, _ := newTestAdaptiveSampler(10)
+		for i := 0; i < adaptiveMaxKeys+10; i++ {
+			as.observe(strconv.Itoa(i))
+		}
+		assert.Len(t, as.keys, adaptiveMaxKeys+1)
+		assert.Equal(t, 10., as.keys[adaptiveOverflowKey].seen)
+	})
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	t.Run("apply", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithAdaptiveSampling(10))
+		defer stop()
+		require.NotNil(t, tracer.adaptiveSampling)
+
+		s := tracer.StartSpan("web.request", ResourceName("GET /")).(*span)
+		assert.Equal(1., s.Metrics[keyAdaptiveSamplerRate])
+		assert.Equal(1., s.Metrics[keySamplingPriority])
+		assert.Equal("-"+strconv.Itoa(int(samplernames.Adaptive)), s.context.trace.propagatingTags[keyDecisionMaker])
+		assert.NotContains(s.Metrics, keySamplingPriorityRate)
+		assert.Contains(tracer.adaptiveSampling.keys, "service:tracer.test,resource:GET /")
+	})
+
+	t.Run("rules-first", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithAdaptiveSampling(10), WithSamplingRules([]SamplingRule{NameRule("web.request", 1)}))
+		defer stop()
+
+		s := tracer.StartSpan("web.request").(*span)
+		assert.Equal(1., s.Metrics[keyRulesSamplerAppliedRate])
+		assert.NotContains(s.Metrics, keyAdaptiveSamplerRate)
+		s = tracer.StartSpan("db.query").(*span)
+		assert.Equal(1., s.Metrics[keyAdaptiveSamplerRate])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+		assert.Nil(t, tracer.adaptiveSampling)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_ADAPTIVE_SAMPLING_TARGET_TPS", "2.5")
+		assert.Equal(t, 2.5, newConfig().adaptiveSamplingTPS)
+		t.Setenv("DD_TRACE_ADAPTIVE_SAMPLING_TARGET_TPS", "-1")
+		assert.Equal(t, 0., newConfig().adaptiveSamplingTPS)
+		assert.Equal(t, 0., newConfig(WithAdaptiveSampling(-1)).adaptiveSamplingTPS)
+	})
+}
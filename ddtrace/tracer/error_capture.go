@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"runtime"
+)
+
+const (
+	// defaultErrorChainDepth specifies the default maximum number of errors of an
+	// unwrap chain recorded in the error.chain tag.
+	defaultErrorChainDepth = 8
+
+	// defaultFingerprintFrames specifies the default number of stack frames error
+	// fingerprints are computed from.
+	defaultFingerprintFrames = 5
+
+	// maxGoroutineStacksSize specifies the maximum size of the goroutine stacks
+	// recorded for recovered panics.
+	maxGoroutineStacksSize = 64 << 10
+)
+
+// chainDepth returns the maximum number of errors of an unwrap chain to record.
+func (c *ErrorCaptureConfig) chainDepth() int {
+	if c == nil || c.ChainDepth == 0 {
+		return defaultErrorChainDepth
+	}
+	return c.ChainDepth
+}
+
+// fingerprintFrames returns the number of stack frames to compute fingerprints from.
+func (c *ErrorCaptureConfig) fingerprintFrames() int {
+	if c == nil || c.FingerprintFrames == 0 {
+		return defaultFingerprintFrames
+	}
+	return c.FingerprintFrames
+}
+
+// isError reports whether err should be recorded as a span error.
+func (c *ErrorCaptureConfig) isError(err error) bool {
+	if c == nil || c.IsError == nil {
+		return true
+	}
+	return c.IsError(err)
+}
+
+// panicStacks reports whether the goroutine stacks are recorded for recovered panics.
+func (c *ErrorCaptureConfig) panicStacks() bool {
+	return c != nil && c.PanicStacks
+}
+
+// panicError is the error recorded by the WithPanic finish option. It holds the
+// value passed to panic.
+type panicError struct {
+	value interface{}
+	err   error
+}
+
+func newPanicError(r interface{}) *panicError {
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+	return &panicError{value: r, err: err}
+}
+
+func (e *panicError) Error() string { return e.err.Error() }
+
+func (e *panicError) Unwrap() error { return e.err }
+
+// errorChainEntry is an error of the chain recorded in the error.chain tag.
+type errorChainEntry struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// errorChain returns the JSON encoded chain of up to depth errors wrapped by err,
+// err included, following both Unwrap() error and Unwrap() []error, as implemented by
+// errors.Join. It returns "" if err does not wrap any error.
+func errorChain(err error, depth int) string {
+	if depth <= 0 {
+		return ""
+	}
+	var chain []errorChainEntry
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil || len(chain) >= depth {
+			return
+		}
+		chain = append(chain, errorChainEntry{
+			Type:    reflect.TypeOf(err).String(),
+			Message: err.Error(),
+		})
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				walk(err)
+			}
+		}
+	}
+	walk(err)
+	if len(chain) < 2 {
+		return ""
+	}
+	b, err := json.Marshal(chain)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// errorFingerprint returns the fingerprint of an error of type typ, computed from typ
+// and the functions of the top n stack frames of the caller of the span method which
+// records the error, skipping the first skip frames.
+func errorFingerprint(typ string, n int, skip uint) string {
+	h := fnv.New64a()
+	h.Write([]byte(typ))
+	if n > 0 {
+		pcs := make([]uintptr, n)
+		// +4 to exclude runtime.Callers, errorFingerprint, setTagError and the span
+		// method calling it
+		frames := runtime.CallersFrames(pcs[:runtime.Callers(4+int(skip), pcs)])
+		for {
+			frame, more := frames.Next()
+			h.Write([]byte{'\n'})
+			h.Write([]byte(frame.Function))
+			if !more {
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// takeGoroutineStacks returns the stacks of all goroutines, truncated to
+// maxGoroutineStacksSize bytes.
+func takeGoroutineStacks() string {
+	buf := make([]byte, maxGoroutineStacksSize)
+	return string(buf[:runtime.Stack(buf, true)])
+}
@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// joinedErrors mimics the errors returned by errors.Join.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string { return "joined" }
+
+func (e joinedErrors) Unwrap() []error { return e }
+
+func TestErrorChain(t *testing.T) {
+	t.Run("wrapped", func(t *testing.T) {
+		err := fmt.Errorf("reading config: %w", fmt.Errorf("opening file: %w", io.EOF))
+		assert.JSONEq(t, `[
+			{"type": "*fmt.wrapError", "message": "reading config: opening file: EOF"},
+			{"type": "*fmt.wrapError", "message": "opening file: EOF"},
+			{"type": "*errors.errorString", "message": "EOF"}
+		]`, errorChain(err, defaultErrorChainDepth))
+	})
+
+	t.Run("joined", func(t *testing.T) {
+		err := joinedErrors{io.EOF, fmt.Errorf("closing: %w", io.ErrClosedPipe)}
+		assert.JSONEq(t, `[
+			{"type": "tracer.joinedErrors", "message": "joined"},
+			{"type": "*errors.errorString", "message": "EOF"},
+			{"type": "*fmt.wrapError", "message": "closing: io: read/write on closed pipe"},
+			{"type": "*errors.errorString", "message": "io: read/write on closed pipe"}
+		]`, errorChain(err, defaultErrorChainDepth))
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		err := fmt.Errorf("a: %w", fmt.Errorf("b: %w", io.EOF))
+		assert.JSONEq(t, `[
+			{"type": "*fmt.wrapError", "message": "a: b: EOF"},
+			{"type": "*fmt.wrapError", "message": "b: EOF"}
+		]`, errorChain(err, 2))
+		assert.Empty(t, errorChain(err, -1))
+	})
+
+	t.Run("unwrapped", func(t *testing.T) {
+		assert.Empty(t, errorChain(io.EOF, defaultErrorChainDepth))
+	})
+}
+
+func TestErrorFingerprint(t *testing.T) {
+	var fingerprints []string
+	for _, err := range []error{io.EOF, io.ErrUnexpectedEOF, errors.New("other")} {
+		s := newBasicSpan("web.request")
+		s.SetTag(ext.Error, err)
+		fingerprints = append(fingerprints, s.Meta[ext.ErrorFingerprint])
+	}
+	// errors of the same type recorded at the same place share their fingerprint
+	assert.Len(t, fingerprints[0], 16)
+	assert.Equal(t, fingerprints[0], fingerprints[1])
+	assert.Equal(t, fingerprints[0], fingerprints[2])
+
+	s := newBasicSpan("web.request")
+	s.SetTag(ext.Error, &joinedErrors{})
+	assert.NotEqual(t, fingerprints[0], s.Meta[ext.ErrorFingerprint])
+
+	// errors recorded at other places have other fingerprints
+	s = newBasicSpan("web.request")
+	setErrorTag(s, io.EOF)
+	assert.NotEqual(t, fingerprints[0], s.Meta[ext.ErrorFingerprint])
+
+	s = newBasicSpan("web.request")
+	s.Finish(WithError(io.EOF))
+	assert.Equal(t, fingerprints[0], s.Meta[ext.ErrorFingerprint])
+
+	// without debug stacks, fingerprints are computed from error types only
+	s = newBasicSpan("web.request")
+	s.Finish(WithError(io.EOF), NoDebugStack())
+	assert.Equal(t, errorFingerprint("*errors.errorString", 0, 0), s.Meta[ext.ErrorFingerprint])
+
+	assert.Equal(t, errorFingerprint("*errors.errorString", -1, 0), errorFingerprint("*errors.errorString", -1, 0))
+}
+
+func setErrorTag(s *span, err error) { s.SetTag(ext.Error, err) }
+
+func TestWithPanic(t *testing.T) {
+	t.Run("value", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newBasicSpan("web.request")
+		s.Finish(WithPanic("boom"))
+		assert.Equal(int32(1), s.Error)
+		assert.Equal("boom", s.Meta[ext.ErrorMsg])
+		assert.Equal("string", s.Meta[ext.ErrorType])
+		assert.NotEmpty(s.Meta[ext.ErrorStack])
+		assert.NotContains(s.Meta, ext.ErrorGoroutineStacks)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newBasicSpan("web.request")
+		s.Finish(WithPanic(fmt.Errorf("rendering: %w", io.EOF)))
+		assert.Equal("rendering: EOF", s.Meta[ext.ErrorMsg])
+		assert.Equal("*fmt.wrapError", s.Meta[ext.ErrorType])
+		assert.Contains(s.Meta[ext.ErrorChain], `"message":"EOF"`)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		s := newBasicSpan("web.request")
+		s.Finish(WithPanic(nil))
+		assert.Equal(t, int32(0), s.Error)
+	})
+
+	t.Run("stacks", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithErrorCapture(ErrorCaptureConfig{PanicStacks: true}))
+		defer stop()
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithPanic("boom"))
+		assert.Contains(t, s.Meta[ext.ErrorGoroutineStacks], "goroutine ")
+		assert.Contains(t, s.Meta[ext.ErrorGoroutineStacks], "tracer.TestWithPanic")
+	})
+}
+
+func TestErrorCaptureConfig(t *testing.T) {
+	t.Run("is-error", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithErrorCapture(ErrorCaptureConfig{
+			IsError: func(err error) bool { return !errors.Is(err, context.Canceled) },
+		}))
+		defer stop()
+
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(fmt.Errorf("handling request: %w", context.Canceled)))
+		assert.Equal(int32(0), s.Error)
+		assert.NotContains(s.Meta, ext.ErrorMsg)
+
+		s = tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(io.EOF))
+		assert.Equal(int32(1), s.Error)
+		assert.Equal("EOF", s.Meta[ext.ErrorMsg])
+	})
+
+	t.Run("chain-depth", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithErrorCapture(ErrorCaptureConfig{ChainDepth: -1}))
+		defer stop()
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(fmt.Errorf("wrapped: %w", io.EOF)))
+		assert.Equal(t, "wrapped: EOF", s.Meta[ext.ErrorMsg])
+		assert.NotContains(t, s.Meta, ext.ErrorChain)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		c := newConfig()
+		assert.Equal(t, defaultErrorChainDepth, c.errorCapture.chainDepth())
+		assert.Equal(t, defaultFingerprintFrames, c.errorCapture.fingerprintFrames())
+		assert.False(t, c.errorCapture.panicStacks())
+		assert.True(t, c.errorCapture.isError(io.EOF))
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/normalizer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
 
@@ -65,6 +66,36 @@ type config struct {
 	// failure.
 	sendRetries int
 
+	// spoolDir, when set, is the directory in which payloads which failed to be
+	// sent to the agent are spooled until they can be replayed. Value from
+	// DD_TRACE_SPOOL_DIR, default disabled.
+	spoolDir string
+
+	// spoolMaxBytes is the maximum total size of the spooled payloads. Value from
+	// DD_TRACE_SPOOL_MAX_BYTES, default 64MB.
+	spoolMaxBytes int64
+
+	// spoolMaxAge is the maximum age of the spooled payloads. Value from
+	// DD_TRACE_SPOOL_MAX_AGE, default 1h.
+	spoolMaxAge time.Duration
+
+	// remoteConfigEnabled reports whether the tracer settings may be updated at
+	// runtime through remote configuration. When enabled, the tracer polls the agent
+	// for its settings every DD_REMOTE_CONFIG_POLL_INTERVAL_SECONDS (default 5s),
+	// using the client shared with AppSec. Value from DD_REMOTE_CONFIGURATION_ENABLED,
+	// default true.
+	remoteConfigEnabled bool
+
+	// traceProtocol specifies the version of the agent's trace intake protocol used
+	// to encode traces. Version 0.5 is used when the agent lists its endpoint in
+	// /info, 0.4 otherwise. Setting DD_TRACE_AGENT_PROTOCOL_VERSION to 0.4 forces
+	// version 0.4.
+	traceProtocol float64
+
+	// otlpEndpoint, when set, is the OTLP/HTTP endpoint to which traces are sent
+	// instead of the agent.
+	otlpEndpoint string
+
 	// logStartup, when true, causes various startup info to be written
 	// when the tracer starts.
 	logStartup bool
@@ -115,6 +146,11 @@ type config struct {
 	// runtimeMetrics specifies whether collection of runtime metrics is enabled.
 	runtimeMetrics bool
 
+	// runtimeMetricsV2 specifies whether collection of runtime metrics through the
+	// runtime/metrics package is enabled. It may be enabled along with runtimeMetrics.
+	// Value from DD_RUNTIME_METRICS_V2_ENABLED, default false.
+	runtimeMetricsV2 bool
+
 	// dogstatsdAddr specifies the address to connect for sending metrics to the
 	// Datadog Agent. If not set, it defaults to "localhost:8125" or to the
 	// combination of the environment variables DD_AGENT_HOST and DD_DOGSTATSD_PORT.
@@ -140,6 +176,9 @@ type config struct {
 	// errors will record a stack trace when this option is set.
 	noDebugStack bool
 
+	// errorCapture configures the recording of the errors set on spans.
+	errorCapture ErrorCaptureConfig
+
 	// profilerHotspots specifies whether profiler Code Hotspots is enabled.
 	profilerHotspots bool
 
@@ -154,6 +193,55 @@ type config struct {
 
 	// spanAttributeSchemaVersion holds the selected DD_TRACE_SPAN_ATTRIBUTE_SCHEMA version.
 	spanAttributeSchemaVersion int
+
+	// partialFlushEnabled specifies whether finished spans of a trace may be flushed
+	// before the whole trace has finished. Value from DD_TRACE_PARTIAL_FLUSH_ENABLED,
+	// default false.
+	partialFlushEnabled bool
+
+	// partialFlushMinSpans is the number of finished spans in a single trace which
+	// triggers a partial flush. Value from DD_TRACE_PARTIAL_FLUSH_MIN_SPANS, default 1000.
+	partialFlushMinSpans int
+
+	// deferredSampling specifies whether the sampling decision of locally-rooted traces
+	// is deferred to the finish of their root span. Value from
+	// DD_TRACE_DEFERRED_SAMPLING_ENABLED, default false.
+	deferredSampling bool
+
+	// deferredSamplingLatency is the duration above which traces whose sampling decision
+	// is deferred are kept. Value from DD_TRACE_DEFERRED_SAMPLING_LATENCY_THRESHOLD,
+	// default 0, which disables keeping slow traces.
+	deferredSamplingLatency time.Duration
+
+	// spanProcessors holds the processors run on finished traces, in order.
+	spanProcessors []SpanProcessor
+
+	// adaptiveSamplingTPS is the target number of traces per second to keep for each
+	// service and resource using adaptive sampling, or 0 when adaptive sampling is
+	// disabled. Value from DD_TRACE_ADAPTIVE_SAMPLING_TARGET_TPS, default 0.
+	adaptiveSamplingTPS float64
+
+	// peerServiceDefaultsEnabled specifies whether peer.service is inferred from
+	// other tags on client and producer spans which do not set it. Value from
+	// DD_TRACE_PEER_SERVICE_DEFAULTS_ENABLED, default false.
+	peerServiceDefaultsEnabled bool
+
+	// peerServiceMappings holds a set of mappings to rename peer.service values.
+	peerServiceMappings map[string]string
+
+	// headerAsTags holds the HTTP headers recorded as span tags by the HTTP integrations,
+	// in the form "header" or "header:tag". Value from DD_TRACE_HEADER_TAGS.
+	headerAsTags []string
+
+	// baggageTagKeys holds the keys of the baggage items received from remote parents
+	// which are recorded as span tags, "*" standing for all keys. Value from
+	// DD_TRACE_BAGGAGE_TAG_KEYS.
+	baggageTagKeys []string
+
+	// statsPeerTags holds the tags client, producer and consumer spans are aggregated
+	// by in client-computed stats, when the agent supports peer tags aggregation.
+	// Value from DD_TRACE_STATS_PEER_TAGS, default peer.service, db.system and out.host.
+	statsPeerTags []string
 }
 
 // HasFeature reports whether feature f is enabled.
@@ -165,6 +253,10 @@ func (c *config) HasFeature(f string) bool {
 // StartOption represents a function that can be provided as a parameter to Start.
 type StartOption func(*config)
 
+// partialFlushMinSpansDefault is the default number of finished spans in a single
+// trace which triggers a partial flush, when partial flushing is enabled.
+const partialFlushMinSpansDefault = 1000
+
 // maxPropagatedTagsLength limits the size of DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH to prevent HTTP 413 responses.
 const maxPropagatedTagsLength = 512
 
@@ -205,6 +297,19 @@ func newConfig(opts ...StartOption) *config {
 	if v := os.Getenv("DD_SERVICE_MAPPING"); v != "" {
 		internal.ForEachStringTag(v, func(key, val string) { WithServiceMapping(key, val)(c) })
 	}
+	if v := os.Getenv("DD_TRACE_PEER_SERVICE_MAPPING"); v != "" {
+		internal.ForEachStringTag(v, func(key, val string) { WithPeerServiceMapping(key, val)(c) })
+	}
+	if v := os.Getenv("DD_TRACE_HEADER_TAGS"); v != "" {
+		WithHeaderTags(strings.Split(v, ","))(c)
+	}
+	if v := os.Getenv("DD_TRACE_BAGGAGE_TAG_KEYS"); v != "" {
+		WithBaggageTagKeys(strings.Split(v, ",")...)(c)
+	}
+	c.statsPeerTags = defaultStatsPeerTags
+	if v := os.Getenv("DD_TRACE_STATS_PEER_TAGS"); v != "" {
+		WithStatsPeerTags(strings.Split(v, ",")...)(c)
+	}
 	if v := os.Getenv("DD_TAGS"); v != "" {
 		tags := internal.ParseTagString(v)
 		internal.CleanGitMetadataTags(tags)
@@ -217,13 +322,56 @@ func newConfig(opts ...StartOption) *config {
 		// See: https://docs.aws.amazon.com/lambda/latest/dg/configuration-envvars.html
 		c.logToStdout = true
 	}
+	c.remoteConfigEnabled = internal.BoolEnv("DD_REMOTE_CONFIGURATION_ENABLED", true)
+	// v0.5 is negotiated with the agent in loadAgentFeatures
+	switch v := os.Getenv("DD_TRACE_AGENT_PROTOCOL_VERSION"); v {
+	case "", "0.5":
+		c.traceProtocol = traceProtocolV05
+	case "0.4":
+		c.traceProtocol = traceProtocolV04
+	default:
+		log.Warn("DD_TRACE_AGENT_PROTOCOL_VERSION=%s is not a valid value, negotiating the version with the agent", v)
+		c.traceProtocol = traceProtocolV05
+	}
+	c.spoolDir = os.Getenv("DD_TRACE_SPOOL_DIR")
+	c.spoolMaxBytes = int64(internal.IntEnv("DD_TRACE_SPOOL_MAX_BYTES", spoolMaxBytesDefault))
+	c.spoolMaxAge = internal.DurationEnv("DD_TRACE_SPOOL_MAX_AGE", spoolMaxAgeDefault)
+	if c.spoolMaxBytes <= 0 {
+		log.Warn("DD_TRACE_SPOOL_MAX_BYTES=%d is not a valid value, setting to default %d", c.spoolMaxBytes, spoolMaxBytesDefault)
+		c.spoolMaxBytes = spoolMaxBytesDefault
+	}
+	if c.spoolMaxAge <= 0 {
+		log.Warn("DD_TRACE_SPOOL_MAX_AGE=%s is not a valid value, setting to default %s", c.spoolMaxAge, spoolMaxAgeDefault)
+		c.spoolMaxAge = spoolMaxAgeDefault
+	}
+	if strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "otlp") {
+		c.otlpEndpoint = otlpEndpointFromEnv()
+	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
 	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", false)
+	c.runtimeMetricsV2 = internal.BoolEnv("DD_RUNTIME_METRICS_V2_ENABLED", false)
 	c.debug = internal.BoolEnv("DD_TRACE_DEBUG", false)
 	c.enabled = internal.BoolEnv("DD_TRACE_ENABLED", true)
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
 	c.profilerHotspots = internal.BoolEnv(traceprof.CodeHotspotsEnvVar, true)
 	c.enableHostnameDetection = internal.BoolEnv("DD_CLIENT_HOSTNAME_ENABLED", true)
+	c.peerServiceDefaultsEnabled = internal.BoolEnv("DD_TRACE_PEER_SERVICE_DEFAULTS_ENABLED", false)
+	c.partialFlushEnabled = internal.BoolEnv("DD_TRACE_PARTIAL_FLUSH_ENABLED", false)
+	c.partialFlushMinSpans = internal.IntEnv("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS", partialFlushMinSpansDefault)
+	if c.partialFlushMinSpans <= 0 || c.partialFlushMinSpans >= traceMaxSize {
+		log.Warn("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS=%d is not a valid value, setting to default %d", c.partialFlushMinSpans, partialFlushMinSpansDefault)
+		c.partialFlushMinSpans = partialFlushMinSpansDefault
+	}
+	c.deferredSampling = internal.BoolEnv("DD_TRACE_DEFERRED_SAMPLING_ENABLED", false)
+	c.deferredSamplingLatency = internal.DurationEnv("DD_TRACE_DEFERRED_SAMPLING_LATENCY_THRESHOLD", 0)
+	if v := os.Getenv("DD_TRACE_ADAPTIVE_SAMPLING_TARGET_TPS"); v != "" {
+		tps, err := strconv.ParseFloat(v, 64)
+		if err != nil || tps < 0 {
+			log.Warn("DD_TRACE_ADAPTIVE_SAMPLING_TARGET_TPS=%s is not a valid value, adaptive sampling disabled", v)
+		} else {
+			c.adaptiveSamplingTPS = tps
+		}
+	}
 
 	schemaVersionStr := os.Getenv("DD_TRACE_SPAN_ATTRIBUTE_SCHEMA")
 	if v, ok := namingschema.ParseVersion(schemaVersionStr); ok {
@@ -305,7 +453,15 @@ func newConfig(opts ...StartOption) *config {
 	if c.debug {
 		log.SetLevel(log.LevelDebug)
 	}
+	if c.deferredSampling && c.partialFlushEnabled {
+		log.Warn("Deferred sampling is not compatible with partial flushing, disabling deferred sampling.")
+		c.deferredSampling = false
+	}
 	c.loadAgentFeatures()
+	if c.traceProtocol == traceProtocolV05 && !c.agent.TracesV05 {
+		// the agent doesn't support v0.5, or its features could not be discovered
+		c.traceProtocol = traceProtocolV04
+	}
 	if c.statsdClient == nil {
 		// configure statsd client
 		addr := c.dogstatsdAddr
@@ -406,10 +562,33 @@ type agentFeatures struct {
 	// If it's the default, it will be 0, which means 8125.
 	StatsdPort int
 
+	// TracesV05 reports whether the agent can receive traces encoded using the
+	// v0.5 protocol on the /v0.5/traces endpoint.
+	TracesV05 bool
+
+	// PeerTags lists the tags client, producer and consumer spans are aggregated by
+	// in client-computed stats. It is empty unless the agent reports the
+	// agentFlagPeerTagsAggregation feature flag.
+	PeerTags []string
+
+	// SpanKindStats reports whether stats are computed for all client, producer and
+	// consumer spans, in addition to top-level and measured spans, which is the case
+	// when the agent reports the agentFlagSpanKindStats feature flag.
+	SpanKindStats bool
+
 	// featureFlags specifies all the feature flags reported by the trace-agent.
 	featureFlags map[string]struct{}
 }
 
+const (
+	// agentFlagPeerTagsAggregation is the feature flag reported by agents which
+	// aggregate stats by peer tags.
+	agentFlagPeerTagsAggregation = "peer_tags_aggregation"
+	// agentFlagSpanKindStats is the feature flag reported by agents which compute
+	// stats for client, producer and consumer spans.
+	agentFlagSpanKindStats = "compute_stats_by_span_kind"
+)
+
 // HasFlag reports whether the agent has set the feat feature flag.
 func (a *agentFeatures) HasFlag(feat string) bool {
 	_, ok := a.featureFlags[feat]
@@ -439,6 +618,7 @@ func (c *config) loadAgentFeatures() {
 		ClientDropP0s bool     `json:"client_drop_p0s"`
 		StatsdPort    int      `json:"statsd_port"`
 		FeatureFlags  []string `json:"feature_flags"`
+		PeerTags      []string `json:"peer_tags"`
 	}
 	var info infoResponse
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
@@ -451,12 +631,18 @@ func (c *config) loadAgentFeatures() {
 		switch endpoint {
 		case "/v0.6/stats":
 			c.agent.Stats = true
+		case "/v0.5/traces":
+			c.agent.TracesV05 = true
 		}
 	}
 	c.agent.featureFlags = make(map[string]struct{}, len(info.FeatureFlags))
 	for _, flag := range info.FeatureFlags {
 		c.agent.featureFlags[flag] = struct{}{}
 	}
+	if c.agent.HasFlag(agentFlagPeerTagsAggregation) {
+		c.agent.PeerTags = mergePeerTags(c.statsPeerTags, info.PeerTags)
+	}
+	c.agent.SpanKindStats = c.agent.HasFlag(agentFlagSpanKindStats)
 }
 
 func (c *config) canComputeStats() bool {
@@ -539,6 +725,38 @@ func WithDebugStack(enabled bool) StartOption {
 	}
 }
 
+// ErrorCaptureConfig configures the recording of the errors set on spans, through
+// the WithError finish option or the ext.Error tag.
+type ErrorCaptureConfig struct {
+	// ChainDepth specifies the maximum number of errors of the chain formed by
+	// errors.Unwrap and errors.Join which are recorded in the error.chain tag. It
+	// defaults to 8 when zero; a negative value disables the recording of chains.
+	ChainDepth int
+
+	// FingerprintFrames specifies the number of top stack frames, along with the
+	// error type, the error.fingerprint tag is computed from. It defaults to 5
+	// when zero; a negative value computes fingerprints from error types only,
+	// as does finishing spans with the NoDebugStack option.
+	FingerprintFrames int
+
+	// PanicStacks enables recording the stacks of all goroutines in the
+	// error.goroutine_stacks tag, for panics reported through WithPanic, such as
+	// panics recovered by the integrations.
+	PanicStacks bool
+
+	// IsError reports whether err is considered an error. Spans aren't marked as
+	// erroneous by errors it returns false for, e.g. context.Canceled. All errors are
+	// considered errors when nil.
+	IsError func(err error) bool
+}
+
+// WithErrorCapture configures the recording of the errors set on spans.
+func WithErrorCapture(cfg ErrorCaptureConfig) StartOption {
+	return func(c *config) {
+		c.errorCapture = cfg
+	}
+}
+
 // WithDebugMode enables debug mode on the tracer, resulting in more verbose logging.
 func WithDebugMode(enabled bool) StartOption {
 	return func(c *config) {
@@ -564,6 +782,50 @@ func WithSendRetries(retries int) StartOption {
 	}
 }
 
+// WithTraceSpool enables spooling to the directory dir of the trace payloads which
+// could not be sent to the agent, even after the retries configured with
+// WithSendRetries. Spooled payloads are replayed once the agent is reachable again,
+// including by subsequent runs of the application using the same directory. The
+// oldest payloads are evicted once they are older than maxAge, or once the total
+// size of the spool exceeds maxBytes. Zero or negative values of maxBytes and
+// maxAge default to 64MB and 1 hour respectively. This can also be configured with
+// the DD_TRACE_SPOOL_DIR, DD_TRACE_SPOOL_MAX_BYTES and DD_TRACE_SPOOL_MAX_AGE
+// environment variables. Spooling is disabled by default.
+//
+// Only the payloads which failed to be sent are spooled: the traces dropped before
+// being added to a payload, such as when the queue of finished traces is full,
+// are not.
+func WithTraceSpool(dir string, maxBytes int64, maxAge time.Duration) StartOption {
+	return func(c *config) {
+		if maxBytes <= 0 {
+			maxBytes = spoolMaxBytesDefault
+		}
+		if maxAge <= 0 {
+			maxAge = spoolMaxAgeDefault
+		}
+		c.spoolDir = dir
+		c.spoolMaxBytes = maxBytes
+		c.spoolMaxAge = maxAge
+	}
+}
+
+// WithOTLPExporter configures the tracer to convert traces to the OpenTelemetry
+// protocol (OTLP) and send them to the given OTLP/HTTP traces endpoint, such as
+// the one of an OpenTelemetry Collector, instead of the Datadog agent. If endpoint
+// is empty, it defaults to the value of the OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT environment variables, or to
+// "http://localhost:4318/v1/traces". Failed sends are retried according to
+// WithSendRetries. This can also be enabled by setting the OTEL_TRACES_EXPORTER
+// environment variable to "otlp". It has no effect in Lambda mode.
+func WithOTLPExporter(endpoint string) StartOption {
+	return func(c *config) {
+		if endpoint == "" {
+			endpoint = otlpEndpointFromEnv()
+		}
+		c.otlpEndpoint = endpoint
+	}
+}
+
 // WithPropagator sets an alternative propagator to be used by the tracer.
 func WithPropagator(p Propagator) StartOption {
 	return func(c *config) {
@@ -624,6 +886,78 @@ func WithServiceMapping(from, to string) StartOption {
 	}
 }
 
+// WithPeerServiceMapping determines the value of the peer.service tag "from" to be
+// renamed to "to", whether the tag was set explicitly or inferred by the tracer.
+// This option is case sensitive and can be used multiple times.
+func WithPeerServiceMapping(from, to string) StartOption {
+	return func(c *config) {
+		if c.peerServiceMappings == nil {
+			c.peerServiceMappings = make(map[string]string)
+		}
+		c.peerServiceMappings[from] = to
+	}
+}
+
+// WithPeerServiceDefaults sets whether the peer.service tag is inferred from other
+// tags, such as db.instance or out.host, on client and producer spans which do not
+// set it. It is disabled by default.
+func WithPeerServiceDefaults(enabled bool) StartOption {
+	return func(c *config) {
+		c.peerServiceDefaultsEnabled = enabled
+	}
+}
+
+// WithHeaderTags enables the recording of the given HTTP request and response headers
+// as span tags by all the HTTP server and client integrations. Each entry is either a
+// header name, in which case the header is recorded in the http.request.headers.<header>
+// and http.response.headers.<header> tags, or of the form "header:tag" to record the
+// header in the given tag. Header names are case insensitive. The integrations may
+// override these settings through their own options. It replaces the headers set
+// through DD_TRACE_HEADER_TAGS.
+func WithHeaderTags(headerAsTags []string) StartOption {
+	return func(c *config) {
+		globalconfig.ClearHeaderTags()
+		c.headerAsTags = c.headerAsTags[:0]
+		for _, h := range headerAsTags {
+			header, tag := normalizer.HeaderTag(h)
+			if header == "" {
+				continue
+			}
+			globalconfig.SetHeaderTag(header, tag)
+			c.headerAsTags = append(c.headerAsTags, h)
+		}
+	}
+}
+
+// WithBaggageTagKeys records the baggage items with the given keys, received from
+// remote parents through context propagation, as span tags named "baggage.<key>" on
+// the local root spans. The key "*" records all the baggage items. Keys are case
+// sensitive. It replaces the keys set through DD_TRACE_BAGGAGE_TAG_KEYS.
+func WithBaggageTagKeys(keys ...string) StartOption {
+	return func(c *config) {
+		c.baggageTagKeys = c.baggageTagKeys[:0]
+		for _, k := range keys {
+			if k = strings.TrimSpace(k); k != "" {
+				c.baggageTagKeys = append(c.baggageTagKeys, k)
+			}
+		}
+	}
+}
+
+// WithStatsPeerTags sets the tags client, producer and consumer spans are aggregated
+// by in client-computed stats, when the agent supports it. Tags requested by the agent
+// are added to these. It replaces the tags set through DD_TRACE_STATS_PEER_TAGS.
+func WithStatsPeerTags(tags ...string) StartOption {
+	return func(c *config) {
+		c.statsPeerTags = nil
+		for _, t := range tags {
+			if t = strings.TrimSpace(t); t != "" {
+				c.statsPeerTags = append(c.statsPeerTags, t)
+			}
+		}
+	}
+}
+
 // WithGlobalTag sets a key/value pair which will be set as a tag on all spans
 // created by tracer. This option may be used multiple times.
 func WithGlobalTag(k string, v interface{}) StartOption {
@@ -699,6 +1033,18 @@ func WithRuntimeMetrics() StartOption {
 	}
 }
 
+// WithRuntimeMetricsV2 enables automatic collection of runtime metrics every 10 seconds
+// through the runtime/metrics package, which does not stop the world, unlike the collector
+// enabled by WithRuntimeMetrics. Along with memory and garbage collection metrics, it reports
+// the distributions of the scheduling latencies and of the GC pauses, the time spent waiting
+// on mutexes, the number of goroutines, GOMAXPROCS and GOMEMLIMIT. Some of these metrics are
+// only available with recent versions of Go. Both collectors may be enabled at the same time.
+func WithRuntimeMetricsV2() StartOption {
+	return func(cfg *config) {
+		cfg.runtimeMetricsV2 = true
+	}
+}
+
 // WithDogstatsdAddress specifies the address to connect to for sending metrics to the Datadog
 // Agent. It should be a "host:port" string, or the path to a unix domain socket.If not set, it
 // attempts to determine the address of the statsd service according to the following rules:
@@ -727,6 +1073,21 @@ func WithSamplingRules(rules []SamplingRule) StartOption {
 	}
 }
 
+// WithAdaptiveSampling enables adaptive sampling, which keeps up to targetTPS traces
+// per second for each service and resource of root spans. The sampling rate of each
+// service and resource is continuously adjusted by the tracer based on the observed
+// volume of root spans. Traces matching sampling rules are sampled according to the
+// rules instead. A targetTPS of 0 disables adaptive sampling.
+func WithAdaptiveSampling(targetTPS float64) StartOption {
+	return func(c *config) {
+		if targetTPS < 0 {
+			log.Warn("WithAdaptiveSampling: ignoring negative target of %f traces per second", targetTPS)
+			return
+		}
+		c.adaptiveSamplingTPS = targetTPS
+	}
+}
+
 // WithServiceVersion specifies the version of the service that is running. This will
 // be included in spans from this service in the "version" tag, provided that
 // span service name and config service name match. Do NOT use with WithUniversalVersion.
@@ -793,6 +1154,57 @@ func WithProfilerEndpoints(enabled bool) StartOption {
 	}
 }
 
+// WithPartialFlushing enables flushing of partially finished traces. Once numSpans
+// spans of a single local trace have finished, they are sent to the agent as a
+// chunk of the trace, freeing up the memory they were consuming, while the rest of
+// the trace keeps being tracked. Every chunk carries the trace-level tags and the
+// sampling priority of the trace. This can also be enabled by setting the
+// DD_TRACE_PARTIAL_FLUSH_ENABLED environment variable to true, in which case the
+// number of spans defaults to 1000 unless overridden with DD_TRACE_PARTIAL_FLUSH_MIN_SPANS.
+// Partial flushing is disabled by default.
+func WithPartialFlushing(numSpans int) StartOption {
+	return func(c *config) {
+		if numSpans <= 0 || numSpans >= traceMaxSize {
+			log.Warn("WithPartialFlushing(%d) is not a valid value, setting to default %d", numSpans, partialFlushMinSpansDefault)
+			numSpans = partialFlushMinSpansDefault
+		}
+		c.partialFlushEnabled = true
+		c.partialFlushMinSpans = numSpans
+	}
+}
+
+// WithDeferredSampling defers the sampling decision of the traces started locally to
+// the finish of their root span, so that it can take into account everything known
+// about the trace by then. Traces where a span had an error and, when latencyThreshold
+// is positive, traces whose root span lasted at least latencyThreshold are kept, up to
+// the trace rate limit set by DD_TRACE_RATE_LIMIT. The other traces go through the configured sampling rules and rates, which then match
+// the tags set on the root span after its start. The decision is made earlier when
+// the trace context is injected into a carrier, so that it is propagated consistently.
+// Deferred sampling can also be enabled with the DD_TRACE_DEFERRED_SAMPLING_ENABLED and
+// DD_TRACE_DEFERRED_SAMPLING_LATENCY_THRESHOLD environment variables. It is disabled
+// by default and isn't compatible with partial flushing.
+func WithDeferredSampling(latencyThreshold time.Duration) StartOption {
+	return func(c *config) {
+		c.deferredSampling = true
+		c.deferredSamplingLatency = latencyThreshold
+	}
+}
+
+// WithSpanProcessor registers the given span processors, which are run on every
+// finished trace before it is sent to the agent. Processors run sequentially in
+// the order in which they were registered. This option may be used multiple times.
+// See SpanProcessor for more details.
+func WithSpanProcessor(processors ...SpanProcessor) StartOption {
+	return func(c *config) {
+		for _, p := range processors {
+			if p == nil {
+				continue
+			}
+			c.spanProcessors = append(c.spanProcessors, p)
+		}
+	}
+}
+
 // StartSpanOption is a configuration option for StartSpan. It is aliased in order
 // to help godoc group all the functions returning it together. It is considered
 // more correct to refer to it as the type as the origin, ddtrace.StartSpanOption.
@@ -850,6 +1262,16 @@ func ChildOf(ctx ddtrace.SpanContext) StartSpanOption {
 	}
 }
 
+// WithSpanLinks sets the given links to other spans on the started span. Links can
+// be used to express a causal relationship with spans which are not the parent of
+// the started span, such as the producers of the messages consumed in a batch.
+// This option may be used multiple times.
+func WithSpanLinks(links []ddtrace.SpanLink) StartSpanOption {
+	return func(cfg *ddtrace.StartSpanConfig) {
+		cfg.SpanLinks = append(cfg.SpanLinks, links...)
+	}
+}
+
 // withContext associates the ctx with the span.
 func withContext(ctx context.Context) StartSpanOption {
 	return func(cfg *ddtrace.StartSpanConfig) {
@@ -897,6 +1319,18 @@ func WithError(err error) FinishOption {
 	}
 }
 
+// WithPanic marks the span as having had an error caused by a panic, r being the
+// value returned by recover. The tags set are the ones of WithError, the error type
+// being the type of r, along with the stacks of all goroutines when enabled through
+// WithErrorCapture. It has no effect if r is nil.
+func WithPanic(r interface{}) FinishOption {
+	return func(cfg *ddtrace.FinishConfig) {
+		if r != nil {
+			cfg.Error = newPanicError(r)
+		}
+	}
+}
+
 // NoDebugStack prevents any error presented using the WithError finishing option
 // from generating a stack trace. This is useful in situations where errors are frequent
 // and performance is critical.
@@ -917,6 +1351,32 @@ func StackFrames(n, skip uint) FinishOption {
 	}
 }
 
+// SpanEventOption is a configuration option for AddEvent. It is aliased in order
+// to help godoc group all the functions returning it together. It is considered
+// more correct to refer to it as the type as the origin, ddtrace.SpanEventOption.
+type SpanEventOption = ddtrace.SpanEventOption
+
+// WithSpanEventTimestamp sets the given time as the time at which the event occurred.
+// By default, the current time is used.
+func WithSpanEventTimestamp(t time.Time) SpanEventOption {
+	return func(cfg *ddtrace.SpanEventConfig) {
+		cfg.Time = t
+	}
+}
+
+// WithSpanEventAttributes sets the given key/value pairs as attributes of the event.
+// This option may be used multiple times.
+func WithSpanEventAttributes(attributes map[string]interface{}) SpanEventOption {
+	return func(cfg *ddtrace.SpanEventConfig) {
+		if cfg.Attributes == nil {
+			cfg.Attributes = make(map[string]interface{}, len(attributes))
+		}
+		for k, v := range attributes {
+			cfg.Attributes[k] = v
+		}
+	}
+}
+
 // UserMonitoringConfig is used to configure what is used to identify a user.
 // This configuration can be set by combining one or several UserMonitoringOption with a call to SetUser().
 type UserMonitoringConfig struct {
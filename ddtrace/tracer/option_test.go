@@ -215,6 +215,29 @@ func TestLoadAgentFeatures(t *testing.T) {
 		assert.True(t, cfg.agent.HasFlag("b"))
 	})
 
+	t.Run("extended-stats", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"endpoints":["/v0.6/stats"],"feature_flags":["peer_tags_aggregation","compute_stats_by_span_kind"],"peer_tags":["db.system","rpc.service"]}`))
+		}))
+		defer srv.Close()
+		cfg := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.True(t, cfg.agent.SpanKindStats)
+		assert.Equal(t, []string{"peer.service", "db.system", "out.host", "rpc.service"}, cfg.agent.PeerTags)
+
+		cfg = newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")), WithStatsPeerTags("peer.service"))
+		assert.Equal(t, []string{"peer.service", "db.system", "rpc.service"}, cfg.agent.PeerTags)
+	})
+
+	t.Run("no-extended-stats", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"endpoints":["/v0.6/stats"],"peer_tags":["db.system"]}`))
+		}))
+		defer srv.Close()
+		cfg := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.False(t, cfg.agent.SpanKindStats)
+		assert.Empty(t, cfg.agent.PeerTags)
+	})
+
 	t.Run("discovery", func(t *testing.T) {
 		defer func(old string) { os.Setenv("DD_TRACE_FEATURES", old) }(os.Getenv("DD_TRACE_FEATURES"))
 		os.Setenv("DD_TRACE_FEATURES", "discovery")
@@ -896,6 +919,38 @@ func TestGlobalTag(t *testing.T) {
 	assert.Contains(t, statsTags(&c), "k:v")
 }
 
+func TestWithHeaderTags(t *testing.T) {
+	defer globalconfig.ClearHeaderTags()
+
+	t.Run("option", func(t *testing.T) {
+		c := newConfig(WithHeaderTags([]string{"X-Request-Id:req.id", " User-Agent ", ""}))
+		assert.Equal(t, []string{"X-Request-Id:req.id", " User-Agent "}, c.headerAsTags)
+		tag, ok := globalconfig.HeaderTag("x-request-id")
+		assert.True(t, ok)
+		assert.Equal(t, "req.id", tag)
+		tag, ok = globalconfig.HeaderTag("user-agent")
+		assert.True(t, ok)
+		assert.Equal(t, "", tag)
+		assert.Equal(t, 2, globalconfig.HeaderTagsLen())
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_HEADER_TAGS", "X-Request-Id:req.id,Content-Type")
+		newConfig()
+		assert.Equal(t, 2, globalconfig.HeaderTagsLen())
+		_, ok := globalconfig.HeaderTag("content-type")
+		assert.True(t, ok)
+	})
+
+	t.Run("override-env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_HEADER_TAGS", "X-Request-Id:req.id,Content-Type")
+		newConfig(WithHeaderTags([]string{"Accept"}))
+		assert.Equal(t, 1, globalconfig.HeaderTagsLen())
+		_, ok := globalconfig.HeaderTag("accept")
+		assert.True(t, ok)
+	})
+}
+
 func TestWithHostname(t *testing.T) {
 	t.Run("WithHostname", func(t *testing.T) {
 		assert := assert.New(t)
@@ -970,3 +1025,73 @@ func TestHostnameDisabled(t *testing.T) {
 		assert.False(t, c.enableHostnameDetection)
 	})
 }
+
+func TestPartialFlushing(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		c := newConfig()
+		assert.False(t, c.partialFlushEnabled)
+		assert.Equal(t, partialFlushMinSpansDefault, c.partialFlushMinSpans)
+	})
+	t.Run("Disabled-DefaultMinSpans", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_ENABLED", "false")
+		c := newConfig()
+		assert.False(t, c.partialFlushEnabled)
+		assert.Equal(t, partialFlushMinSpansDefault, c.partialFlushMinSpans)
+	})
+	t.Run("Default-SetMinSpans", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS", "10")
+		c := newConfig()
+		assert.False(t, c.partialFlushEnabled)
+		assert.Equal(t, 10, c.partialFlushMinSpans)
+	})
+	t.Run("Enabled-DefaultMinSpans", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_ENABLED", "true")
+		c := newConfig()
+		assert.True(t, c.partialFlushEnabled)
+		assert.Equal(t, partialFlushMinSpansDefault, c.partialFlushMinSpans)
+	})
+	t.Run("Enabled-SetMinSpans", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_ENABLED", "true")
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS", "10")
+		c := newConfig()
+		assert.True(t, c.partialFlushEnabled)
+		assert.Equal(t, 10, c.partialFlushMinSpans)
+	})
+	t.Run("Enabled-SetMinSpansNegative", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_ENABLED", "true")
+		t.Setenv("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS", "-1")
+		c := newConfig()
+		assert.True(t, c.partialFlushEnabled)
+		assert.Equal(t, partialFlushMinSpansDefault, c.partialFlushMinSpans)
+	})
+	t.Run("WithPartialFlushOption", func(t *testing.T) {
+		c := newConfig()
+		WithPartialFlushing(20)(c)
+		assert.True(t, c.partialFlushEnabled)
+		assert.Equal(t, 20, c.partialFlushMinSpans)
+	})
+	t.Run("WithPartialFlushOptionInvalid", func(t *testing.T) {
+		c := newConfig()
+		WithPartialFlushing(traceMaxSize)(c)
+		assert.True(t, c.partialFlushEnabled)
+		assert.Equal(t, partialFlushMinSpansDefault, c.partialFlushMinSpans)
+	})
+}
+
+func TestStatsPeerTagsConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		assert.Equal(t, []string{"peer.service", "db.system", "out.host"}, newConfig().statsPeerTags)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_STATS_PEER_TAGS", "peer.service, messaging.system,")
+		assert.Equal(t, []string{"peer.service", "messaging.system"}, newConfig().statsPeerTags)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		t.Setenv("DD_TRACE_STATS_PEER_TAGS", "peer.service")
+		c := newConfig(WithStatsPeerTags("db.system", "out.host"))
+		assert.Equal(t, []string{"db.system", "out.host"}, c.statsPeerTags)
+		assert.Equal(t, []string{"peer.service", "db.system", "out.host"}, defaultStatsPeerTags)
+	})
+}
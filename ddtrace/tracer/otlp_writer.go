@@ -0,0 +1,407 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// otlpDefaultEndpoint is the default OTLP/HTTP traces endpoint of an OpenTelemetry Collector.
+	otlpDefaultEndpoint = "http://localhost:4318/v1/traces"
+
+	// otlpTracesPath is the path of the OTLP/HTTP traces endpoint, relative to the base
+	// endpoint set in OTEL_EXPORTER_OTLP_ENDPOINT.
+	otlpTracesPath = "/v1/traces"
+)
+
+// otlpTraceWriter converts finished spans to OTLP and sends them as an
+// ExportTraceServiceRequest protobuf message to an OTLP/HTTP endpoint, such as
+// the one of an OpenTelemetry Collector.
+//
+// The messages are encoded by hand following the OTLP protobuf definitions found at
+// https://github.com/open-telemetry/opentelemetry-proto/tree/main/opentelemetry/proto,
+// in order to avoid depending on the generated code.
+type otlpTraceWriter struct {
+	// config holds the tracer configuration
+	config *config
+
+	// client is used to send the payloads to endpoint
+	client *http.Client
+
+	// endpoint is the URL of the OTLP/HTTP traces endpoint
+	endpoint string
+
+	// spans holds the encoded Span messages buffered for the next payload, by service
+	spans map[string][]byte
+
+	// size and count hold the size of the buffered spans and the number of buffered traces
+	size, count int
+
+	// climit limits the number of concurrent outgoing connections
+	climit chan struct{}
+
+	// wg waits for all uploads to finish
+	wg sync.WaitGroup
+
+	// statsd is used to send metrics
+	statsd statsdClient
+}
+
+// otlpEndpointFromEnv returns the OTLP/HTTP traces endpoint configured through the
+// standard OpenTelemetry environment variables, or the default one.
+func otlpEndpointFromEnv() string {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		return v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		return strings.TrimSuffix(v, "/") + otlpTracesPath
+	}
+	return otlpDefaultEndpoint
+}
+
+func newOTLPTraceWriter(c *config, statsdClient statsdClient) *otlpTraceWriter {
+	client := c.httpClient
+	if strings.HasPrefix(c.agentURL.Host, "UDS_") {
+		// the configured client dials the agent's socket, which can't reach the collector
+		client = defaultClient
+	}
+	return &otlpTraceWriter{
+		config:   c,
+		client:   client,
+		endpoint: c.otlpEndpoint,
+		spans:    make(map[string][]byte),
+		climit:   make(chan struct{}, concurrentConnectionLimit),
+		statsd:   statsdClient,
+	}
+}
+
+func (h *otlpTraceWriter) add(trace []*span) {
+	// The agent drops the traces rejected by the samplers, which the tracer sends
+	// to it for stats computation. A collector doesn't, so they are dropped here.
+	trace = sampledSpans(trace)
+	if len(trace) == 0 {
+		return
+	}
+	for _, s := range trace {
+		n := len(h.spans[s.Service])
+		h.spans[s.Service] = protowire.AppendTag(h.spans[s.Service], 2, protowire.BytesType) // ScopeSpans.spans
+		h.spans[s.Service] = protowire.AppendBytes(h.spans[s.Service], encodeOTLPSpan(s))
+		h.size += len(h.spans[s.Service]) - n
+	}
+	h.count++
+	if h.size > payloadSizeLimit {
+		h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:size"}, 1)
+		h.flush()
+	}
+}
+
+// sampledSpans returns the spans of the trace chunk to be exported: all of them
+// when the trace is kept or not yet sampled, and only those kept by single span
+// sampling rules when the trace is dropped.
+func sampledSpans(trace []*span) []*span {
+	for _, s := range trace {
+		p, ok := s.Metrics[keySamplingPriority]
+		if !ok {
+			continue
+		}
+		if p > 0 {
+			return trace
+		}
+		var kept []*span
+		for _, s := range trace {
+			if _, ok := s.Metrics[keySpanSamplingMechanism]; ok {
+				kept = append(kept, s)
+			}
+		}
+		return kept
+	}
+	return trace
+}
+
+func (h *otlpTraceWriter) stop() {
+	h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:shutdown"}, 1)
+	h.flush()
+	h.wg.Wait()
+}
+
+// flush will send any currently buffered traces to the OTLP endpoint.
+func (h *otlpTraceWriter) flush() {
+	if h.count == 0 {
+		return
+	}
+	body := h.encodeRequest()
+	count := h.count
+	h.spans = make(map[string][]byte)
+	h.size, h.count = 0, 0
+
+	h.wg.Add(1)
+	h.climit <- struct{}{}
+	go func() {
+		defer func(start time.Time) {
+			<-h.climit
+			h.wg.Done()
+			h.statsd.Timing("datadog.tracer.flush_duration", time.Since(start), nil, 1)
+		}(time.Now())
+
+		var err error
+		for attempt := 0; attempt <= h.config.sendRetries; attempt++ {
+			log.Debug("Sending OTLP payload: size: %d traces: %d\n", len(body), count)
+			if err = h.send(body); err == nil {
+				log.Debug("sent traces after %d attempts", attempt+1)
+				h.statsd.Count("datadog.tracer.flush_bytes", int64(len(body)), nil, 1)
+				h.statsd.Count("datadog.tracer.flush_traces", int64(count), nil, 1)
+				return
+			}
+			log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
+			time.Sleep(time.Millisecond)
+		}
+		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
+		log.Error("lost %d traces: %v", count, err)
+	}()
+}
+
+// send posts the given ExportTraceServiceRequest to the OTLP endpoint.
+func (h *otlpTraceWriter) send(body []byte) error {
+	req, err := http.NewRequest("POST", h.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if code := resp.StatusCode; code >= 400 {
+		// error, check the body for context information and
+		// return a nice error.
+		msg := make([]byte, 1000)
+		n, _ := resp.Body.Read(msg)
+		txt := http.StatusText(code)
+		if n > 0 {
+			return fmt.Errorf("%s (Status: %s)", msg[:n], txt)
+		}
+		return fmt.Errorf("%s", txt)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// encodeRequest encodes the buffered spans into an ExportTraceServiceRequest,
+// with one ResourceSpans per service.
+func (h *otlpTraceWriter) encodeRequest() []byte {
+	services := make([]string, 0, len(h.spans))
+	for service := range h.spans {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var scope []byte // InstrumentationScope
+	scope = appendOTLPString(scope, 1, "dd-trace-go")
+	scope = appendOTLPString(scope, 2, version.Tag)
+
+	var req []byte // ExportTraceServiceRequest
+	for _, service := range services {
+		var resource []byte // Resource
+		resource = appendOTLPKeyValue(resource, 1, "service.name", service)
+		if h.config.env != "" {
+			resource = appendOTLPKeyValue(resource, 1, "deployment.environment", h.config.env)
+		}
+		if h.config.version != "" {
+			resource = appendOTLPKeyValue(resource, 1, "service.version", h.config.version)
+		}
+		resource = appendOTLPKeyValue(resource, 1, "telemetry.sdk.name", "dd-trace-go")
+		resource = appendOTLPKeyValue(resource, 1, "telemetry.sdk.language", "go")
+		resource = appendOTLPKeyValue(resource, 1, "telemetry.sdk.version", version.Tag)
+
+		var scopeSpans []byte // ScopeSpans
+		scopeSpans = appendOTLPMessage(scopeSpans, 1, scope)
+		scopeSpans = append(scopeSpans, h.spans[service]...)
+
+		var resourceSpans []byte // ResourceSpans
+		resourceSpans = appendOTLPMessage(resourceSpans, 1, resource)
+		resourceSpans = appendOTLPMessage(resourceSpans, 2, scopeSpans)
+
+		req = appendOTLPMessage(req, 1, resourceSpans)
+	}
+	return req
+}
+
+// otlpSpanKinds maps the values of the span.kind tag to OTLP's Span.SpanKind.
+var otlpSpanKinds = map[string]uint64{
+	ext.SpanKindInternal: 1,
+	ext.SpanKindServer:   2,
+	ext.SpanKindClient:   3,
+	ext.SpanKindProducer: 4,
+	ext.SpanKindConsumer: 5,
+}
+
+// encodeOTLPSpan encodes s as an OTLP Span message. The operation name of s is
+// used as the name of the span, while its resource and type are added as the
+// resource.name and span.type attributes.
+func encodeOTLPSpan(s *span) []byte {
+	var b []byte
+	b = appendOTLPBytes(b, 1, otlpTraceID(s))
+	b = appendOTLPBytes(b, 2, otlpSpanID(s.SpanID))
+	if s.ParentID != 0 {
+		b = appendOTLPBytes(b, 4, otlpSpanID(s.ParentID))
+	}
+	b = appendOTLPString(b, 5, s.Name)
+	if kind, ok := otlpSpanKinds[s.Meta[ext.SpanKind]]; ok {
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, kind)
+	}
+	b = protowire.AppendTag(b, 7, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start))
+	b = protowire.AppendTag(b, 8, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start+s.Duration))
+
+	b = appendOTLPKeyValue(b, 9, ext.ResourceName, s.Resource)
+	if s.Type != "" {
+		b = appendOTLPKeyValue(b, 9, ext.SpanType, s.Type)
+	}
+	for k, v := range s.Meta {
+		if k == keySpanEvents {
+			// events are encoded natively below
+			continue
+		}
+		b = appendOTLPKeyValue(b, 9, k, v)
+	}
+	for k, v := range s.Metrics {
+		b = appendOTLPKeyValue(b, 9, k, v)
+	}
+
+	for _, e := range s.events {
+		var event []byte // Span.Event
+		event = protowire.AppendTag(event, 1, protowire.Fixed64Type)
+		event = protowire.AppendFixed64(event, e.TimeUnixNano)
+		event = appendOTLPString(event, 2, e.Name)
+		for k, v := range e.Attributes {
+			event = appendOTLPKeyValue(event, 3, k, v)
+		}
+		b = appendOTLPMessage(b, 11, event)
+	}
+	for _, l := range s.SpanLinks {
+		var link []byte // Span.Link
+		link = appendOTLPBytes(link, 1, otlpTraceIDFromParts(l.TraceIDHigh, l.TraceID))
+		link = appendOTLPBytes(link, 2, otlpSpanID(l.SpanID))
+		if l.Tracestate != "" {
+			link = appendOTLPString(link, 3, l.Tracestate)
+		}
+		for k, v := range l.Attributes {
+			link = appendOTLPKeyValue(link, 4, k, v)
+		}
+		if l.Flags != 0 {
+			link = protowire.AppendTag(link, 6, protowire.Fixed32Type)
+			link = protowire.AppendFixed32(link, l.Flags)
+		}
+		b = appendOTLPMessage(b, 13, link)
+	}
+	if s.Error != 0 {
+		var status []byte // Status
+		if msg := s.Meta[ext.ErrorMsg]; msg != "" {
+			status = appendOTLPString(status, 2, msg)
+		}
+		status = protowire.AppendTag(status, 3, protowire.VarintType)
+		status = protowire.AppendVarint(status, 2) // STATUS_CODE_ERROR
+		b = appendOTLPMessage(b, 15, status)
+	}
+	return b
+}
+
+// otlpTraceID returns the 128-bit trace ID of s.
+func otlpTraceID(s *span) []byte {
+	if s.context != nil {
+		id := s.context.traceID
+		return id[:]
+	}
+	return otlpTraceIDFromParts(0, s.TraceID)
+}
+
+func otlpTraceIDFromParts(high, low uint64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], high)
+	binary.BigEndian.PutUint64(b[8:], low)
+	return b
+}
+
+func otlpSpanID(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+func appendOTLPString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendOTLPBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendOTLPMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	return appendOTLPBytes(b, num, msg)
+}
+
+// appendOTLPKeyValue appends a KeyValue message holding key and the AnyValue
+// representation of v as field num of b.
+func appendOTLPKeyValue(b []byte, num protowire.Number, key string, v interface{}) []byte {
+	var val []byte // AnyValue
+	switch v := v.(type) {
+	case string:
+		val = appendOTLPString(val, 1, v)
+	case bool:
+		val = protowire.AppendTag(val, 2, protowire.VarintType)
+		val = protowire.AppendVarint(val, protowire.EncodeBool(v))
+	case int:
+		val = appendOTLPInt(val, int64(v))
+	case int32:
+		val = appendOTLPInt(val, int64(v))
+	case int64:
+		val = appendOTLPInt(val, v)
+	case uint32:
+		val = appendOTLPInt(val, int64(v))
+	case float32:
+		val = appendOTLPDouble(val, float64(v))
+	case float64:
+		val = appendOTLPDouble(val, v)
+	default:
+		val = appendOTLPString(val, 1, fmt.Sprint(v))
+	}
+	var kv []byte // KeyValue
+	kv = appendOTLPString(kv, 1, key)
+	kv = appendOTLPMessage(kv, 2, val)
+	return appendOTLPMessage(b, num, kv)
+}
+
+func appendOTLPInt(b []byte, v int64) []byte {
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendOTLPDouble(b []byte, v float64) []byte {
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
@@ -0,0 +1,284 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// protoMessage holds the decoded fields of a protobuf message, by field number.
+// Length-delimited fields are held as []byte, varint and fixed64 fields as uint64
+// and fixed32 fields as uint32.
+type protoMessage map[protowire.Number][]interface{}
+
+func decodeProto(t *testing.T, b []byte) protoMessage {
+	m := make(protoMessage)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			v, n = protowire.ConsumeFixed32(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+// messages decodes all the message values of field num.
+func (m protoMessage) messages(t *testing.T, num protowire.Number) []protoMessage {
+	var msgs []protoMessage
+	for _, v := range m[num] {
+		msgs = append(msgs, decodeProto(t, v.([]byte)))
+	}
+	return msgs
+}
+
+// attributes decodes the KeyValue messages of field num into a map.
+func (m protoMessage) attributes(t *testing.T, num protowire.Number) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, kv := range m.messages(t, num) {
+		val := kv.messages(t, 2)[0]
+		var v interface{}
+		switch {
+		case len(val[1]) > 0:
+			v = string(val[1][0].([]byte))
+		case len(val[2]) > 0:
+			v = protowire.DecodeBool(val[2][0].(uint64))
+		case len(val[3]) > 0:
+			v = int64(val[3][0].(uint64))
+		case len(val[4]) > 0:
+			v = math.Float64frombits(val[4][0].(uint64))
+		}
+		attrs[string(kv[1][0].([]byte))] = v
+	}
+	return attrs
+}
+
+// otlpCollector returns a test server acting as an OTLP/HTTP collector, which fails
+// the first failures requests.
+func otlpCollector(failures int) (srv *httptest.Server, requests func() [][]byte) {
+	var (
+		mu   sync.Mutex
+		reqs [][]byte
+	)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != otlpTracesPath || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, body)
+		if len(reqs) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	return srv, func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs
+	}
+}
+
+func TestOTLPTraceWriter(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, requests := otlpCollector(0)
+		defer srv.Close()
+		var tg testStatsdClient
+		c := newConfig(WithOTLPExporter(srv.URL+otlpTracesPath), WithEnv("prod"), WithServiceVersion("1.2.3"))
+		w := newOTLPTraceWriter(c, &tg)
+
+		root := newSpan("http.request", "web", "GET /users", 2, 1, 0)
+		root.Start, root.Duration = 100, 50
+		root.Meta[ext.SpanKind] = ext.SpanKindServer
+		root.Meta[ext.ErrorMsg] = "boom"
+		root.Metrics["rate"] = 0.5
+		root.Error = 1
+		root.events = []ddtrace.SpanEvent{{Name: "exception", TimeUnixNano: 120, Attributes: map[string]interface{}{"count": 3}}}
+		root.SpanLinks = []ddtrace.SpanLink{{TraceID: 7, TraceIDHigh: 8, SpanID: 9, Flags: 1 | 1<<31}}
+		child := newSpan("db.query", "db", "SELECT 1", 3, 1, 2)
+		w.add([]*span{root, child})
+		w.stop()
+
+		reqs := requests()
+		require.Len(t, reqs, 1)
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.flush_traces"])
+		resourceSpans := decodeProto(t, reqs[0]).messages(t, 1)
+		require.Len(t, resourceSpans, 2)
+
+		spans := make(map[string]protoMessage)
+		for _, rs := range resourceSpans {
+			resource := rs.messages(t, 1)[0].attributes(t, 1)
+			assert.Equal("prod", resource["deployment.environment"])
+			assert.Equal("1.2.3", resource["service.version"])
+			scopeSpans := rs.messages(t, 2)[0]
+			assert.Equal("dd-trace-go", string(scopeSpans.messages(t, 1)[0][1][0].([]byte)))
+			spans[resource["service.name"].(string)] = scopeSpans.messages(t, 2)[0]
+		}
+
+		s := spans["web"]
+		require.NotNil(t, s)
+		assert.Equal(otlpTraceIDFromParts(0, 1), s[1][0])
+		assert.Equal(otlpSpanID(2), s[2][0])
+		assert.Empty(s[4])
+		assert.Equal("http.request", string(s[5][0].([]byte)))
+		assert.Equal(uint64(2), s[6][0])
+		assert.Equal(uint64(100), s[7][0])
+		assert.Equal(uint64(150), s[8][0])
+		attrs := s.attributes(t, 9)
+		assert.Equal("GET /users", attrs[ext.ResourceName])
+		assert.Equal(0.5, attrs["rate"])
+		event := s.messages(t, 11)[0]
+		assert.Equal(uint64(120), event[1][0])
+		assert.Equal("exception", string(event[2][0].([]byte)))
+		assert.Equal(int64(3), event.attributes(t, 3)["count"])
+		link := s.messages(t, 13)[0]
+		assert.Equal(otlpTraceIDFromParts(8, 7), link[1][0])
+		assert.Equal(otlpSpanID(9), link[2][0])
+		assert.Equal(uint32(1|1<<31), link[6][0])
+		status := s.messages(t, 15)[0]
+		assert.Equal("boom", string(status[2][0].([]byte)))
+		assert.Equal(uint64(2), status[3][0])
+
+		s = spans["db"]
+		require.NotNil(t, s)
+		assert.Equal(otlpSpanID(2), s[4][0])
+		assert.Empty(s[6])
+		assert.Empty(s[15])
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, requests := otlpCollector(0)
+		defer srv.Close()
+		var tg testStatsdClient
+		c := newConfig(WithOTLPExporter(srv.URL + otlpTracesPath))
+		w := newOTLPTraceWriter(c, &tg)
+
+		rejected := newSpan("http.request", "web", "GET /users", 1, 1, 0)
+		rejected.Metrics[keySamplingPriority] = ext.PriorityUserReject
+		w.add([]*span{rejected, newSpan("db.query", "db", "SELECT 1", 2, 1, 1)})
+		auto := newSpan("http.request", "web", "GET /users", 3, 2, 0)
+		auto.Metrics[keySamplingPriority] = ext.PriorityAutoReject
+		w.add([]*span{auto})
+		w.flush()
+		w.wg.Wait()
+		assert.Empty(requests())
+
+		// single span sampling keeps spans of dropped traces
+		kept := newSpan("db.query", "db", "SELECT 1", 5, 3, 4)
+		kept.Metrics[keySpanSamplingMechanism] = float64(samplernames.SingleSpan)
+		dropped := newSpan("http.request", "web", "GET /users", 4, 3, 0)
+		dropped.Metrics[keySamplingPriority] = ext.PriorityAutoReject
+		w.add([]*span{dropped, kept})
+		w.stop()
+
+		reqs := requests()
+		require.Len(t, reqs, 1)
+		resourceSpans := decodeProto(t, reqs[0]).messages(t, 1)
+		require.Len(t, resourceSpans, 1)
+		spans := resourceSpans[0].messages(t, 2)[0].messages(t, 2)
+		require.Len(t, spans, 1)
+		assert.Equal(otlpSpanID(5), spans[0][2][0])
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, requests := otlpCollector(2)
+		defer srv.Close()
+		var tg testStatsdClient
+		c := newConfig(WithOTLPExporter(srv.URL+otlpTracesPath), WithSendRetries(2))
+		w := newOTLPTraceWriter(c, &tg)
+
+		w.add([]*span{makeSpan(0)})
+		w.stop()
+
+		assert.Len(requests(), 3)
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.flush_traces"])
+		assert.Equal(int64(0), tg.Counts()["datadog.tracer.traces_dropped"])
+	})
+
+	t.Run("send-failed", func(t *testing.T) {
+		assert := assert.New(t)
+		srv, requests := otlpCollector(math.MaxInt32)
+		defer srv.Close()
+		var tg testStatsdClient
+		c := newConfig(WithOTLPExporter(srv.URL+otlpTracesPath), WithSendRetries(1))
+		w := newOTLPTraceWriter(c, &tg)
+
+		w.add([]*span{makeSpan(0)})
+		w.add([]*span{makeSpan(0)})
+		w.stop()
+
+		assert.Len(requests(), 2)
+		assert.Equal(int64(2), tg.Counts()["datadog.tracer.traces_dropped"])
+	})
+}
+
+func TestOTLPExporterConfig(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		c := newConfig()
+		assert.Empty(t, c.otlpEndpoint)
+		trc := newUnstartedTracer()
+		defer trc.statsd.Close()
+		assert.IsType(t, &agentTraceWriter{}, trc.traceWriter)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		c := newConfig()
+		assert.Equal(t, otlpDefaultEndpoint, c.otlpEndpoint)
+		trc := newUnstartedTracer()
+		defer trc.statsd.Close()
+		assert.IsType(t, &otlpTraceWriter{}, trc.traceWriter)
+	})
+
+	t.Run("env-endpoint", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+		c := newConfig()
+		assert.Equal(t, "http://collector:4318/v1/traces", c.otlpEndpoint)
+
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/custom")
+		c = newConfig()
+		assert.Equal(t, "http://collector:4318/custom", c.otlpEndpoint)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		c := newConfig(WithOTLPExporter(""))
+		assert.Equal(t, otlpDefaultEndpoint, c.otlpEndpoint)
+		c = newConfig(WithOTLPExporter("http://collector:4318/v1/traces"))
+		assert.Equal(t, "http://collector:4318/v1/traces", c.otlpEndpoint)
+	})
+}
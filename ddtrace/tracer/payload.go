@@ -8,9 +8,12 @@ package tracer
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"io"
 	"sync/atomic"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -57,6 +60,20 @@ type payload struct {
 
 	// reader is used for reading the contents of buf.
 	reader *bytes.Reader
+
+	// protocol specifies the version of the agent's trace intake protocol which
+	// the payload is encoded with (traceProtocolV04 or traceProtocolV05).
+	protocol float64
+
+	// strings holds the string table of v0.5 payloads; nil otherwise.
+	strings *stringTable
+
+	// scratch is reused when encoding v0.5 traces.
+	scratch []byte
+
+	// raw reports whether buf holds the complete encoded payload, header
+	// included, as created by newRawPayload.
+	raw bool
 }
 
 var _ io.Reader = (*payload)(nil)
@@ -64,14 +81,58 @@ var _ io.Reader = (*payload)(nil)
 // newPayload returns a ready to use payload.
 func newPayload() *payload {
 	p := &payload{
-		header: make([]byte, 8),
-		off:    8,
+		header:   make([]byte, 8),
+		off:      8,
+		protocol: traceProtocolV04,
+	}
+	return p
+}
+
+// newPayloadV05 returns a ready to use payload which encodes traces in the v0.5
+// format of the agent's trace intake. In this format, every string is stored once
+// in a string table and spans reference strings by their index in it:
+//
+//	[
+//		[string, ...],            // string table
+//		[[span, ...], ...],       // traces
+//	]
+//
+// where each span is an array of 12 elements: service, name, resource, trace_id,
+// span_id, parent_id, start, duration, error, meta, metrics and type.
+func newPayloadV05() *payload {
+	return &payload{
+		protocol: traceProtocolV05,
+		strings:  newStringTable(),
+	}
+}
+
+// newPayloadForProtocol returns a ready to use payload encoding traces using the
+// given version of the agent's trace intake protocol.
+func newPayloadForProtocol(protocol float64) *payload {
+	if protocol == traceProtocolV05 {
+		return newPayloadV05()
+	}
+	return newPayload()
+}
+
+// newRawPayload returns a payload holding data, the complete encoding of count
+// traces using the given protocol version, such as read from another payload.
+func newRawPayload(protocol float64, count int, data []byte) *payload {
+	p := &payload{
+		header:   []byte{},
+		count:    uint32(count),
+		protocol: protocol,
+		raw:      true,
 	}
+	p.buf.Write(data)
 	return p
 }
 
 // push pushes a new item into the stream.
 func (p *payload) push(t spanList) error {
+	if p.strings != nil {
+		return p.pushV05(t)
+	}
 	if err := msgp.Encode(&p.buf, t); err != nil {
 		return err
 	}
@@ -80,6 +141,20 @@ func (p *payload) push(t spanList) error {
 	return nil
 }
 
+// pushV05 pushes a new item into the stream of a v0.5 payload. The header, which
+// holds the string table, is only built once the payload is read.
+func (p *payload) pushV05(t spanList) error {
+	b := msgp.AppendArrayHeader(p.scratch[:0], uint32(len(t)))
+	for _, s := range t {
+		b = p.strings.appendSpan(b, s)
+	}
+	p.scratch = b[:0]
+	p.buf.Write(b)
+	atomic.AddUint32(&p.count, 1)
+	p.header = nil
+	return nil
+}
+
 // itemCount returns the number of items available in the srteam.
 func (p *payload) itemCount() int {
 	return int(atomic.LoadUint32(&p.count))
@@ -88,6 +163,10 @@ func (p *payload) itemCount() int {
 // size returns the payload size in bytes. After the first read the value becomes
 // inaccurate by up to 8 bytes.
 func (p *payload) size() int {
+	if p.strings != nil && p.header == nil {
+		// the header of v0.5 payloads is not built yet
+		return 1 + p.strings.size() + arrayHeaderSize(p.itemCount()) + p.buf.Len()
+	}
 	return p.buf.Len() + len(p.header) - p.off
 }
 
@@ -96,9 +175,9 @@ func (p *payload) size() int {
 // reuse the payload for another set of traces.
 func (p *payload) reset() {
 	p.updateHeader()
-	if p.reader != nil {
-		p.reader.Seek(0, 0)
-	}
+	// the reader is rebuilt on the next read, as buf may have grown since the
+	// last one
+	p.reader = nil
 }
 
 // clear empties the payload buffers.
@@ -117,6 +196,17 @@ const (
 // updateHeader updates the payload header based on the number of items currently
 // present in the stream.
 func (p *payload) updateHeader() {
+	if p.raw {
+		return
+	}
+	if p.strings != nil {
+		// v0.5: the header holds the string table and the traces array header.
+		h := msgp.AppendArrayHeader(p.header[:0], 2)
+		h = p.strings.append(h)
+		p.header = msgp.AppendArrayHeader(h, atomic.LoadUint32(&p.count))
+		p.off = 0
+		return
+	}
 	n := uint64(atomic.LoadUint32(&p.count))
 	switch {
 	case n <= 15:
@@ -140,6 +230,10 @@ func (p *payload) Close() error {
 
 // Read implements io.Reader. It reads from the msgpack-encoded stream.
 func (p *payload) Read(b []byte) (n int, err error) {
+	if p.header == nil {
+		// v0.5 payload which was pushed to since the header was last built
+		p.updateHeader()
+	}
 	if p.off < len(p.header) {
 		// reading header
 		n = copy(b, p.header[p.off:])
@@ -151,3 +245,108 @@ func (p *payload) Read(b []byte) (n int, err error) {
 	}
 	return p.reader.Read(b)
 }
+
+const (
+	// traceProtocolV04 is the version of the agent's trace intake protocol which
+	// encodes spans as msgpack maps.
+	traceProtocolV04 = 0.4
+
+	// traceProtocolV05 is the version of the agent's trace intake protocol which
+	// encodes spans as msgpack arrays referencing a string table.
+	traceProtocolV05 = 0.5
+)
+
+// keySpanLinks holds the JSON encoded span links of spans sent using the v0.5
+// protocol, which has no dedicated field for them.
+const keySpanLinks = "_dd.span_links"
+
+// stringTable deduplicates the strings of a v0.5 payload. The first string of
+// the table is always the empty string.
+type stringTable struct {
+	// index maps the strings of the table to their position in it.
+	index map[string]uint32
+
+	// buf holds the msgpack-encoded strings of the table, in order.
+	buf []byte
+}
+
+func newStringTable() *stringTable {
+	t := &stringTable{index: make(map[string]uint32)}
+	t.indexOf("")
+	return t
+}
+
+// indexOf returns the position of str in the table, adding it if needed.
+func (t *stringTable) indexOf(str string) uint32 {
+	if i, ok := t.index[str]; ok {
+		return i
+	}
+	i := uint32(len(t.index))
+	t.index[str] = i
+	t.buf = msgp.AppendString(t.buf, str)
+	return i
+}
+
+// size returns the size in bytes of the encoded table.
+func (t *stringTable) size() int {
+	return arrayHeaderSize(len(t.index)) + len(t.buf)
+}
+
+// append appends the encoded table to b.
+func (t *stringTable) append(b []byte) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(t.index)))
+	return append(b, t.buf...)
+}
+
+// appendSpan appends the v0.5 encoding of s to b, adding its strings to the table.
+func (t *stringTable) appendSpan(b []byte, s *span) []byte {
+	var links []byte
+	if len(s.SpanLinks) > 0 {
+		var err error
+		if links, err = json.Marshal(s.SpanLinks); err != nil {
+			log.Error("Error marshaling span links: %v", err)
+			links = nil
+		}
+	}
+	b = msgp.AppendArrayHeader(b, 12)
+	b = msgp.AppendUint32(b, t.indexOf(s.Service))
+	b = msgp.AppendUint32(b, t.indexOf(s.Name))
+	b = msgp.AppendUint32(b, t.indexOf(s.Resource))
+	b = msgp.AppendUint64(b, s.TraceID)
+	b = msgp.AppendUint64(b, s.SpanID)
+	b = msgp.AppendUint64(b, s.ParentID)
+	b = msgp.AppendInt64(b, s.Start)
+	b = msgp.AppendInt64(b, s.Duration)
+	b = msgp.AppendInt32(b, s.Error)
+	n := len(s.Meta)
+	if links != nil {
+		n++
+	}
+	b = msgp.AppendMapHeader(b, uint32(n))
+	for k, v := range s.Meta {
+		b = msgp.AppendUint32(b, t.indexOf(k))
+		b = msgp.AppendUint32(b, t.indexOf(v))
+	}
+	if links != nil {
+		b = msgp.AppendUint32(b, t.indexOf(keySpanLinks))
+		b = msgp.AppendUint32(b, t.indexOf(string(links)))
+	}
+	b = msgp.AppendMapHeader(b, uint32(len(s.Metrics)))
+	for k, v := range s.Metrics {
+		b = msgp.AppendUint32(b, t.indexOf(k))
+		b = msgp.AppendFloat64(b, v)
+	}
+	return msgp.AppendUint32(b, t.indexOf(s.Type))
+}
+
+// arrayHeaderSize returns the size in bytes of the msgpack header of an array of n items.
+func arrayHeaderSize(n int) int {
+	switch {
+	case n <= 15:
+		return 1
+	case n <= 1<<16-1:
+		return 3
+	default:
+		return 5
+	}
+}
@@ -8,13 +8,18 @@ package tracer
 import (
 	"bytes"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tinylib/msgp/msgp"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 )
 
 var fixedTime = now()
@@ -108,3 +113,289 @@ func benchmarkPayloadThroughput(count int) func(*testing.B) {
 		}
 	}
 }
+
+// decodeV05 decodes a v0.5 payload into the traces it holds.
+func decodeV05(t *testing.T, data []byte) spanLists {
+	r := msgp.NewReader(bytes.NewReader(data))
+	n, err := r.ReadArrayHeader()
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), n)
+
+	n, err = r.ReadArrayHeader()
+	require.NoError(t, err)
+	table := make([]string, n)
+	for i := range table {
+		table[i], err = r.ReadString()
+		require.NoError(t, err)
+	}
+	require.Equal(t, "", table[0])
+	str := func() string {
+		i, err := r.ReadUint32()
+		require.NoError(t, err)
+		require.Less(t, int(i), len(table))
+		return table[i]
+	}
+
+	ntraces, err := r.ReadArrayHeader()
+	require.NoError(t, err)
+	traces := make(spanLists, ntraces)
+	for i := range traces {
+		nspans, err := r.ReadArrayHeader()
+		require.NoError(t, err)
+		traces[i] = make(spanList, nspans)
+		for j := range traces[i] {
+			n, err := r.ReadArrayHeader()
+			require.NoError(t, err)
+			require.Equal(t, uint32(12), n)
+			s := &span{Service: str(), Name: str(), Resource: str()}
+			s.TraceID, err = r.ReadUint64()
+			require.NoError(t, err)
+			s.SpanID, err = r.ReadUint64()
+			require.NoError(t, err)
+			s.ParentID, err = r.ReadUint64()
+			require.NoError(t, err)
+			s.Start, err = r.ReadInt64()
+			require.NoError(t, err)
+			s.Duration, err = r.ReadInt64()
+			require.NoError(t, err)
+			s.Error, err = r.ReadInt32()
+			require.NoError(t, err)
+			n, err = r.ReadMapHeader()
+			require.NoError(t, err)
+			s.Meta = make(map[string]string, n)
+			for ; n > 0; n-- {
+				k := str()
+				s.Meta[k] = str()
+			}
+			n, err = r.ReadMapHeader()
+			require.NoError(t, err)
+			s.Metrics = make(map[string]float64, n)
+			for ; n > 0; n-- {
+				k := str()
+				s.Metrics[k], err = r.ReadFloat64()
+				require.NoError(t, err)
+			}
+			s.Type = str()
+			traces[i][j] = s
+		}
+	}
+	_, err = r.R.Peek(1)
+	require.Equal(t, io.EOF, err, "unexpected trailing data")
+	return traces
+}
+
+func TestPayloadV05(t *testing.T) {
+	t.Run("integrity", func(t *testing.T) {
+		for _, n := range []int{10, 1 << 10} {
+			t.Run(strconv.Itoa(n), func(t *testing.T) {
+				assert := assert.New(t)
+				p := newPayloadV05()
+				lists := make(spanLists, n)
+				for i := 0; i < n; i++ {
+					list := newSpanList(i%5 + 1)
+					lists[i] = list
+					p.push(list)
+				}
+				size := p.size()
+				assert.Equal(n, p.itemCount())
+
+				got, err := io.ReadAll(p)
+				assert.NoError(err)
+				assert.Equal(size, len(got))
+				traces := decodeV05(t, got)
+				require.Len(t, traces, n)
+				for i := range lists {
+					require.Len(t, traces[i], len(lists[i]))
+					for j, want := range lists[i] {
+						s := traces[i][j]
+						assert.Equal(want.Name, s.Name)
+						assert.Equal(want.Service, s.Service)
+						assert.Equal(want.Resource, s.Resource)
+						assert.Equal(want.Type, s.Type)
+						assert.Equal(want.TraceID, s.TraceID)
+						assert.Equal(want.SpanID, s.SpanID)
+						assert.Equal(want.ParentID, s.ParentID)
+						assert.Equal(want.Start, s.Start)
+						assert.Equal(want.Duration, s.Duration)
+						assert.Equal(want.Error, s.Error)
+						assert.Equal(want.Meta, s.Meta)
+						assert.Equal(want.Metrics, s.Metrics)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("span-links", func(t *testing.T) {
+		p := newPayloadV05()
+		s := newBasicSpan("linked")
+		s.SpanLinks = []ddtrace.SpanLink{{TraceID: 1, SpanID: 2, Attributes: map[string]string{"key": "value"}}}
+		p.push(spanList{s})
+		got, err := io.ReadAll(p)
+		require.NoError(t, err)
+		traces := decodeV05(t, got)
+		assert.JSONEq(t, `[{"trace_id":1,"span_id":2,"attributes":{"key":"value"}}]`, traces[0][0].Meta[keySpanLinks])
+	})
+
+	t.Run("push-after-read", func(t *testing.T) {
+		assert := assert.New(t)
+		p := newPayloadV05()
+		p.push(newSpanList(1))
+		_, err := io.ReadAll(p)
+		assert.NoError(err)
+		p.push(newSpanList(2))
+		p.reset()
+		size := p.size()
+		got, err := io.ReadAll(p)
+		assert.NoError(err)
+		assert.Equal(size, len(got))
+		traces := decodeV05(t, got)
+		assert.Len(traces, 2)
+		assert.Len(traces[1], 2)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		assert := assert.New(t)
+		p := newPayloadV05()
+		p.push(newSpanList(3))
+		first, err := io.ReadAll(p)
+		assert.NoError(err)
+		p.reset()
+		second, err := io.ReadAll(p)
+		assert.NoError(err)
+		assert.Equal(first, second)
+	})
+}
+
+func TestRawPayload(t *testing.T) {
+	for _, protocol := range []float64{traceProtocolV04, traceProtocolV05} {
+		t.Run(strconv.FormatFloat(protocol, 'f', -1, 64), func(t *testing.T) {
+			assert := assert.New(t)
+			p := newPayloadForProtocol(protocol)
+			p.push(newSpanList(2))
+			p.push(newSpanList(3))
+			data, err := io.ReadAll(p)
+			assert.NoError(err)
+
+			raw := newRawPayload(protocol, p.itemCount(), data)
+			assert.Equal(protocol, raw.protocol)
+			assert.Equal(2, raw.itemCount())
+			assert.Equal(len(data), raw.size())
+			got, err := io.ReadAll(raw)
+			assert.NoError(err)
+			assert.Equal(data, got)
+			raw.reset()
+			got, err = io.ReadAll(raw)
+			assert.NoError(err)
+			assert.Equal(data, got)
+		})
+	}
+}
+
+func TestPayloadProtocolTransport(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, r.Header.Get("Content-Length"), strconv.Itoa(len(body)))
+		assert.Equal(t, "1", r.Header.Get(traceCountHeader))
+	}))
+	defer srv.Close()
+	transport := newHTTPTransport(srv.URL, defaultClient)
+	for _, protocol := range []float64{traceProtocolV04, traceProtocolV05} {
+		p := newPayloadForProtocol(protocol)
+		p.push(newSpanList(1))
+		rc, err := transport.send(p)
+		require.NoError(t, err)
+		rc.Close()
+	}
+	assert.Equal(t, []string{"/v0.4/traces", "/v0.5/traces"}, paths)
+}
+
+func TestTraceProtocolConfig(t *testing.T) {
+	agent := func(endpoints string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"endpoints":` + endpoints + `}`))
+		}))
+	}
+
+	t.Run("default", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces","/v0.5/traces"]`)
+		defer srv.Close()
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.True(t, c.agent.TracesV05)
+		assert.Equal(t, traceProtocolV05, c.traceProtocol)
+		w := newAgentTraceWriter(c, nil, &testStatsdClient{})
+		assert.Equal(t, traceProtocolV05, w.payload.protocol)
+	})
+
+	t.Run("default-unsupported", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces"]`)
+		defer srv.Close()
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.Equal(t, traceProtocolV04, c.traceProtocol)
+	})
+
+	t.Run("no-agent", func(t *testing.T) {
+		c := newConfig(WithAgentAddr("localhost:1"))
+		assert.Equal(t, traceProtocolV04, c.traceProtocol)
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces","/v0.5/traces"]`)
+		defer srv.Close()
+		t.Setenv("DD_TRACE_AGENT_PROTOCOL_VERSION", "0.4")
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.Equal(t, traceProtocolV04, c.traceProtocol)
+	})
+
+	t.Run("supported", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces","/v0.5/traces"]`)
+		defer srv.Close()
+		t.Setenv("DD_TRACE_AGENT_PROTOCOL_VERSION", "0.5")
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.Equal(t, traceProtocolV05, c.traceProtocol)
+		w := newAgentTraceWriter(c, nil, &testStatsdClient{})
+		assert.Equal(t, traceProtocolV05, w.payload.protocol)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces"]`)
+		defer srv.Close()
+		t.Setenv("DD_TRACE_AGENT_PROTOCOL_VERSION", "0.5")
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.False(t, c.agent.TracesV05)
+		assert.Equal(t, traceProtocolV04, c.traceProtocol)
+	})
+
+	t.Run("env-invalid", func(t *testing.T) {
+		srv := agent(`["/v0.4/traces","/v0.5/traces"]`)
+		defer srv.Close()
+		t.Setenv("DD_TRACE_AGENT_PROTOCOL_VERSION", "0.7")
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.Equal(t, traceProtocolV05, c.traceProtocol)
+	})
+}
+
+func BenchmarkPayloadEncoding(b *testing.B) {
+	for _, protocol := range []float64{traceProtocolV04, traceProtocolV05} {
+		b.Run(strconv.FormatFloat(protocol, 'f', -1, 64), func(b *testing.B) {
+			trace := newSpanList(5)
+			for _, s := range trace {
+				s.Meta["http.url"] = "http://example.com/users/42"
+				s.Meta["component"] = "net/http"
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			var size int
+			for i := 0; i < b.N; i++ {
+				p := newPayloadForProtocol(protocol)
+				for j := 0; j < 100; j++ {
+					p.push(trace)
+				}
+				size = p.size()
+			}
+			b.ReportMetric(float64(size), "bytes/payload")
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// peerServiceSources lists the tags peer.service may be inferred from, in order of
+// precedence. The tags identifying the remote service itself come first, followed
+// by the tags identifying the remote host.
+var peerServiceSources = []string{
+	ext.DBInstance,
+	ext.DBName,
+	ext.MessagingDestination,
+	ext.RPCService,
+	ext.PeerHostname,
+	ext.TargetHost,
+}
+
+// setPeerService sets the peer.service tag of s, when possible, and applies the
+// peer service mappings of c. An explicit peer.service tag is always kept, while
+// client and producer spans without one have it inferred from other tags when
+// c.peerServiceDefaultsEnabled is set. On client and producer spans, the tag the
+// value originates from is stored in the _dd.peer.service.source tag. Caller must
+// hold the lock of s.
+func setPeerService(s *span, c *config) {
+	kind := s.Meta[ext.SpanKind]
+	outbound := kind == ext.SpanKindClient || kind == ext.SpanKindProducer
+	if _, ok := s.Meta[ext.PeerService]; ok {
+		if outbound {
+			s.setMeta(keyPeerServiceSource, ext.PeerService)
+		}
+	} else {
+		if !c.peerServiceDefaultsEnabled || !outbound {
+			return
+		}
+		source := inferPeerService(s)
+		if source == "" {
+			return
+		}
+		s.setMeta(keyPeerServiceSource, source)
+	}
+	ps := s.Meta[ext.PeerService]
+	if to, ok := c.peerServiceMappings[ps]; ok {
+		s.setMeta(keyPeerServiceRemappedFrom, ps)
+		s.setMeta(ext.PeerService, to)
+	}
+}
+
+// inferPeerService sets the peer.service tag of s from the first non-empty tag of
+// peerServiceSources and returns the name of that tag, or "" if none was found.
+func inferPeerService(s *span) string {
+	for _, source := range peerServiceSources {
+		if v := s.Meta[source]; v != "" {
+			s.setMeta(ext.PeerService, v)
+			return source
+		}
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+func TestPeerService(t *testing.T) {
+	for name, tt := range map[string]struct {
+		opts       []StartOption
+		tags       map[string]interface{}
+		peer       string
+		source     string
+		remappedBy string
+	}{
+		"db": {
+			tags:   map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.DBInstance: "users", ext.TargetHost: "db.local"},
+			peer:   "users",
+			source: ext.DBInstance,
+		},
+		"messaging": {
+			tags:   map[string]interface{}{ext.SpanKind: ext.SpanKindProducer, ext.MessagingDestination: "orders"},
+			peer:   "orders",
+			source: ext.MessagingDestination,
+		},
+		"rpc": {
+			tags:   map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.RPCService: "pb.Greeter", ext.TargetHost: "10.0.0.1"},
+			peer:   "pb.Greeter",
+			source: ext.RPCService,
+		},
+		"host": {
+			tags:   map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.TargetHost: "api.example.com"},
+			peer:   "api.example.com",
+			source: ext.TargetHost,
+		},
+		"explicit": {
+			tags:   map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.PeerService: "billing", ext.TargetHost: "10.0.0.1"},
+			peer:   "billing",
+			source: ext.PeerService,
+		},
+		"explicit-server": {
+			tags: map[string]interface{}{ext.SpanKind: ext.SpanKindServer, ext.PeerService: "billing"},
+			peer: "billing",
+		},
+		"server": {
+			tags: map[string]interface{}{ext.SpanKind: ext.SpanKindServer, ext.TargetHost: "10.0.0.1"},
+		},
+		"no-kind": {
+			tags: map[string]interface{}{ext.DBInstance: "users"},
+		},
+		"no-source": {
+			tags: map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.DBInstance: ""},
+		},
+		"disabled": {
+			opts: []StartOption{WithPeerServiceDefaults(false)},
+			tags: map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.TargetHost: "api.example.com"},
+		},
+		"remapped": {
+			opts:       []StartOption{WithPeerServiceMapping("users", "users-db")},
+			tags:       map[string]interface{}{ext.SpanKind: ext.SpanKindClient, ext.DBInstance: "users"},
+			peer:       "users-db",
+			source:     ext.DBInstance,
+			remappedBy: "users",
+		},
+		"remapped-explicit": {
+			opts:       []StartOption{WithPeerServiceDefaults(false), WithPeerServiceMapping("billing", "payments")},
+			tags:       map[string]interface{}{ext.SpanKind: ext.SpanKindProducer, ext.PeerService: "billing"},
+			peer:       "payments",
+			source:     ext.PeerService,
+			remappedBy: "billing",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			opts := append([]StartOption{WithPeerServiceDefaults(true)}, tt.opts...)
+			tracer, _, _, stop := startTestTracer(t, opts...)
+			defer stop()
+
+			s := tracer.StartSpan("op").(*span)
+			for k, v := range tt.tags {
+				s.SetTag(k, v)
+			}
+			s.Finish()
+			if tt.peer == "" {
+				assert.Empty(s.Meta[ext.PeerService])
+				assert.NotContains(s.Meta, keyPeerServiceSource)
+				return
+			}
+			assert.Equal(tt.peer, s.Meta[ext.PeerService])
+			if tt.source == "" {
+				assert.NotContains(s.Meta, keyPeerServiceSource)
+			} else {
+				assert.Equal(tt.source, s.Meta[keyPeerServiceSource])
+			}
+			if tt.remappedBy == "" {
+				assert.NotContains(s.Meta, keyPeerServiceRemappedFrom)
+			} else {
+				assert.Equal(tt.remappedBy, s.Meta[keyPeerServiceRemappedFrom])
+			}
+		})
+	}
+}
+
+func TestPeerServiceConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c := newConfig()
+		assert.False(t, c.peerServiceDefaultsEnabled)
+		assert.Empty(t, c.peerServiceMappings)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PEER_SERVICE_DEFAULTS_ENABLED", "true")
+		t.Setenv("DD_TRACE_PEER_SERVICE_MAPPING", "users:users-db,billing:payments")
+		c := newConfig()
+		assert.True(t, c.peerServiceDefaultsEnabled)
+		assert.Equal(t, map[string]string{"users": "users-db", "billing": "payments"}, c.peerServiceMappings)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		t.Setenv("DD_TRACE_PEER_SERVICE_MAPPING", "users:users-db")
+		c := newConfig(WithPeerServiceMapping("users", "accounts"), WithPeerServiceMapping("cache", "redis"))
+		assert.Equal(t, map[string]string{"users": "accounts", "cache": "redis"}, c.peerServiceMappings)
+	})
+}
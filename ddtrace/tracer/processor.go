@@ -0,0 +1,238 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"strings"
+	"sync/atomic"
+
+	ginternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// SpanProcessor processes the finished spans of a trace before they are sent to
+// the agent. It can be used to centrally scrub, add or normalize tags, rename
+// resources, or drop spans.
+//
+// Processors are registered using WithSpanProcessor and run sequentially, in the
+// order they were registered, on the tracer's worker goroutine. They are invoked
+// once for every trace, or trace chunk when partial flushing is enabled, which
+// is going to be sent to the agent. Implementations should be fast, as a slow
+// processor delays all the traces queued after it. A panicking processor is
+// logged, and the trace is sent as it was before being processed.
+//
+// Client-side stats, when enabled, are computed when the spans finish, before
+// processors run: the changes made by processors, such as renamed resources or
+// dropped spans, are not reflected in them.
+type SpanProcessor interface {
+	// ProcessTrace is called with the spans of a finished trace. The spans may
+	// be modified in place and dropped using ProcessedSpan.Drop. The spans are
+	// only valid for the duration of the call and must not be retained.
+	ProcessTrace(spans []ProcessedSpan)
+}
+
+// SpanProcessorFunc is an adapter to allow the use of ordinary functions as
+// span processors.
+type SpanProcessorFunc func(spans []ProcessedSpan)
+
+// ProcessTrace calls fn(spans).
+func (fn SpanProcessorFunc) ProcessTrace(spans []ProcessedSpan) { fn(spans) }
+
+// ProcessedSpan gives a SpanProcessor read and write access to a finished span.
+type ProcessedSpan interface {
+	// SpanID returns the ID of the span.
+	SpanID() uint64
+
+	// TraceID returns the lower 64 bits of the ID of the trace the span belongs to.
+	TraceID() uint64
+
+	// ParentID returns the ID of the span's parent, or 0 for root spans.
+	ParentID() uint64
+
+	// OperationName returns the operation name of the span.
+	OperationName() string
+
+	// SetOperationName sets the operation name of the span.
+	SetOperationName(name string)
+
+	// Service returns the service name of the span.
+	Service() string
+
+	// SetService sets the service name of the span.
+	SetService(service string)
+
+	// Resource returns the resource name of the span.
+	Resource() string
+
+	// SetResource sets the resource name of the span.
+	SetResource(resource string)
+
+	// SpanType returns the type of the span.
+	SpanType() string
+
+	// Tag returns the value of the string tag at key, and whether it was found.
+	Tag(key string) (string, bool)
+
+	// SetTag sets the string tag key to value. Setting any of ext.SpanName,
+	// ext.ServiceName, ext.ResourceName or ext.SpanType updates the corresponding
+	// field of the span.
+	SetTag(key, value string)
+
+	// Metric returns the value of the numeric tag at key, and whether it was found.
+	Metric(key string) (float64, bool)
+
+	// SetMetric sets the numeric tag key to value.
+	SetMetric(key string, value float64)
+
+	// DeleteTag removes the string or numeric tag at key.
+	DeleteTag(key string)
+
+	// ForEachTag calls fn for every string tag of the span, stopping the
+	// iteration if fn returns false. fn may call SetTag or DeleteTag.
+	ForEachTag(fn func(key, value string) bool)
+
+	// Drop drops the span, preventing it from being sent to the agent.
+	Drop()
+
+	// Dropped reports whether the span was dropped by a processor.
+	Dropped() bool
+}
+
+// processedSpan implements ProcessedSpan. The span it wraps is finished and owned
+// by the worker goroutine, so it is accessed without locking.
+type processedSpan struct {
+	s       *span
+	dropped bool
+}
+
+var _ ProcessedSpan = (*processedSpan)(nil)
+
+func (p *processedSpan) SpanID() uint64               { return p.s.SpanID }
+func (p *processedSpan) TraceID() uint64              { return p.s.TraceID }
+func (p *processedSpan) ParentID() uint64             { return p.s.ParentID }
+func (p *processedSpan) OperationName() string        { return p.s.Name }
+func (p *processedSpan) SetOperationName(name string) { p.s.Name = name }
+func (p *processedSpan) Service() string              { return p.s.Service }
+func (p *processedSpan) SetService(service string)    { p.s.Service = service }
+func (p *processedSpan) Resource() string             { return p.s.Resource }
+func (p *processedSpan) SetResource(resource string)  { p.s.Resource = resource }
+func (p *processedSpan) SpanType() string             { return p.s.Type }
+func (p *processedSpan) SetTag(key, value string)     { p.s.setMeta(key, value) }
+func (p *processedSpan) Drop()                        { p.dropped = true }
+func (p *processedSpan) Dropped() bool                { return p.dropped }
+
+func (p *processedSpan) Tag(key string) (string, bool) {
+	v, ok := p.s.Meta[key]
+	return v, ok
+}
+
+func (p *processedSpan) Metric(key string) (float64, bool) {
+	v, ok := p.s.Metrics[key]
+	return v, ok
+}
+
+// SetMetric sets the numeric tag key to value. Unlike span.SetTag, it does not
+// alter the sampling decision of the trace, which has already been taken.
+func (p *processedSpan) SetMetric(key string, value float64) {
+	if p.s.Metrics == nil {
+		p.s.Metrics = make(map[string]float64, 1)
+	}
+	delete(p.s.Meta, key)
+	p.s.Metrics[key] = value
+}
+
+func (p *processedSpan) DeleteTag(key string) {
+	delete(p.s.Meta, key)
+	delete(p.s.Metrics, key)
+}
+
+func (p *processedSpan) ForEachTag(fn func(key, value string) bool) {
+	for k, v := range p.s.Meta {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// processTrace runs the configured span processors on the given trace, removing
+// from it any spans dropped by them.
+func (t *tracer) processTrace(trace *finishedTrace) {
+	if len(t.config.spanProcessors) == 0 || len(trace.spans) == 0 {
+		return
+	}
+	wrapped := make([]processedSpan, len(trace.spans))
+	spans := make([]ProcessedSpan, len(trace.spans))
+	for i, s := range trace.spans {
+		wrapped[i].s = s
+		spans[i] = &wrapped[i]
+	}
+	if !t.runProcessors(spans) {
+		// the spans are sent as they are, without being dropped
+		return
+	}
+	kept := trace.spans[:0]
+	for i := range wrapped {
+		if !wrapped[i].dropped {
+			kept = append(kept, wrapped[i].s)
+		}
+	}
+	dropped := len(trace.spans) - len(kept)
+	if dropped == 0 {
+		return
+	}
+	atomic.AddUint32(&t.processorDroppedSpans, uint32(dropped))
+	if len(kept) == 0 {
+		atomic.AddUint32(&t.processorDroppedTraces, 1)
+	} else if wrapped[0].dropped {
+		copyTraceTags(wrapped[0].s, kept[0])
+	}
+	trace.spans = kept
+}
+
+// runProcessors runs the span processors over spans, and reports whether they
+// all returned. A panicking processor is logged and counted, so that it doesn't
+// take the tracer's worker goroutine down.
+func (t *tracer) runProcessors(spans []ProcessedSpan) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Span processor panicked, sending the trace unprocessed: %v", r)
+			atomic.AddUint32(&t.processorPanics, 1)
+			ok = false
+		}
+	}()
+	for _, p := range t.config.spanProcessors {
+		p.ProcessTrace(spans)
+	}
+	return true
+}
+
+// copyTraceTags copies the trace level tags held by from, the first span of a
+// chunk, to the span to, which becomes the new first span of the chunk. Tags
+// already set on to are kept.
+func copyTraceTags(from, to *span) {
+	p := processedSpan{s: to}
+	for k, v := range from.Meta {
+		if _, ok := to.Meta[k]; ok || !isTraceTag(k) {
+			continue
+		}
+		p.SetTag(k, v)
+	}
+	if v, ok := from.Metrics[keySamplingPriority]; ok {
+		p.SetMetric(keySamplingPriority, v)
+	}
+}
+
+// isTraceTag reports whether the tag at key applies to the whole trace, and is
+// set on the first span of the chunks sent to the agent.
+func isTraceTag(key string) bool {
+	switch key {
+	case keyHostname, keyTracerHostname, keyPropagationError,
+		ginternal.TraceTagRepositoryURL, ginternal.TraceTagCommitSha, ginternal.TraceTagGoPath:
+		return true
+	}
+	// includes keyDecisionMaker and keyTraceID128
+	return strings.HasPrefix(key, "_dd.p.")
+}
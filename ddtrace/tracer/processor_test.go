@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanProcessor(t *testing.T) {
+	t.Run("modify", func(t *testing.T) {
+		assert := assert.New(t)
+		scrub := SpanProcessorFunc(func(spans []ProcessedSpan) {
+			for _, s := range spans {
+				s.ForEachTag(func(k, _ string) bool {
+					if strings.HasPrefix(k, "secret.") {
+						s.DeleteTag(k)
+					}
+					return true
+				})
+				s.SetTag("team", "apm")
+			}
+		})
+		rename := SpanProcessorFunc(func(spans []ProcessedSpan) {
+			for _, s := range spans {
+				// runs after scrub, which has already added the tag
+				if v, _ := s.Tag("team"); v == "apm" {
+					s.SetResource("GET /users/?")
+				}
+				s.SetMetric("processed", 1)
+			}
+		})
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(scrub, rename))
+		defer stop()
+
+		root := tracer.StartSpan("http.request", ResourceName("GET /users/42"), Tag("secret.token", "abc"))
+		tracer.StartSpan("db.query", ChildOf(root.Context()), Tag("secret.password", "abc")).Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		for _, s := range traces[0] {
+			assert.NotContains(s.Meta, "secret.token")
+			assert.NotContains(s.Meta, "secret.password")
+			assert.Equal("apm", s.Meta["team"])
+			assert.Equal("GET /users/?", s.Resource)
+			assert.Equal(1., s.Metrics["processed"])
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		assert := assert.New(t)
+		drop := SpanProcessorFunc(func(spans []ProcessedSpan) {
+			for _, s := range spans {
+				if s.OperationName() == "root" {
+					s.Drop()
+				}
+			}
+		})
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(drop))
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		root.(*span).context.trace.setPropagatingTag("_dd.p.team", "apm")
+		child := tracer.StartSpan("child", ChildOf(root.Context()))
+		child.Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 1)
+		assert.Equal(child.(*span).SpanID, traces[0][0].SpanID)
+		// the trace level tags are kept on the new first span
+		assert.Equal(1., traces[0][0].Metrics[keySamplingPriority])
+		assert.Equal("-1", traces[0][0].Meta[keyDecisionMaker])
+		assert.Equal("apm", traces[0][0].Meta["_dd.p.team"])
+		assert.Equal(uint32(1), tracer.processorDroppedSpans)
+		assert.Equal(uint32(0), tracer.processorDroppedTraces)
+	})
+
+	t.Run("drop-all", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer(WithSpanProcessor(SpanProcessorFunc(func(spans []ProcessedSpan) {
+			for _, s := range spans {
+				s.Drop()
+			}
+		})))
+		defer tracer.Stop()
+
+		trace := &finishedTrace{spans: []*span{newBasicSpan("a"), newBasicSpan("b")}}
+		tracer.processTrace(trace)
+		assert.Empty(trace.spans)
+		assert.Equal(uint32(2), tracer.processorDroppedSpans)
+		assert.Equal(uint32(1), tracer.processorDroppedTraces)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer(WithSpanProcessor(SpanProcessorFunc(func(spans []ProcessedSpan) {
+			spans[0].Drop()
+			panic("boom")
+		})))
+		defer tracer.Stop()
+
+		trace := &finishedTrace{spans: []*span{newBasicSpan("a"), newBasicSpan("b")}}
+		tracer.processTrace(trace)
+		assert.Len(trace.spans, 2)
+		assert.Equal(uint32(0), tracer.processorDroppedSpans)
+		assert.Equal(uint32(1), tracer.processorPanics)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		c := newConfig(WithSpanProcessor(nil))
+		assert.Empty(t, c.spanProcessors)
+	})
+}
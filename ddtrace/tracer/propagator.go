@@ -7,8 +7,12 @@ package tracer
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
 // Propagator implementations should be able to inject and extract
@@ -55,3 +59,149 @@ var (
 	// ErrSpanContextNotFound represents missing information in the given carrier.
 	ErrSpanContextNotFound = errors.New("span context not found")
 )
+
+// builtinPropagationStyles holds the names of the propagation styles implemented
+// by the tracer, which can't be registered with RegisterPropagator.
+var builtinPropagationStyles = map[string]bool{
+	"datadog":          true,
+	"tracecontext":     true,
+	"b3":               true,
+	"b3multi":          true,
+	"b3 single header": true,
+	"xray":             true,
+	"jaeger":           true,
+	"baggage":          true,
+	"none":             true,
+}
+
+var (
+	// propagatorsMu guards propagators.
+	propagatorsMu sync.RWMutex
+	// propagators holds the propagators registered with RegisterPropagator, by style name.
+	propagators = make(map[string]Propagator)
+)
+
+// RegisterPropagator registers p as the propagator of the propagation style name, so
+// that it can be selected by name, along with the built-in styles such as "datadog" or
+// "tracecontext", through the DD_TRACE_PROPAGATION_STYLE, DD_TRACE_PROPAGATION_STYLE_INJECT
+// and DD_TRACE_PROPAGATION_STYLE_EXTRACT env vars, e.g. "datadog,tracecontext,custom".
+// Names are case insensitive, and an error is returned for the names of the built-in
+// styles, which can instead be overridden through PropagatorConfig.Propagators.
+// Registering a name again replaces its propagator, and a nil p unregisters it.
+//
+// Propagators must be registered before the tracer is started, and should honour the
+// contract checked by CheckPropagator.
+func RegisterPropagator(name string, p Propagator) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.Contains(name, ",") {
+		return fmt.Errorf("invalid propagation style name %q", name)
+	}
+	if builtinPropagationStyles[name] {
+		return fmt.Errorf("propagation style %q is built-in and can't be registered", name)
+	}
+	propagatorsMu.Lock()
+	defer propagatorsMu.Unlock()
+	if p == nil {
+		delete(propagators, name)
+		return nil
+	}
+	propagators[name] = p
+	return nil
+}
+
+// registeredPropagator returns the propagator registered for the propagation style
+// name, or nil if there is none.
+func registeredPropagator(name string) Propagator {
+	propagatorsMu.RLock()
+	defer propagatorsMu.RUnlock()
+	return propagators[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// textMapWriterFunc is a carrier only implementing TextMapWriter.
+type textMapWriterFunc func(key, val string)
+
+// Set implements TextMapWriter.
+func (f textMapWriterFunc) Set(key, val string) { f(key, val) }
+
+// textMapReaderFunc is a carrier only implementing TextMapReader.
+type textMapReaderFunc func(handler func(key, val string) error) error
+
+// ForeachKey implements TextMapReader.
+func (f textMapReaderFunc) ForeachKey(handler func(key, val string) error) error { return f(handler) }
+
+// CheckPropagator checks that p honours the contract expected by the tracer from the
+// propagators of the trace context, returning an error describing the first violation
+// found. It is meant to be used in the tests of third-party propagators, e.g. registered
+// with RegisterPropagator:
+//
+//	if err := tracer.CheckPropagator(myPropagator{}); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The contract is the following:
+//   - Inject only relies on the TextMapWriter interface of the carrier, and returns
+//     ErrInvalidCarrier for carriers which don't implement it.
+//   - Extract only relies on the TextMapReader interface of the carrier, and returns
+//     ErrInvalidCarrier for carriers which don't implement it.
+//   - Extract returns the trace and span IDs of the injected span context, in a span
+//     context implementing ddtrace.SpanContextW3C, which the tracer requires to use
+//     it as parent.
+//   - Extract matches keys case insensitively, as HTTP headers may be canonicalized,
+//     and ignores unknown keys.
+//   - Extract returns ErrSpanContextNotFound when the carrier holds no span context.
+func CheckPropagator(p Propagator) error {
+	ctx := &spanContext{spanID: 0x1122334455667788}
+	ctx.traceID.SetUpper(0x0102030405060708)
+	ctx.traceID.SetLower(0x090a0b0c0d0e0f10)
+	ctx.setSamplingPriority(1, samplernames.Unknown)
+	ctx.setBaggageItem("key", "value")
+
+	if err := p.Inject(ctx, struct{}{}); !errors.Is(err, ErrInvalidCarrier) {
+		return fmt.Errorf("calling Inject with an invalid carrier returned %v, expected %v", err, ErrInvalidCarrier)
+	}
+	if _, err := p.Extract(struct{}{}); !errors.Is(err, ErrInvalidCarrier) {
+		return fmt.Errorf("calling Extract with an invalid carrier returned %v, expected %v", err, ErrInvalidCarrier)
+	}
+	if _, err := p.Extract(TextMapCarrier{}); !errors.Is(err, ErrSpanContextNotFound) {
+		return fmt.Errorf("calling Extract with an empty carrier returned %v, expected %v", err, ErrSpanContextNotFound)
+	}
+
+	injected := make(map[string]string)
+	err := p.Inject(ctx, textMapWriterFunc(func(key, val string) { injected[key] = val }))
+	if err != nil {
+		return fmt.Errorf("calling Inject returned %v", err)
+	}
+	if len(injected) == 0 {
+		return errors.New("calling Inject didn't set any key")
+	}
+	headers := make(HTTPHeadersCarrier)
+	for k, v := range injected {
+		headers.Set(k, v)
+	}
+	withUnknown := TextMapCarrier{"x-unknown-key": "unknown value"}
+	for k, v := range injected {
+		withUnknown[k] = v
+	}
+	for _, c := range []struct {
+		name    string
+		carrier interface{}
+	}{
+		{"a reader-only carrier", textMapReaderFunc(TextMapCarrier(injected).ForeachKey)},
+		{"HTTP headers", headers},
+		{"an unknown key", withUnknown},
+	} {
+		name := c.name
+		extracted, err := p.Extract(c.carrier)
+		if err != nil {
+			return fmt.Errorf("calling Extract with %s returned %v", name, err)
+		}
+		if _, ok := extracted.(ddtrace.SpanContextW3C); !ok {
+			return fmt.Errorf("calling Extract with %s returned a %T, which doesn't implement ddtrace.SpanContextW3C", name, extracted)
+		}
+		if extracted.TraceID() != ctx.TraceID() || extracted.SpanID() != ctx.SpanID() {
+			return fmt.Errorf("calling Extract with %s returned trace ID %d and span ID %d, expected %d and %d",
+				name, extracted.TraceID(), extracted.SpanID(), ctx.TraceID(), ctx.SpanID())
+		}
+	}
+	return nil
+}
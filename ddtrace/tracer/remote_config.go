@@ -0,0 +1,229 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+// dynamicConfig holds the tracer settings which can be updated at runtime through
+// remote configuration, along with their local values, which are restored once
+// the remote configuration is removed.
+type dynamicConfig struct {
+	mu sync.RWMutex
+
+	// globalTags holds the tags applied to every span. It is guarded by mu and is
+	// replaced on updates, never modified.
+	globalTags map[string]interface{}
+
+	// localTags, localRate and localRules hold the global tags, the global sample
+	// rate and the trace sampling rules configured locally.
+	localTags  map[string]interface{}
+	localRate  float64
+	localRules []SamplingRule
+}
+
+func newDynamicConfig(c *config, rs *traceRulesSampler) *dynamicConfig {
+	return &dynamicConfig{
+		globalTags: c.globalTags,
+		localTags:  c.globalTags,
+		localRate:  rs.globalRate,
+		localRules: rs.rules,
+	}
+}
+
+// tags returns the tags to apply to every span. The returned map must not be modified.
+func (d *dynamicConfig) tags() map[string]interface{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.globalTags
+}
+
+func (d *dynamicConfig) setTags(tags map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.globalTags = tags
+}
+
+// serviceTarget specifies the service and environment that a remote configuration
+// applies to.
+type serviceTarget struct {
+	Service string `json:"service"`
+	Env     string `json:"env"`
+}
+
+// libConfig holds the tracer settings of an APM_TRACING remote configuration.
+// Settings which are not set use their local value.
+type libConfig struct {
+	SamplingRate  *float64        `json:"tracing_sampling_rate,omitempty"`
+	SamplingRules json.RawMessage `json:"tracing_sampling_rules,omitempty"`
+	Tags          []string        `json:"tracing_tags,omitempty"`
+}
+
+// configData is the content of an APM_TRACING remote configuration file.
+type configData struct {
+	Action        string        `json:"action"`
+	ServiceTarget serviceTarget `json:"service_target"`
+	LibConfig     libConfig     `json:"lib_config"`
+}
+
+// newRemoteConfig creates the remote configuration client of the tracer, and
+// registers the APM_TRACING product on it, allowing to update the tracer settings
+// at runtime. The client is shared with AppSec and must be started once AppSec
+// registered its products.
+func (t *tracer) newRemoteConfig(cfg remoteconfig.ClientConfig) error {
+	client, err := remoteconfig.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	client.RegisterProduct(rc.ProductAPMTracing)
+	client.RegisterCapability(remoteconfig.APMTracingSampleRate)
+	client.RegisterCapability(remoteconfig.APMTracingCustomTags)
+	client.RegisterCallback(t.onRemoteConfigUpdate)
+	t.rc = client
+	return nil
+}
+
+// onRemoteConfigUpdate is the remote configuration callback handling APM_TRACING
+// updates. A removed configuration file reverts the tracer settings to their local
+// values. An invalid configuration leaves the current settings unchanged.
+func (t *tracer) onRemoteConfigUpdate(updates map[string]remoteconfig.ProductUpdate) map[string]rc.ApplyStatus {
+	u := updates[rc.ProductAPMTracing]
+	if len(u) == 0 {
+		return nil
+	}
+	statuses := make(map[string]rc.ApplyStatus, len(u))
+	var (
+		lc      libConfig
+		applied string
+		removed bool
+	)
+	for path, raw := range u {
+		if raw == nil {
+			// the configuration was removed; the local settings are restored
+			// unless another configuration is applied below
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateAcknowledged}
+			removed = true
+			continue
+		}
+		var data configData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateError, Error: err.Error()}
+			continue
+		}
+		if err := t.checkServiceTarget(data.ServiceTarget); err != nil {
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateError, Error: err.Error()}
+			continue
+		}
+		if applied != "" {
+			log.Warn("Remote config: ignoring APM_TRACING configuration %s, %s is already applied", path, applied)
+			statuses[path] = rc.ApplyStatus{State: rc.ApplyStateError, Error: "multiple configurations for the same service"}
+			continue
+		}
+		lc, applied = data.LibConfig, path
+	}
+	if applied == "" && !removed {
+		return statuses
+	}
+	if err := t.applyLibConfig(lc); err != nil {
+		log.Error("Remote config: unable to apply APM_TRACING configuration %s: %v", applied, err)
+		statuses[applied] = rc.ApplyStatus{State: rc.ApplyStateError, Error: err.Error()}
+		return statuses
+	}
+	if applied != "" {
+		statuses[applied] = rc.ApplyStatus{State: rc.ApplyStateAcknowledged}
+	}
+	return statuses
+}
+
+// checkServiceTarget returns an error if st does not target this tracer.
+func (t *tracer) checkServiceTarget(st serviceTarget) error {
+	if st.Service != "" && st.Service != t.config.serviceName {
+		return fmt.Errorf("service mismatch: %q (expected %q)", st.Service, t.config.serviceName)
+	}
+	if st.Env != "" && st.Env != t.config.env {
+		return fmt.Errorf("env mismatch: %q (expected %q)", st.Env, t.config.env)
+	}
+	return nil
+}
+
+// applyLibConfig applies the settings of lc, using the local value of the settings
+// it does not set, and reports the resulting configuration to telemetry. Nothing is
+// applied if any of the settings is invalid.
+func (t *tracer) applyLibConfig(lc libConfig) error {
+	rate, rules, tags := t.dynamic.localRate, t.dynamic.localRules, t.dynamic.localTags
+	var rateOrigin, rulesOrigin, tagsOrigin string
+	if lc.SamplingRate != nil {
+		if r := *lc.SamplingRate; r < 0 || r > 1 {
+			return fmt.Errorf("tracing_sampling_rate out of [0.0, 1.0] range: %f", r)
+		}
+		rate, rateOrigin = *lc.SamplingRate, telemetryOriginRemoteConfig
+	}
+	if len(lc.SamplingRules) > 0 && string(lc.SamplingRules) != "null" {
+		var err error
+		if rules, err = unmarshalSamplingRules(lc.SamplingRules, SamplingRuleTrace); err != nil {
+			return fmt.Errorf("tracing_sampling_rules: %v", err)
+		}
+		rulesOrigin = telemetryOriginRemoteConfig
+	}
+	if lc.Tags != nil {
+		tags = make(map[string]interface{}, len(lc.Tags)+1)
+		for _, tag := range lc.Tags {
+			k, v, ok := strings.Cut(tag, ":")
+			if !ok || k == "" {
+				return fmt.Errorf("tracing_tags: invalid tag %q", tag)
+			}
+			tags[k] = v
+		}
+		tags[ext.RuntimeID] = globalconfig.RuntimeID()
+		tagsOrigin = telemetryOriginRemoteConfig
+	}
+	t.rulesSampling.traces.setGlobalSampleRate(rate)
+	t.rulesSampling.traces.setRules(rules)
+	t.dynamic.setTags(tags)
+
+	var rulesJSON []byte
+	if len(rules) > 0 {
+		rulesJSON, _ = json.Marshal(rules)
+	}
+	var rateValue interface{}
+	if !math.IsNaN(rate) {
+		rateValue = rate
+	}
+	telemetry.GlobalClient.ConfigChange([]telemetry.Configuration{
+		{Name: "trace_sample_rate", Value: rateValue, Origin: rateOrigin},
+		{Name: "trace_sample_rules", Value: string(rulesJSON), Origin: rulesOrigin},
+		{Name: "trace_tags", Value: tagsString(tags), Origin: tagsOrigin},
+	})
+	return nil
+}
+
+// telemetryOriginRemoteConfig is the telemetry origin of settings applied through
+// remote configuration.
+const telemetryOriginRemoteConfig = "remote_config"
+
+// tagsString returns tags formatted as a sorted, comma-separated list of key:value pairs.
+func tagsString(tags map[string]interface{}) string {
+	list := make([]string, 0, len(tags))
+	for k, v := range tags {
+		list = append(list, fmt.Sprintf("%s:%v", k, v))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
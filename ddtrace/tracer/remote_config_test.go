@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry/telemetrytest"
+
+	rc "github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+)
+
+const rcPath = "datadog/2/APM_TRACING/config/config"
+
+func apmTracingUpdate(path string, raw string) map[string]remoteconfig.ProductUpdate {
+	u := remoteconfig.ProductUpdate{path: nil}
+	if raw != "" {
+		u[path] = []byte(raw)
+	}
+	return map[string]remoteconfig.ProductUpdate{rc.ProductAPMTracing: u}
+}
+
+func TestOnRemoteConfigUpdate(t *testing.T) {
+	t.Run("sample-rate", func(t *testing.T) {
+		assert := assert.New(t)
+		telemetryClient := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(telemetryClient)()
+		tracer, _, _, stop := startTestTracer(t, WithService("my-service"), WithEnv("my-env"))
+		defer stop()
+
+		statuses := tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath,
+			`{"service_target":{"service":"my-service","env":"my-env"},"lib_config":{"tracing_sampling_rate":0.5}}`))
+		assert.Equal(rc.ApplyStateAcknowledged, statuses[rcPath].State)
+		assert.Equal(0.5, tracer.rulesSampling.traces.globalRate)
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish()
+		assert.Equal(0.5, s.Metrics[keyRulesSamplerAppliedRate])
+		telemetry.Check(t, telemetryClient.Configuration, "trace_sample_rate", 0.5)
+
+		// the configuration is removed: the local settings are restored
+		telemetryClient.Configuration = nil
+		statuses = tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath, ""))
+		assert.Equal(rc.ApplyStateAcknowledged, statuses[rcPath].State)
+		assert.True(math.IsNaN(tracer.rulesSampling.traces.globalRate))
+		telemetry.Check(t, telemetryClient.Configuration, "trace_sample_rate", nil)
+	})
+
+	t.Run("sampling-rules", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingRules([]SamplingRule{ServiceRule("local", 1)}))
+		defer stop()
+
+		statuses := tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath,
+			`{"lib_config":{"tracing_sampling_rules":[{"service":"remote","sample_rate":0}]}}`))
+		assert.Equal(rc.ApplyStateAcknowledged, statuses[rcPath].State)
+		s := tracer.StartSpan("web.request", ServiceName("remote")).(*span)
+		s.Finish()
+		assert.Equal(0., s.Metrics[keyRulesSamplerAppliedRate])
+		assert.Equal(float64(ext.PriorityUserReject), s.Metrics[keySamplingPriority])
+
+		tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath, ""))
+		require.Len(t, tracer.rulesSampling.traces.rules, 1)
+		assert.Equal("local", tracer.rulesSampling.traces.rules[0].exactService)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		assert := assert.New(t)
+		telemetryClient := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(telemetryClient)()
+		tracer, _, _, stop := startTestTracer(t, WithGlobalTag("local", "value"))
+		defer stop()
+
+		statuses := tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath,
+			`{"lib_config":{"tracing_tags":["team:apm","key:a:b"]}}`))
+		assert.Equal(rc.ApplyStateAcknowledged, statuses[rcPath].State)
+		s := tracer.StartSpan("web.request").(*span)
+		assert.Equal("apm", s.Meta["team"])
+		assert.Equal("a:b", s.Meta["key"])
+		assert.NotContains(s.Meta, "local")
+		assert.NotEmpty(s.Meta[ext.RuntimeID])
+		telemetry.Check(t, telemetryClient.Configuration, "trace_tags", "key:a:b,runtime-id:"+s.Meta[ext.RuntimeID]+",team:apm")
+
+		tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath, ""))
+		s = tracer.StartSpan("web.request").(*span)
+		assert.Equal("value", s.Meta["local"])
+		assert.NotContains(s.Meta, "team")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithService("my-service"))
+		defer stop()
+		tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath, `{"lib_config":{"tracing_sampling_rate":0.5}}`))
+
+		for name, raw := range map[string]string{
+			"json":           `{"lib_config":`,
+			"rate":           `{"lib_config":{"tracing_sampling_rate":2}}`,
+			"rules":          `{"lib_config":{"tracing_sampling_rules":[{"service":"remote"}]}}`,
+			"tags":           `{"lib_config":{"tracing_tags":["team"]}}`,
+			"service-target": `{"service_target":{"service":"other-service"},"lib_config":{"tracing_sampling_rate":0.1}}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				statuses := tracer.onRemoteConfigUpdate(apmTracingUpdate(rcPath, raw))
+				assert.Equal(t, rc.ApplyStateError, statuses[rcPath].State)
+				assert.NotEmpty(t, statuses[rcPath].Error)
+				// the current settings are kept
+				assert.Equal(t, 0.5, tracer.rulesSampling.traces.globalRate)
+			})
+		}
+	})
+
+	t.Run("other-product", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+		statuses := tracer.onRemoteConfigUpdate(map[string]remoteconfig.ProductUpdate{
+			rc.ProductASMFeatures: {"datadog/2/ASM_FEATURES/asm/config": []byte(`{}`)},
+		})
+		assert.Empty(t, statuses)
+	})
+}
+
+func TestNewRemoteConfig(t *testing.T) {
+	tracer := newUnstartedTracer()
+	defer tracer.statsd.Close()
+	cfg := remoteConfigClientConfig(tracer.config)
+	require.NoError(t, tracer.newRemoteConfig(cfg))
+
+	assert.Contains(t, tracer.rc.Products, rc.ProductAPMTracing)
+	assert.Contains(t, tracer.rc.Capabilities, remoteconfig.APMTracingSampleRate)
+	assert.Contains(t, tracer.rc.Capabilities, remoteconfig.APMTracingCustomTags)
+}
+
+func TestStartRemoteConfig(t *testing.T) {
+	start := func(t *testing.T, opts ...StartOption) *tracer {
+		t.Helper()
+		Start(append([]StartOption{withTransport(newDummyTransport()), withNoopStats()}, opts...)...)
+		t.Cleanup(Stop)
+		tr, ok := internal.GetGlobalTracer().(*tracer)
+		require.True(t, ok)
+		return tr
+	}
+
+	t.Run("agent", func(t *testing.T) {
+		tr := start(t)
+		require.NotNil(t, tr.rc)
+		assert.Contains(t, tr.rc.Products, rc.ProductAPMTracing)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("DD_REMOTE_CONFIGURATION_ENABLED", "false")
+		assert.Nil(t, start(t).rc)
+	})
+
+	t.Run("lambda", func(t *testing.T) {
+		assert.Nil(t, start(t, WithLambdaMode(true)).rc)
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+		assert.Nil(t, start(t, WithOTLPExporter("http://localhost:4318/v1/traces")).rc)
+	})
+}
+
+func TestRemoteConfigEnabled(t *testing.T) {
+	assert.True(t, newConfig().remoteConfigEnabled)
+	t.Setenv("DD_REMOTE_CONFIGURATION_ENABLED", "false")
+	assert.False(t, newConfig().remoteConfigEnabled)
+}
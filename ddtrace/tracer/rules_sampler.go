@@ -70,6 +70,21 @@ type SamplingRule struct {
 	// If not specified, the default is no limit.
 	MaxPerSecond float64
 
+	// Resource specifies the regex pattern that a span resource must match.
+	Resource *regexp.Regexp
+
+	// Tags specifies the regex patterns that the values of span tags must match,
+	// by tag key. A span which doesn't have one of the tags doesn't match the rule.
+	// Numeric tags are matched using their decimal representation.
+	//
+	// The resource and tags of a trace's root span are often only known once it
+	// finishes: when trace rules use Resource or Tags, the sampling decision of
+	// the traces started locally is deferred to the finish of their root span,
+	// or to the propagation of their context if it happens earlier. It isn't when
+	// partial flushing is enabled, in which case these rules only match the
+	// resource and tags set when the root span starts.
+	Tags map[string]*regexp.Regexp
+
 	ruleType     SamplingRuleType
 	exactService string
 	exactName    string
@@ -88,9 +103,34 @@ func (sr *SamplingRule) match(s *span) bool {
 	} else if sr.exactName != "" && sr.exactName != s.Name {
 		return false
 	}
+	if sr.Resource != nil && !sr.Resource.MatchString(s.Resource) {
+		return false
+	}
+	for k, re := range sr.Tags {
+		v, ok := s.Meta[k]
+		if !ok {
+			m, ok := s.Metrics[k]
+			if !ok {
+				return false
+			}
+			v = formatTagMetric(m)
+		}
+		if !re.MatchString(v) {
+			return false
+		}
+	}
 	return true
 }
 
+// formatTagMetric returns the decimal representation of the numeric tag value v,
+// without fractional part for integers.
+func formatTagMetric(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
 // SamplingRuleType represents a type of sampling rule spans are matched against.
 type SamplingRuleType int
 
@@ -184,8 +224,52 @@ func SpanNameServiceMPSRule(name, service string, rate, limit float64) SamplingR
 	}
 }
 
+// TagsResourceRule returns a SamplingRule that applies the provided sampling rate
+// to spans matching the resource, operation name and service glob patterns provided,
+// as well as the glob patterns of the tags provided, by tag key. Empty patterns match
+// any value.
+func TagsResourceRule(tags map[string]string, resource, name, service string, rate float64) SamplingRule {
+	return SamplingRule{
+		Service:  globMatch(service),
+		Name:     globMatch(name),
+		Resource: globMatch(resource),
+		Tags:     globTags(tags),
+		Rate:     rate,
+	}
+}
+
+// SpanTagsResourceRule returns a SamplingRule of type SamplingRuleSpan that applies
+// the provided sampling rate to spans matching the resource, operation name and
+// service glob patterns provided, as well as the glob patterns of the tags provided,
+// by tag key. Empty patterns match any value.
+func SpanTagsResourceRule(tags map[string]string, resource, name, service string, rate float64) SamplingRule {
+	return SamplingRule{
+		Service:  globMatch(service),
+		Name:     globMatch(name),
+		Resource: globMatch(resource),
+		Tags:     globTags(tags),
+		Rate:     rate,
+		ruleType: SamplingRuleSpan,
+		limiter:  newSingleSpanRateLimiter(0),
+	}
+}
+
+// globTags compiles the glob patterns of tags, by tag key. It returns nil if tags is empty.
+func globTags(tags map[string]string) map[string]*regexp.Regexp {
+	if len(tags) == 0 {
+		return nil
+	}
+	res := make(map[string]*regexp.Regexp, len(tags))
+	for k, pattern := range tags {
+		res[k] = globMatch(pattern)
+	}
+	return res
+}
+
 // traceRulesSampler allows a user-defined list of rules to apply to traces.
-// These rules can match based on the span's Service, Name or both.
+// These rules can match based on the span's Service, Name, Resource and Tags.
+// As they are applied when the root span of a trace starts, they can only match
+// the resource and tags known at that time.
 // When making a sampling decision, the rules are checked in order until
 // a match is found.
 // If a match is found, the rate from that rule is used.
@@ -199,7 +283,9 @@ func SpanNameServiceMPSRule(name, service string, rate, limit float64) SamplingR
 // Its value is the number of spans to sample per second.
 // Spans that matched the rules but exceeded the rate limit are not sampled.
 type traceRulesSampler struct {
+	m          sync.RWMutex   // guards rules, late and globalRate, which may be updated through remote configuration
 	rules      []SamplingRule // the rules to match spans with
+	late       bool           // whether any of the rules matches the resource or tags of spans
 	globalRate float64        // a rate to apply when no rules match a span
 	limiter    *rateLimiter   // used to limit the volume of spans sampled
 }
@@ -209,6 +295,7 @@ type traceRulesSampler struct {
 func newTraceRulesSampler(rules []SamplingRule) *traceRulesSampler {
 	return &traceRulesSampler{
 		rules:      rules,
+		late:       matchLateFields(rules),
 		globalRate: globalSampleRate(),
 		limiter:    newRateLimiter(),
 	}
@@ -235,9 +322,46 @@ func globalSampleRate() float64 {
 }
 
 func (rs *traceRulesSampler) enabled() bool {
+	rs.m.RLock()
+	defer rs.m.RUnlock()
 	return len(rs.rules) > 0 || !math.IsNaN(rs.globalRate)
 }
 
+// setGlobalSampleRate sets the rate to apply when no rules match a span. A NaN
+// rate leaves the decision to the priority sampler when no rules match.
+func (rs *traceRulesSampler) setGlobalSampleRate(rate float64) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	rs.globalRate = rate
+}
+
+// setRules replaces the rules to match spans with.
+func (rs *traceRulesSampler) setRules(rules []SamplingRule) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	rs.rules = rules
+	rs.late = matchLateFields(rules)
+}
+
+// matchesLateFields reports whether any of the rules matches the resource or the
+// tags of spans, which are often only set after the spans start.
+func (rs *traceRulesSampler) matchesLateFields() bool {
+	rs.m.RLock()
+	defer rs.m.RUnlock()
+	return rs.late
+}
+
+// matchLateFields reports whether any of rules matches the resource or the tags
+// of spans.
+func matchLateFields(rules []SamplingRule) bool {
+	for _, r := range rules {
+		if r.Resource != nil || len(r.Tags) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // apply uses the sampling rules to determine the sampling rate for the
 // provided span. If the rules don't match, and a default rate hasn't been
 // set using DD_TRACE_SAMPLE_RATE, then it returns false and the span is not
@@ -248,15 +372,19 @@ func (rs *traceRulesSampler) apply(span *span) bool {
 		return false
 	}
 
+	rs.m.RLock()
+	rules, rate := rs.rules, rs.globalRate
+	rs.m.RUnlock()
 	var matched bool
-	rate := rs.globalRate
-	for _, rule := range rs.rules {
+	span.RLock()
+	for _, rule := range rules {
 		if rule.match(span) {
 			matched = true
 			rate = rule.Rate
 			break
 		}
 	}
+	span.RUnlock()
 	if !matched && math.IsNaN(rate) {
 		// no matching rule or global rate, so we want to fall back
 		// to priority sampling
@@ -283,6 +411,18 @@ func (rs *traceRulesSampler) applyRule(span *span, rate float64, now time.Time)
 	span.SetTag(keyRulesSamplerLimiterRate, rate)
 }
 
+// keepDeferred keeps the trace of the root span, which deferred sampling decided to keep,
+// granted the rate limiter allows it. It reports whether the trace was kept.
+func (rs *traceRulesSampler) keepDeferred(span *span, now time.Time) bool {
+	sampled, rate := rs.limiter.allowOne(now)
+	if !sampled {
+		return false
+	}
+	span.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Deferred)
+	span.SetTag(keyRulesSamplerLimiterRate, rate)
+	return true
+}
+
 // limit returns the rate limit set in the rules sampler, controlled by DD_TRACE_RATE_LIMIT, and
 // true if rules sampling is enabled. If not present it returns math.NaN() and false.
 func (rs *traceRulesSampler) limit() (float64, bool) {
@@ -319,8 +459,8 @@ func newRateLimiter() *rateLimiter {
 
 // singleSpanRulesSampler allows a user-defined list of rules to apply to spans
 // to sample single spans.
-// These rules match based on the span's Service and Name. If empty value is supplied
-// to either Service or Name field, it will default to "*", allow all.
+// These rules match based on the span's Service, Name, Resource and Tags. If empty value
+// is supplied to either Service or Name field, it will default to "*", allow all.
 // When making a sampling decision, the rules are checked in order until
 // a match is found.
 // If a match is found, the rate from that rule is used.
@@ -488,18 +628,37 @@ func samplingRulesFromEnv() (trace, span []SamplingRule, err error) {
 	return trace, span, err
 }
 
+// jsonRule is the JSON representation of a sampling rule, as found in
+// DD_TRACE_SAMPLING_RULES for example.
+type jsonRule struct {
+	Service      string            `json:"service"`
+	Name         string            `json:"name"`
+	Rate         json.Number       `json:"sample_rate"`
+	MaxPerSecond float64           `json:"max_per_second"`
+	Resource     string            `json:"resource"`
+	Tags         map[string]string `json:"tags"`
+}
+
+// String returns the rule as shown in error messages. The resource and tags are
+// only included when set.
+func (r jsonRule) String() string {
+	s := fmt.Sprintf("{Service:%s Name:%s Rate:%s MaxPerSecond:%v", r.Service, r.Name, r.Rate, r.MaxPerSecond)
+	if r.Resource != "" {
+		s += " Resource:" + r.Resource
+	}
+	if len(r.Tags) > 0 {
+		s += fmt.Sprintf(" Tags:%v", r.Tags)
+	}
+	return s + "}"
+}
+
 // unmarshalSamplingRules unmarshals JSON from b and returns the sampling rules found, attributing
 // the type t to them. If any errors are occurred, they are returned.
 func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule, error) {
 	if len(b) == 0 {
 		return nil, nil
 	}
-	var jsonRules []struct {
-		Service      string      `json:"service"`
-		Name         string      `json:"name"`
-		Rate         json.Number `json:"sample_rate"`
-		MaxPerSecond float64     `json:"max_per_second"`
-	}
+	var jsonRules []jsonRule
 	err := json.Unmarshal(b, &jsonRules)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling JSON: %v", err)
@@ -521,19 +680,24 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 			continue
 		}
 		if rate < 0.0 || rate > 1.0 {
-			errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %+v: rate is out of [0.0, 1.0] range", i, v))
+			errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %s: rate is out of [0.0, 1.0] range", i, v))
 			continue
 		}
 		switch spanType {
 		case SamplingRuleSpan:
-			rules = append(rules, SamplingRule{
+			rule := SamplingRule{
 				Service:      globMatch(v.Service),
 				Name:         globMatch(v.Name),
+				Tags:         globTags(v.Tags),
 				Rate:         rate,
 				MaxPerSecond: v.MaxPerSecond,
 				limiter:      newSingleSpanRateLimiter(v.MaxPerSecond),
 				ruleType:     SamplingRuleSpan,
-			})
+			}
+			if v.Resource != "" {
+				rule.Resource = globMatch(v.Resource)
+			}
+			rules = append(rules, rule)
 		case SamplingRuleTrace:
 			if v.Rate == "" {
 				errs = append(errs, fmt.Sprintf("at index %d: rate not provided", i))
@@ -545,18 +709,19 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 				continue
 			}
 			if rate < 0.0 || rate > 1.0 {
-				errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %+v: rate is out of [0.0, 1.0] range", i, v))
+				errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %s: rate is out of [0.0, 1.0] range", i, v))
 				continue
 			}
 
-			switch {
-			case v.Service != "" && v.Name != "":
-				rules = append(rules, NameServiceRule(v.Name, v.Service, rate))
-			case v.Service != "":
-				rules = append(rules, ServiceRule(v.Service, rate))
-			case v.Name != "":
-				rules = append(rules, NameRule(v.Name, rate))
+			if v.Service == "" && v.Name == "" && v.Resource == "" && len(v.Tags) == 0 {
+				continue
+			}
+			rule := NameServiceRule(v.Name, v.Service, rate)
+			if v.Resource != "" {
+				rule.Resource = globMatch(v.Resource)
 			}
+			rule.Tags = globTags(v.Tags)
+			rules = append(rules, rule)
 		}
 	}
 	if len(errs) != 0 {
@@ -568,11 +733,13 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 // MarshalJSON implements the json.Marshaler interface.
 func (sr *SamplingRule) MarshalJSON() ([]byte, error) {
 	s := struct {
-		Service      string   `json:"service"`
-		Name         string   `json:"name"`
-		Rate         float64  `json:"sample_rate"`
-		Type         string   `json:"type"`
-		MaxPerSecond *float64 `json:"max_per_second,omitempty"`
+		Service      string            `json:"service"`
+		Name         string            `json:"name"`
+		Resource     string            `json:"resource,omitempty"`
+		Tags         map[string]string `json:"tags,omitempty"`
+		Rate         float64           `json:"sample_rate"`
+		Type         string            `json:"type"`
+		MaxPerSecond *float64          `json:"max_per_second,omitempty"`
 	}{}
 	if sr.exactService != "" {
 		s.Service = sr.exactService
@@ -584,6 +751,15 @@ func (sr *SamplingRule) MarshalJSON() ([]byte, error) {
 	} else if sr.Name != nil {
 		s.Name = fmt.Sprintf("%s", sr.Name)
 	}
+	if sr.Resource != nil {
+		s.Resource = sr.Resource.String()
+	}
+	if len(sr.Tags) > 0 {
+		s.Tags = make(map[string]string, len(sr.Tags))
+		for k, re := range sr.Tags {
+			s.Tags[k] = re.String()
+		}
+	}
 	s.Rate = sr.Rate
 	s.Type = fmt.Sprintf("%v(%d)", sr.ruleType.String(), sr.ruleType)
 	if sr.MaxPerSecond != 0 {
@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// runtimeMetricsNames lists the runtime/metrics metrics reported by the
+// runtimeMetricsCollector. Metrics which are not supported by the Go version
+// the program was built with are skipped.
+var runtimeMetricsNames = []string{
+	// scheduler
+	"/sched/goroutines:goroutines",
+	"/sched/gomaxprocs:threads",
+	"/sched/latencies:seconds",
+	// synchronization
+	"/sync/mutex/wait/total:seconds",
+	// garbage collector
+	"/gc/cycles/total:gc-cycles",
+	"/gc/cycles/forced:gc-cycles",
+	"/gc/heap/goal:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/frees:bytes",
+	"/gc/gomemlimit:bytes",
+	"/gc/gogc:percent",
+	"/gc/pauses:seconds",
+	// memory
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/cpu/classes/gc/total:cpu-seconds",
+}
+
+// runtimeMetricsSamples is the number of samples submitted for each histogram at
+// every report. The samples are taken at evenly spaced quantiles of the values
+// observed since the previous report, so that the resulting distribution has the
+// same shape, while keeping the number of submitted points bounded.
+const runtimeMetricsSamples = 50
+
+// runtimeMetricsCollector reports go runtime metrics read through the runtime/metrics
+// package, which, unlike runtime.ReadMemStats, does not stop the world. Scalar metrics
+// are reported as gauges and histograms, such as the scheduling latencies and the GC
+// pauses, as distributions of the values observed since the previous report.
+type runtimeMetricsCollector struct {
+	statsd  statsdClient
+	tags    []string
+	samples []metrics.Sample
+	names   []string            // statsd metric names, by sample index
+	prev    map[string][]uint64 // bucket counts of histograms at the previous report
+}
+
+func newRuntimeMetricsCollector(statsd statsdClient, tags []string) *runtimeMetricsCollector {
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	c := &runtimeMetricsCollector{
+		statsd: statsd,
+		tags:   tags,
+		prev:   make(map[string][]uint64),
+	}
+	for _, name := range runtimeMetricsNames {
+		if !supported[name] {
+			log.Debug("Runtime metric %s is not supported by this version of Go, skipping.", name)
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: name})
+		c.names = append(c.names, runtimeMetricName(name))
+	}
+	// read the histograms once, so that the first report only contains the values
+	// observed after the collector was created
+	metrics.Read(c.samples)
+	for _, s := range c.samples {
+		if s.Value.Kind() == metrics.KindFloat64Histogram {
+			c.prev[s.Name] = append([]uint64(nil), s.Value.Float64Histogram().Counts...)
+		}
+	}
+	return c
+}
+
+// runtimeMetricName returns the statsd name of the runtime/metrics metric name,
+// e.g. runtime.go.metrics.sched_latencies.seconds for /sched/latencies:seconds.
+func runtimeMetricName(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.NewReplacer("/", "_", ":", ".", "-", "_").Replace(name)
+	return "runtime.go.metrics." + name
+}
+
+// report reads and reports all the metrics of c.
+func (c *runtimeMetricsCollector) report() {
+	metrics.Read(c.samples)
+	for i, s := range c.samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			c.statsd.Gauge(c.names[i], float64(s.Value.Uint64()), c.tags, 1)
+		case metrics.KindFloat64:
+			c.statsd.Gauge(c.names[i], s.Value.Float64(), c.tags, 1)
+		case metrics.KindFloat64Histogram:
+			c.reportHistogram(c.names[i], s.Name, s.Value.Float64Histogram())
+		}
+	}
+}
+
+// reportHistogram reports the values of h observed since the previous report as
+// the distribution name.
+func (c *runtimeMetricsCollector) reportHistogram(name, key string, h *metrics.Float64Histogram) {
+	prev := c.prev[key]
+	delta := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, n := range h.Counts {
+		if i < len(prev) && prev[i] <= n {
+			n -= prev[i]
+		}
+		delta[i] = n
+		total += n
+	}
+	c.prev[key] = append(prev[:0], h.Counts...)
+	if total == 0 {
+		return
+	}
+	for i := 0; i < runtimeMetricsSamples; i++ {
+		q := (float64(i) + 0.5) / runtimeMetricsSamples
+		c.statsd.Distribution(name, histogramQuantile(h.Buckets, delta, total, q), c.tags, 1)
+	}
+}
+
+// histogramQuantile returns an estimate of the q-quantile of the values counted
+// in counts, where counts[i] is the number of values within [buckets[i], buckets[i+1]),
+// and total is the sum of counts.
+func histogramQuantile(buckets []float64, counts []uint64, total uint64, q float64) float64 {
+	rank := uint64(q * float64(total))
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if n == 0 || seen <= rank {
+			continue
+		}
+		lo, hi := buckets[i], buckets[i+1]
+		switch {
+		case math.IsInf(lo, -1):
+			return hi
+		case math.IsInf(hi, 1):
+			return lo
+		default:
+			return (lo + hi) / 2
+		}
+	}
+	return 0
+}
+
+// reportRuntimeMetricsV2 periodically reports go runtime metrics at the given
+// interval, using a runtimeMetricsCollector.
+func (t *tracer) reportRuntimeMetricsV2(interval time.Duration) {
+	c := newRuntimeMetricsCollector(t.statsd, runtimeMetricsTags(t.config))
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			log.Debug("Reporting runtime metrics (runtime/metrics)...")
+			c.report()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// runtimeMetricsTags returns the service, env and version tags of the metrics
+// reported by the runtimeMetricsCollector, for those which are set.
+func runtimeMetricsTags(c *config) []string {
+	var tags []string
+	if c.serviceName != "" {
+		tags = append(tags, "service:"+c.serviceName)
+	}
+	if c.env != "" {
+		tags = append(tags, "env:"+c.env)
+	}
+	if c.version != "" {
+		tags = append(tags, "version:"+c.version)
+	}
+	return tags
+}
@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetricName(t *testing.T) {
+	assert.Equal(t, "runtime.go.metrics.sched_latencies.seconds", runtimeMetricName("/sched/latencies:seconds"))
+	assert.Equal(t, "runtime.go.metrics.gc_cycles_total.gc_cycles", runtimeMetricName("/gc/cycles/total:gc-cycles"))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{math.Inf(-1), 0, 1, 2, math.Inf(1)}
+	counts := []uint64{0, 10, 0, 10}
+	assert.Equal(t, 0.5, histogramQuantile(buckets, counts, 20, 0.1))
+	assert.Equal(t, 0.5, histogramQuantile(buckets, counts, 20, 0.49))
+	assert.Equal(t, 2., histogramQuantile(buckets, counts, 20, 0.5))
+	assert.Equal(t, 2., histogramQuantile(buckets, counts, 20, 0.99))
+	assert.Equal(t, 0., histogramQuantile(buckets[:2], []uint64{5}, 5, 0.5))
+}
+
+func TestRuntimeMetricsCollector(t *testing.T) {
+	var tg testStatsdClient
+	c := newRuntimeMetricsCollector(&tg, []string{"service:my-service"})
+
+	runtime.GC()
+	c.report()
+	calls := tg.CallNames()
+	assert.Contains(t, calls, "runtime.go.metrics.sched_goroutines.goroutines")
+	assert.Contains(t, calls, "runtime.go.metrics.gc_heap_goal.bytes")
+	for _, call := range tg.GaugeCalls() {
+		assert.Equal(t, []string{"service:my-service"}, call.tags)
+	}
+	var pauses int
+	for _, call := range tg.DistributionCalls() {
+		if call.name == "runtime.go.metrics.gc_pauses.seconds" {
+			pauses++
+			assert.Greater(t, call.floatVal, 0.)
+		}
+	}
+	assert.Equal(t, runtimeMetricsSamples, pauses)
+
+	// no GC happened since the previous report: no pauses are reported
+	tg.Reset()
+	c.report()
+	for _, call := range tg.DistributionCalls() {
+		assert.NotEqual(t, "runtime.go.metrics.gc_pauses.seconds", call.name)
+	}
+}
+
+func TestReportRuntimeMetricsV2(t *testing.T) {
+	var tg testStatsdClient
+	trc := newUnstartedTracer(withStatsdClient(&tg), WithService("my-service"), WithEnv("my-env"), WithServiceVersion("1.2.3"))
+	defer trc.statsd.Close()
+
+	trc.wg.Add(1)
+	go func() {
+		defer trc.wg.Done()
+		trc.reportRuntimeMetricsV2(time.Millisecond)
+	}()
+	err := tg.Wait(10, time.Second)
+	close(trc.stop)
+	trc.wg.Wait()
+	require.NoError(t, err)
+	assert.Contains(t, tg.CallNames(), "runtime.go.metrics.sched_goroutines.goroutines")
+	assert.NotContains(t, tg.CallNames(), "runtime.go.mem_stats.alloc")
+	assert.ElementsMatch(t, []string{"service:my-service", "env:my-env", "version:1.2.3"}, tg.GaugeCalls()[0].tags)
+}
+
+func TestRuntimeMetricsV2Config(t *testing.T) {
+	assert.False(t, newConfig().runtimeMetricsV2)
+	assert.True(t, newConfig(WithRuntimeMetricsV2()).runtimeMetricsV2)
+	t.Setenv("DD_RUNTIME_METRICS_V2_ENABLED", "true")
+	c := newConfig()
+	assert.True(t, c.runtimeMetricsV2)
+	assert.False(t, c.runtimeMetrics)
+}
@@ -140,7 +140,9 @@ func (ps *prioritySampler) getRate(spn *span) float64 {
 // apply applies sampling priority to the given span. Caller must ensure it is safe
 // to modify the span.
 func (ps *prioritySampler) apply(spn *span) {
+	spn.RLock()
 	rate := ps.getRate(spn)
+	spn.RUnlock()
 	if sampledByRate(spn.TraceID, rate) {
 		spn.setSamplingPriority(ext.PriorityAutoKeep, samplernames.AgentRate)
 	} else {
@@ -18,6 +18,7 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/time/rate"
@@ -934,17 +935,17 @@ func TestSamplingRuleMarshall(t *testing.T) {
 		in  SamplingRule
 		out string
 	}{
-		{SamplingRule{nil, nil, 0, 0, 0, "srv", "ops", nil},
+		{SamplingRule{nil, nil, 0, 0, nil, nil, 0, "srv", "ops", nil},
 			`{"service":"srv","name":"ops","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), nil, 0, 0, 0, "srv", "ops", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), nil, 0, 0, nil, nil, 0, "srv", "ops", nil},
 			`{"service":"srv","name":"ops","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.*"), regexp.MustCompile("ops.[0-9]+]"), 0, 0, 0, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.*"), regexp.MustCompile("ops.[0-9]+]"), 0, 0, nil, nil, 0, "", "", nil},
 			`{"service":"srv.*","name":"ops.[0-9]+]","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, 0, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, nil, nil, 0, "", "", nil},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, 1, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, nil, nil, 1, "", "", nil},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"span(1)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 1000, 1, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 1000, nil, nil, 1, "", "", nil},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"span(1)","max_per_second":1000}`},
 	} {
 		m, err := tt.in.MarshalJSON()
@@ -1002,3 +1003,145 @@ func BenchmarkGlobMatchSpan(b *testing.B) {
 		}
 	})
 }
+
+func TestSamplingRuleResourceAndTags(t *testing.T) {
+	makeSpan := func() *span {
+		s := newSpan("http.request", "test-service", "GET /health", random.Uint64(), random.Uint64(), 0)
+		s.Meta["customer.tier"] = "premium"
+		s.Metrics[ext.HTTPCode] = 200
+		s.Metrics["ratio"] = 0.5
+		return s
+	}
+
+	t.Run("match", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			rule  SamplingRule
+			match bool
+		}{
+			"resource-regexp":  {SamplingRule{Resource: regexp.MustCompile("^GET /health$")}, true},
+			"resource-glob":    {TagsResourceRule(nil, "GET /h*", "", "", 1), true},
+			"resource-other":   {TagsResourceRule(nil, "POST /*", "", "", 1), false},
+			"tag-regexp":       {SamplingRule{Tags: map[string]*regexp.Regexp{"customer.tier": regexp.MustCompile("^prem")}}, true},
+			"tag-glob":         {TagsResourceRule(map[string]string{"customer.tier": "prem???"}, "", "", "", 1), true},
+			"tag-other":        {TagsResourceRule(map[string]string{"customer.tier": "free"}, "", "", "", 1), false},
+			"tag-missing":      {TagsResourceRule(map[string]string{"customer.region": "*"}, "", "", "", 1), false},
+			"metric-int":       {TagsResourceRule(map[string]string{ext.HTTPCode: "2??"}, "", "", "", 1), true},
+			"metric-float":     {TagsResourceRule(map[string]string{"ratio": "0.5"}, "", "", "", 1), true},
+			"metric-other":     {TagsResourceRule(map[string]string{ext.HTTPCode: "5*"}, "", "", "", 1), false},
+			"all":              {TagsResourceRule(map[string]string{"customer.tier": "premium", ext.HTTPCode: "200"}, "GET /health", "http.*", "test-*", 1), true},
+			"all-service-diff": {TagsResourceRule(map[string]string{"customer.tier": "premium"}, "GET /health", "http.*", "other-*", 1), false},
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.Equal(t, tt.match, tt.rule.match(makeSpan()))
+			})
+		}
+	})
+
+	t.Run("trace-rules", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := newRulesSampler([]SamplingRule{
+			TagsResourceRule(nil, "GET /health", "", "", 0),
+			TagsResourceRule(map[string]string{"customer.tier": "premium"}, "", "", "", 1),
+		}, nil)
+		s := makeSpan()
+		assert.True(rs.SampleTrace(s))
+		assert.Equal(0., s.Metrics[keyRulesSamplerAppliedRate])
+		assert.Equal(float64(ext.PriorityUserReject), s.Metrics[keySamplingPriority])
+
+		s = makeSpan()
+		s.Resource = "GET /users"
+		assert.True(rs.SampleTrace(s))
+		assert.Equal(1., s.Metrics[keyRulesSamplerAppliedRate])
+	})
+
+	t.Run("set-after-start", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingRules([]SamplingRule{
+			TagsResourceRule(map[string]string{ext.HTTPCode: "5*"}, "", "", "", 1),
+			TagsResourceRule(nil, "GET /health", "", "", 1),
+			RateRule(0),
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		assert.True(root.context.trace.samplingIsDeferred())
+		root.SetTag(ext.HTTPCode, 503)
+		root.Finish()
+		p, _ := root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserKeep, p)
+
+		root = tracer.StartSpan("http.request").(*span)
+		root.SetTag(ext.ResourceName, "GET /health")
+		root.Finish()
+		p, _ = root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserKeep, p)
+
+		root = tracer.StartSpan("http.request").(*span)
+		root.SetTag(ext.HTTPCode, 200)
+		root.Finish()
+		p, _ = root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserReject, p)
+
+		// the decision is made once the context is propagated
+		root = tracer.StartSpan("http.request").(*span)
+		assert.NoError(tracer.Inject(root.Context(), TextMapCarrier(map[string]string{})))
+		root.SetTag(ext.HTTPCode, 503)
+		root.Finish()
+		p, _ = root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserReject, p)
+	})
+
+	t.Run("set-after-start-partial-flush", func(t *testing.T) {
+		tp := new(log.RecordLogger)
+		defer log.UseLogger(tp)()
+		tracer, _, _, stop := startTestTracer(t, WithPartialFlushing(10), WithSamplingRules([]SamplingRule{
+			TagsResourceRule(map[string]string{ext.HTTPCode: "5*"}, "", "", "", 1),
+		}))
+		defer stop()
+		assert.Contains(t, strings.Join(tp.Logs(), "\n"), "Partial flushing is enabled: trace sampling rules matching the resource or tags")
+
+		root := tracer.StartSpan("http.request").(*span)
+		defer root.Finish()
+		assert.False(t, root.context.trace.samplingIsDeferred())
+		_, ok := root.context.samplingPriority()
+		assert.True(t, ok)
+	})
+
+	t.Run("span-rules", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := newRulesSampler(nil, []SamplingRule{
+			SpanTagsResourceRule(map[string]string{ext.HTTPCode: "5*"}, "", "", "", 1),
+		})
+		s := makeSpan()
+		s.finished = true
+		assert.False(rs.SampleSpan(s))
+		s.Metrics[ext.HTTPCode] = 503
+		assert.True(rs.SampleSpan(s))
+		assert.Equal(float64(1), s.Metrics[keySingleSpanSamplingRuleRate])
+	})
+
+	t.Run("env", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"resource": "GET /health", "sample_rate": 0}, {"service": "test-service", "tags": {"customer.tier": "prem*"}, "sample_rate": 1}]`)
+		t.Setenv("DD_SPAN_SAMPLING_RULES", `[{"resource": "GET /*", "tags": {"http.status_code": "200"}}]`)
+		traceRules, spanRules, err := samplingRulesFromEnv()
+		assert.NoError(err)
+		if assert.Len(traceRules, 2) {
+			assert.Equal("^GET /health$", traceRules[0].Resource.String())
+			assert.Nil(traceRules[0].Tags)
+			assert.Equal("test-service", traceRules[1].exactService)
+			assert.Nil(traceRules[1].Resource)
+			assert.Equal("^prem.*$", traceRules[1].Tags["customer.tier"].String())
+		}
+		if assert.Len(spanRules, 1) {
+			assert.True(spanRules[0].match(makeSpan()))
+		}
+	})
+
+	t.Run("marshal", func(t *testing.T) {
+		rule := TagsResourceRule(map[string]string{"customer.tier": "premium"}, "GET /health", "", "", 0.5)
+		m, err := rule.MarshalJSON()
+		assert.NoError(t, err)
+		assert.Equal(t, `{"service":"^.*$","name":"^.*$","resource":"^GET /health$","tags":{"customer.tier":"^premium$"},"sample_rate":0.5,"type":"trace(0)"}`, string(m))
+	})
+}
@@ -10,6 +10,7 @@ package tracer
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
@@ -46,9 +47,11 @@ type (
 )
 
 var (
-	_ ddtrace.Span   = (*span)(nil)
-	_ msgp.Encodable = (*spanList)(nil)
-	_ msgp.Decodable = (*spanLists)(nil)
+	_ ddtrace.Span           = (*span)(nil)
+	_ ddtrace.SpanWithLinks  = (*span)(nil)
+	_ ddtrace.SpanWithEvents = (*span)(nil)
+	_ msgp.Encodable         = (*spanList)(nil)
+	_ msgp.Decodable         = (*spanLists)(nil)
 )
 
 // errorConfig holds customization options for setting error tags.
@@ -76,9 +79,13 @@ type span struct {
 	ParentID uint64             `msg:"parent_id"`         // identifier of the span's direct parent
 	Error    int32              `msg:"error"`             // error status of the span; 0 means no errors
 
-	noDebugStack bool         `msg:"-"` // disables debug stack traces
-	finished     bool         `msg:"-"` // true if the span has been submitted to a tracer.
-	context      *spanContext `msg:"-"` // span propagation context
+	SpanLinks []ddtrace.SpanLink `msg:"span_links,omitempty"` // links to other spans
+
+	noDebugStack bool                `msg:"-"` // disables debug stack traces
+	finished     bool                `msg:"-"` // true if the span has been submitted to a tracer.
+	context      *spanContext        `msg:"-"` // span propagation context
+	events       []ddtrace.SpanEvent `msg:"-"` // events recorded on the span; serialized into Meta on finish
+	errorCapture *ErrorCaptureConfig `msg:"-"` // configures the recording of errors; defaults apply when nil
 
 	pprofCtxActive  context.Context `msg:"-"` // contains pprof.WithLabel labels to tell the profiler more about this span
 	pprofCtxRestore context.Context `msg:"-"` // contains pprof.WithLabel labels of the parent span (if any) that need to be restored when this span finishes
@@ -163,6 +170,47 @@ func (s *span) SetTag(key string, value interface{}) {
 	s.setMeta(key, fmt.Sprint(value))
 }
 
+// AddLink adds a link to another span. Links have no effect on the trace of
+// the span and can point to spans of other traces. Links added after the span
+// has finished are ignored.
+func (s *span) AddLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	// We don't lock spans when flushing, so we could have a data race when
+	// modifying a span as it's being flushed. This protects us against that
+	// race, since spans are marked `finished` before we flush them.
+	if s.finished {
+		return
+	}
+	s.SpanLinks = append(s.SpanLinks, link)
+}
+
+// AddEvent records an event with the given name on the span, such as an
+// exception having occurred. Events are serialized with the span when it
+// finishes. Events added after the span has finished are ignored.
+func (s *span) AddEvent(name string, opts ...ddtrace.SpanEventOption) {
+	var cfg ddtrace.SpanEventConfig
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.Time.IsZero() {
+		cfg.Time = time.Unix(0, now())
+	}
+	s.Lock()
+	defer s.Unlock()
+	// We don't lock spans when flushing, so we could have a data race when
+	// modifying a span as it's being flushed. This protects us against that
+	// race, since spans are marked `finished` before we flush them.
+	if s.finished {
+		return
+	}
+	s.events = append(s.events, ddtrace.SpanEvent{
+		Name:         name,
+		TimeUnixNano: uint64(cfg.Time.UnixNano()),
+		Attributes:   cfg.Attributes,
+	})
+}
+
 // setSamplingPriority locks then span, then updates the sampling priority.
 // It also updates the trace's sampling priority.
 func (s *span) setSamplingPriority(priority int, sampler samplernames.SamplerName) {
@@ -262,6 +310,9 @@ func (s *span) setTagError(value interface{}, cfg errorConfig) {
 			if s.Error == 0 {
 				// new error
 				atomic.AddInt32(&s.context.errors, 1)
+				if s.context.trace != nil {
+					atomic.StoreUint32(&s.context.trace.errored, 1)
+				}
 			}
 			s.Error = 1
 		} else {
@@ -282,12 +333,32 @@ func (s *span) setTagError(value interface{}, cfg errorConfig) {
 	case error:
 		// if anyone sets an error value as the tag, be nice here
 		// and provide all the benefits.
+		typ := reflect.TypeOf(v).String()
+		var panicked bool
+		if p, ok := v.(*panicError); ok {
+			v, typ, panicked = p.err, reflect.TypeOf(p.value).String(), true
+		}
+		if !s.errorCapture.isError(v) {
+			return
+		}
 		setError(true)
 		s.setMeta(ext.ErrorMsg, v.Error())
-		s.setMeta(ext.ErrorType, reflect.TypeOf(v).String())
+		s.setMeta(ext.ErrorType, typ)
+		frames := s.errorCapture.fingerprintFrames()
+		if cfg.noDebugStack {
+			// no stack walk when stack traces are disabled
+			frames = 0
+		}
+		s.setMeta(ext.ErrorFingerprint, errorFingerprint(typ, frames, cfg.stackSkip))
+		if chain := errorChain(v, s.errorCapture.chainDepth()); chain != "" {
+			s.setMeta(ext.ErrorChain, chain)
+		}
 		if !cfg.noDebugStack {
 			s.setMeta(ext.ErrorStack, takeStacktrace(cfg.stackFrames, cfg.stackSkip))
 		}
+		if panicked && s.errorCapture.panicStacks() {
+			s.setMeta(ext.ErrorGoroutineStacks, takeGoroutineStacks())
+		}
 		switch v.(type) {
 		case xerrors.Formatter:
 			s.setMeta(ext.ErrorDetails, fmt.Sprintf("%+v", v))
@@ -440,6 +511,24 @@ t := now()
 
 		s.taskEnd()
 	}
+	if tr, ok := internal.GetGlobalTracer().(*tracer); ok && s.context.trace != nil && s.context.trace.root == s {
+		tr.sampleDeferred(s.context, t-s.Start)
+	}
+	if lt := traceprof.GlobalLatencyTrigger(); lt.Enabled() && s.context.trace != nil && s.context.trace.root == s {
+		// Inform the profiler of slow requests, for it to collect execution
+		// traces of the following ones.
+		s.RLock()
+		slow := traceprof.SlowSpan{
+			TraceID:  s.TraceID,
+			SpanID:   s.SpanID,
+			Duration: time.Duration(t - s.Start),
+		}
+		if spanResourcePIISafe(s) {
+			slow.Endpoint = s.Resource
+		}
+		s.RUnlock()
+		lt.Observe(slow)
+	}
 	log.Debug("calling inner finish")
 
 	s.finish(t)
@@ -487,17 +576,25 @@ func (s *span) finish(finishTime int64) {
 		s.Duration = 0
 	}
 	s.finished = true
+	if len(s.events) > 0 {
+		if events, err := json.Marshal(s.events); err != nil {
+			log.Error("Error marshaling span events: %v", err)
+		} else {
+			s.setMeta(keySpanEvents, string(events))
+		}
+	}
 
 	keep := true
 	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
 		// we have an active tracer
 		log.Debug("have an active tracer")
-		if t.config.canComputeStats() && shouldComputeStats(s) {
+		setPeerService(s, t.config)
+		if t.config.canComputeStats() && shouldComputeStats(s, t.config.agent.SpanKindStats) {
 			log.Debug("in shouldComputeStats")
 
 			// the agent supports computed stats
 			select {
-			case t.stats.In <- newAggregableSpan(s, t.obfuscator):
+			case t.stats.In <- newAggregableSpan(s, t.obfuscator, t.config.agent.PeerTags):
 				// ok
 			default:
 				log.Error("Stats channel full, disregarding span.")
@@ -529,8 +626,9 @@ func (s *span) finish(finishTime int64) {
 }
 
 // newAggregableSpan creates a new summary for the span s, within an application
-// version version.
-func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator) *aggregableSpan {
+// version version. Client, producer and consumer spans are aggregated by the values
+// of their peerTags.
+func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator, peerTags []string) *aggregableSpan {
 	var statusCode uint32
 	if sc, ok := s.Meta["http.status_code"]; ok && sc != "" {
 		if c, err := strconv.Atoi(sc); err == nil && c > 0 && c <= math.MaxInt32 {
@@ -538,12 +636,16 @@ func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator) *aggregableSpa
 		}
 	}
 	key := aggregation{
-		Name:       s.Name,
-		Resource:   obfuscatedResource(obfuscator, s.Type, s.Resource),
-		Service:    s.Service,
-		Type:       s.Type,
-		Synthetics: strings.HasPrefix(s.Meta[keyOrigin], "synthetics"),
-		StatusCode: statusCode,
+		Name:           s.Name,
+		Resource:       obfuscatedResource(obfuscator, s.Type, s.Resource),
+		Service:        s.Service,
+		Type:           s.Type,
+		Synthetics:     strings.HasPrefix(s.Meta[keyOrigin], "synthetics"),
+		StatusCode:     statusCode,
+		PeerService:    s.Meta[ext.PeerService],
+		SpanKind:       s.Meta[ext.SpanKind],
+		PeerTags:       spanPeerTags(s, peerTags),
+		GRPCStatusCode: grpcStatusCode(s),
 	}
 	return &aggregableSpan{
 		key:      key,
@@ -597,14 +699,22 @@ func shouldKeep(s *span) bool {
 }
 
 // shouldComputeStats mentions whether this span needs to have stats computed for.
+// Apart from top-level and measured spans, the stats of client, producer and consumer
+// spans are computed when byKind is set.
 // Warning: callers must guard!
-func shouldComputeStats(s *span) bool {
+func shouldComputeStats(s *span, byKind bool) bool {
 	if v, ok := s.Metrics[keyMeasured]; ok && v == 1 {
 		return true
 	}
 	if v, ok := s.Metrics[keyTopLevel]; ok && v == 1 {
 		return true
 	}
+	if byKind {
+		switch s.Meta[ext.SpanKind] {
+		case ext.SpanKindClient, ext.SpanKindProducer, ext.SpanKindConsumer:
+			return true
+		}
+	}
 	return false
 }
 
@@ -705,6 +815,17 @@ const (
 	keyTraceID128 = "_dd.p.tid"
 	// keySpanAttributeSchemaVersion holds the selected DD_TRACE_SPAN_ATTRIBUTE_SCHEMA version.
 	keySpanAttributeSchemaVersion = "_dd.trace_span_attribute_schema"
+	// keyAdaptiveSamplerRate holds the rate applied by the adaptive sampler.
+	keyAdaptiveSamplerRate = "_dd.adaptive_psr"
+	// keyPeerServiceSource holds the name of the tag peer.service was taken from.
+	keyPeerServiceSource = "_dd.peer.service.source"
+	// keyPeerServiceRemappedFrom holds the original value of a remapped peer.service.
+	keyPeerServiceRemappedFrom = "_dd.peer.service.remapped_from"
+	// keySpanEvents holds the JSON encoded events recorded on the span, if any.
+	keySpanEvents = "events"
+	// keyReparentID holds the hex encoded ID of the last Datadog span of a trace continued
+	// through W3C trace context, when the remote parent isn't a Datadog span.
+	keyReparentID = "_dd.parent_id"
 )
 
 // The following set of tags is used for user monitoring and set through calls to span.SetUser().
@@ -15,9 +15,11 @@ import (
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
 	"github.com/stretchr/testify/assert"
@@ -157,9 +159,24 @@ func TestShouldComputeStats(t *testing.T) {
 		{map[string]float64{}, false},
 	} {
 		t.Run("", func(t *testing.T) {
-			assert.Equal(t, shouldComputeStats(&span{Metrics: tt.metrics}), tt.want)
+			assert.Equal(t, shouldComputeStats(&span{Metrics: tt.metrics}, false), tt.want)
 		})
 	}
+
+	t.Run("span-kind", func(t *testing.T) {
+		for kind, want := range map[string]bool{
+			ext.SpanKindClient:   true,
+			ext.SpanKindProducer: true,
+			ext.SpanKindConsumer: true,
+			ext.SpanKindServer:   false,
+			ext.SpanKindInternal: false,
+			"":                   false,
+		} {
+			s := &span{Meta: map[string]string{ext.SpanKind: kind}, Metrics: map[string]float64{}}
+			assert.Equal(t, want, shouldComputeStats(s, true), kind)
+			assert.False(t, shouldComputeStats(s, false), kind)
+		}
+	})
 }
 
 func TestNewAggregableSpan(t *testing.T) {
@@ -170,7 +187,7 @@ func TestNewAggregableSpan(t *testing.T) {
 			Resource: "SELECT * FROM table WHERE password='secret'",
 			Service:  "service",
 			Type:     "sql",
-		}, o)
+		}, o, nil)
 		assert.Equal(t, aggregation{
 			Name:     "name",
 			Type:     "sql",
@@ -185,7 +202,7 @@ func TestNewAggregableSpan(t *testing.T) {
 			Resource: "SELECT * FROM table WHERE password='secret'",
 			Service:  "service",
 			Type:     "sql",
-		}, nil)
+		}, nil, nil)
 		assert.Equal(t, aggregation{
 			Name:     "name",
 			Type:     "sql",
@@ -193,6 +210,74 @@ func TestNewAggregableSpan(t *testing.T) {
 			Service:  "service",
 		}, aggspan.key)
 	})
+
+	t.Run("peer-service", func(t *testing.T) {
+		aggspan := newAggregableSpan(&span{
+			Name:     "redis.command",
+			Resource: "GET",
+			Service:  "service",
+			Meta:     map[string]string{ext.PeerService: "cache"},
+		}, nil, nil)
+		assert.Equal(t, aggregation{
+			Name:        "redis.command",
+			Resource:    "GET",
+			Service:     "service",
+			PeerService: "cache",
+		}, aggspan.key)
+	})
+
+	t.Run("peer-tags", func(t *testing.T) {
+		aggspan := newAggregableSpan(&span{
+			Name:     "redis.command",
+			Resource: "GET",
+			Service:  "service",
+			Meta: map[string]string{
+				ext.SpanKind:    ext.SpanKindClient,
+				ext.PeerService: "cache",
+				ext.DBSystem:    ext.DBSystemRedis,
+				ext.DBInstance:  "users",
+			},
+		}, nil, defaultStatsPeerTags)
+		assert.Equal(t, aggregation{
+			Name:        "redis.command",
+			Resource:    "GET",
+			Service:     "service",
+			PeerService: "cache",
+			SpanKind:    ext.SpanKindClient,
+			PeerTags:    "peer.service:cache" + peerTagsSep + "db.system:redis",
+		}, aggspan.key)
+		gs, err := newRawGroupedStats().export(aggspan.key)
+		assert.NoError(t, err)
+		assert.Equal(t, "cache", gs.PeerService)
+		assert.Equal(t, []string{"peer.service:cache", "db.system:redis"}, gs.PeerTags)
+	})
+
+	t.Run("peer-tags-server", func(t *testing.T) {
+		aggspan := newAggregableSpan(&span{
+			Name: "http.request",
+			Meta: map[string]string{ext.SpanKind: ext.SpanKindServer, ext.PeerService: "cache"},
+		}, nil, defaultStatsPeerTags)
+		assert.Equal(t, ext.SpanKindServer, aggspan.key.SpanKind)
+		assert.Empty(t, aggspan.key.PeerTags)
+	})
+
+	t.Run("grpc-status-code", func(t *testing.T) {
+		for _, tt := range []struct {
+			meta    map[string]string
+			metrics map[string]float64
+			want    string
+		}{
+			{meta: map[string]string{keyGRPCCode: "NotFound"}, want: "5"},
+			{meta: map[string]string{keyGRPCCode: "CANCELLED"}, want: "1"},
+			{meta: map[string]string{keyRPCGRPCStatusCode: "14"}, want: "14"},
+			{metrics: map[string]float64{keyRPCGRPCStatusCode: 3}, want: "3"},
+			{meta: map[string]string{keyGRPCCode: "bogus"}, want: ""},
+			{want: ""},
+		} {
+			aggspan := newAggregableSpan(&span{Name: "grpc.client", Meta: tt.meta, Metrics: tt.metrics}, nil, nil)
+			assert.Equal(t, tt.want, aggspan.key.GRPCStatusCode)
+		}
+	})
 }
 
 func TestSpanFinishWithTime(t *testing.T) {
@@ -859,6 +944,81 @@ func TestSpanStartAndFinishLogs(t *testing.T) {
 	require.True(t, finished)
 }
 
+func TestSpanEvents(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	t.Run("add", func(t *testing.T) {
+		sp := tracer.StartSpan("op")
+		sp.(ddtrace.SpanWithEvents).AddEvent("first",
+			WithSpanEventTimestamp(time.Unix(0, 1)),
+			WithSpanEventAttributes(map[string]interface{}{"k": "v", "n": 2}))
+		sp.(ddtrace.SpanWithEvents).AddEvent("second", WithSpanEventTimestamp(time.Unix(0, 2)))
+		sp.Finish()
+		sp.(ddtrace.SpanWithEvents).AddEvent("ignored")
+		assert.Equal(t,
+			`[{"name":"first","time_unix_nano":1,"attributes":{"k":"v","n":2}},{"name":"second","time_unix_nano":2}]`,
+			sp.(*span).Meta[keySpanEvents])
+	})
+
+	t.Run("default-time", func(t *testing.T) {
+		start := time.Now()
+		sp := tracer.StartSpan("op")
+		sp.(ddtrace.SpanWithEvents).AddEvent("event")
+		events := sp.(*span).events
+		require.Len(t, events, 1)
+		assert.GreaterOrEqual(t, events[0].TimeUnixNano, uint64(start.UnixNano()))
+		sp.Finish()
+	})
+
+	t.Run("none", func(t *testing.T) {
+		sp := tracer.StartSpan("op")
+		sp.Finish()
+		assert.NotContains(t, sp.(*span).Meta, keySpanEvents)
+	})
+}
+
+func TestSpanLinks(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	link := ddtrace.SpanLink{
+		TraceID:    1,
+		SpanID:     2,
+		Attributes: map[string]string{"reason": "batch"},
+	}
+
+	t.Run("start-option", func(t *testing.T) {
+		sp := tracer.StartSpan("op", WithSpanLinks([]ddtrace.SpanLink{link}))
+		sp.Finish()
+		assert.Equal(t, []ddtrace.SpanLink{link}, sp.(*span).SpanLinks)
+	})
+
+	t.Run("add", func(t *testing.T) {
+		sp := tracer.StartSpan("op")
+		sp.(ddtrace.SpanWithLinks).AddLink(link)
+		sp.Finish()
+		sp.(ddtrace.SpanWithLinks).AddLink(ddtrace.SpanLink{TraceID: 3, SpanID: 4})
+		assert.Equal(t, []ddtrace.SpanLink{link}, sp.(*span).SpanLinks)
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		assert := assert.New(t)
+		withLinks := newBasicSpan("with-links")
+		withLinks.SpanLinks = []ddtrace.SpanLink{link, {TraceID: 5, TraceIDHigh: 6, SpanID: 7, Tracestate: "dd=s:1", Flags: 1 | 1<<31}}
+		withoutLinks := newBasicSpan("without-links")
+
+		p, err := encode([][]*span{{withLinks, withoutLinks}})
+		require.NoError(t, err)
+		got, err := decode(p)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		require.Len(t, got[0], 2)
+		assert.Equal(withLinks.SpanLinks, got[0][0].SpanLinks)
+		assert.Nil(got[0][1].SpanLinks)
+	})
+}
+
 func BenchmarkSetTagMetric(b *testing.B) {
 	span := newBasicSpan("bench.span")
 	keys := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -912,3 +1072,30 @@ type stringer struct{}
 func (s *stringer) String() string {
 	return "string"
 }
+
+func TestSpanLatencyTrigger(t *testing.T) {
+	var got []traceprof.SlowSpan
+	lt := traceprof.GlobalLatencyTrigger()
+	lt.Set(time.Second, nil, func(s traceprof.SlowSpan) { got = append(got, s) })
+	defer lt.Set(0, nil, nil)
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	start := time.Now().Add(-2 * time.Second)
+	root := tracer.StartSpan("web.request", StartTime(start), ResourceName("GET /foo"), SpanType(ext.SpanTypeWeb)).(*span)
+	child := tracer.StartSpan("db.query", ChildOf(root.Context()), StartTime(start)).(*span)
+	child.Finish()
+	root.Finish()
+	fast := tracer.StartSpan("web.request", ResourceName("GET /bar")).(*span)
+	fast.Finish()
+	sql := tracer.StartSpan("db.query", StartTime(start), ResourceName("SELECT 1"), SpanType(ext.SpanTypeSQL)).(*span)
+	sql.Finish()
+
+	require.Len(t, got, 2)
+	assert.Equal(t, root.TraceID, got[0].TraceID)
+	assert.Equal(t, root.SpanID, got[0].SpanID)
+	assert.Equal(t, "GET /foo", got[0].Endpoint)
+	assert.GreaterOrEqual(t, got[0].Duration, 2*time.Second)
+	assert.Equal(t, sql.TraceID, got[1].TraceID)
+	assert.Empty(t, got[1].Endpoint)
+}
@@ -96,6 +96,11 @@ type spanContext struct {
 	baggage    map[string]string
 	hasBaggage uint32 // atomic int for quick checking presence of baggage. 0 indicates no baggage, otherwise baggage exists.
 	origin     string // e.g. "synthetics"
+
+	// the below group is only set on extracted contexts
+
+	reparentID string             // ID of the last Datadog span, from the tracestate p: value
+	spanLinks  []ddtrace.SpanLink // links to the conflicting contexts found during extraction
 }
 
 // newSpanContext creates a new SpanContext to serve as context for the given
@@ -242,10 +247,13 @@ type trace struct {
 	tags             map[string]string // trace level tags
 	propagatingTags  map[string]string // trace level tags that will be propagated across service boundaries
 	finished         int               // the number of finished spans
+	finishedSpans    []*span           // the finished spans, tracked only when partial flushing is enabled
 	full             bool              // signifies that the span buffer is full
 	priority         *float64          // sampling priority
 	locked           bool              // specifies if the sampling priority can be altered
 	samplingDecision samplingDecision  // samplingDecision indicates whether to send the trace to the agent.
+	samplingDeferred uint32            // (atomic) set while the sampling decision is deferred to the root finish
+	errored          uint32            // (atomic) set once a span of the trace had an error
 
 	// root specifies the root of the trace, if known; it is nil when a span
 	// context is extracted from a carrier, at which point there are no spans in
@@ -300,6 +308,22 @@ func (t *trace) drop() {
 	atomic.CompareAndSwapUint32((*uint32)(&t.samplingDecision), uint32(decisionNone), uint32(decisionDrop))
 }
 
+// deferSampling defers the sampling decision of the trace to the finish of its root.
+func (t *trace) deferSampling() {
+	atomic.StoreUint32(&t.samplingDeferred, 1)
+}
+
+// samplingIsDeferred reports whether the sampling decision of the trace is deferred.
+func (t *trace) samplingIsDeferred() bool {
+	return atomic.LoadUint32(&t.samplingDeferred) == 1
+}
+
+// undeferSampling reports whether the sampling decision of the trace was deferred,
+// in which case it's up to the caller to make it.
+func (t *trace) undeferSampling() bool {
+	return atomic.CompareAndSwapUint32(&t.samplingDeferred, 1, 0)
+}
+
 func (t *trace) setTag(key, value string) {
 	if t.tags == nil {
 		t.tags = make(map[string]string, 1)
@@ -387,7 +411,9 @@ func (t *trace) push(sp *span) {
 
 // finishedOne acknowledges that another span in the trace has finished, and checks
 // if the trace is complete, in which case it calls the onFinish function. It uses
-// the given priority, if non-nil, to mark the root span.
+// the given priority, if non-nil, to mark the root span. When partial flushing is
+// enabled and enough spans have finished, the finished spans are flushed as a chunk
+// of the trace before the trace is complete.
 func (t *trace) finishedOne(s *span) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -399,6 +425,11 @@ func (t *trace) finishedOne(s *span) {
 		return
 	}
 	t.finished++
+	tr, haveTracer := internal.GetGlobalTracer().(*tracer)
+	partialFlush := haveTracer && tr.config.partialFlushEnabled
+	if partialFlush {
+		t.finishedSpans = append(t.finishedSpans, s)
+	}
 	if s == t.root && t.priority != nil {
 		// after the root has finished we lock down the priority;
 		// we won't be able to make changes to a span after finishing
@@ -412,28 +443,23 @@ func (t *trace) finishedOne(s *span) {
 		// TODO(barbayar): make sure this doesn't happen in vain when switching to
 		// the new wire format. We won't need to set the tags on the first span
 		// in the chunk there.
-		for k, v := range t.tags {
-			s.setMeta(k, v)
-		}
-		for k, v := range t.propagatingTags {
-			s.setMeta(k, v)
-		}
-		for k, v := range ginternal.GetTracerGitMetadataTags() {
-			s.setMeta(k, v)
-		}
+		t.setTraceTags(s)
 	}
 	if s.context != nil && s.context.traceID.HasUpper() {
 		s.setMeta(keyTraceID128, s.context.traceID.UpperHex())
 	}
 	if len(t.spans) != t.finished {
+		if partialFlush && t.finished >= tr.config.partialFlushMinSpans && len(t.finishedSpans) == t.finished {
+			t.flushPartial(tr, s)
+		}
 		return
 	}
 	defer func() {
 		t.spans = nil
+		t.finishedSpans = nil
 		t.finished = 0 // important, because a buffer can be used for several flushes
 	}()
-	tr, ok := internal.GetGlobalTracer().(*tracer)
-	if !ok {
+	if !haveTracer {
 		return
 	}
 	if hn := tr.hostname(); hn != "" {
@@ -446,3 +472,69 @@ func (t *trace) finishedOne(s *span) {
 		willSend: decisionKeep == samplingDecision(atomic.LoadUint32((*uint32)(&t.samplingDecision))),
 	})
 }
+
+// setTraceTags sets the trace level tags, the propagating tags and the git
+// metadata tags on s, which is expected to be the first span of a chunk.
+// It is not safe for concurrent use and s must not be modified concurrently.
+func (t *trace) setTraceTags(s *span) {
+	for k, v := range t.tags {
+		s.setMeta(k, v)
+	}
+	for k, v := range t.propagatingTags {
+		s.setMeta(k, v)
+	}
+	for k, v := range ginternal.GetTracerGitMetadataTags() {
+		s.setMeta(k, v)
+	}
+}
+
+// flushPartial pushes the finished spans of the trace to the tracer as a chunk,
+// keeping only the unfinished spans in the trace. The first span of the chunk
+// is given the trace level tags and the sampling priority of the trace, which
+// is locked down to keep it consistent across all the chunks of the trace.
+// s is the span that finished last and which is already locked by the caller.
+// It is not safe for concurrent use.
+func (t *trace) flushPartial(tr *tracer, s *span) {
+	log.Debug("Partial flush triggered with %d finished spans", t.finished)
+	finished := make(map[*span]struct{}, len(t.finishedSpans))
+	for _, fs := range t.finishedSpans {
+		finished[fs] = struct{}{}
+	}
+	chunk := make([]*span, 0, len(t.finishedSpans))
+	leftover := make([]*span, 0, len(t.spans)-len(t.finishedSpans))
+	for _, sp := range t.spans {
+		if _, ok := finished[sp]; ok {
+			chunk = append(chunk, sp)
+		} else {
+			leftover = append(leftover, sp)
+		}
+	}
+	t.locked = true
+	first := chunk[0]
+	if first != s {
+		// finished spans can still be read by users, so we lock the
+		// span before modifying it; s is already locked by its caller.
+		first.Lock()
+		defer first.Unlock()
+	}
+	if first != t.spans[0] {
+		// the first span of the trace got its tags when it finished
+		t.setTraceTags(first)
+	}
+	if t.priority != nil {
+		first.setMetric(keySamplingPriority, *t.priority)
+	}
+	if hn := tr.hostname(); hn != "" {
+		first.setMeta(keyTracerHostname, hn)
+	}
+	t.spans = leftover
+	t.finishedSpans = nil
+	t.finished = 0
+	atomic.AddUint32(&tr.spansFinished, uint32(len(chunk)))
+	atomic.AddUint32(&tr.partialFlushes, 1)
+	atomic.AddUint32(&tr.partialFlushSpans, uint32(len(chunk)))
+	tr.pushTrace(&finishedTrace{
+		spans:    chunk,
+		willSend: decisionKeep == samplingDecision(atomic.LoadUint32((*uint32)(&t.samplingDecision))),
+	})
+}
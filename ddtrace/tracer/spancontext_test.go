@@ -17,6 +17,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupteardown(start, max int) func() {
@@ -210,6 +211,87 @@ func TestSpanTracePushSeveral(t *testing.T) {
 	}
 }
 
+func TestPartialFlush(t *testing.T) {
+	t.Run("chunks", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		defer func(old time.Duration) { statsInterval = old }(statsInterval)
+		statsInterval = time.Millisecond
+		tracer, transport, flush, stop := startTestTracer(t, WithPartialFlushing(2), withStatsdClient(&tg))
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		children := make([]Span, 3)
+		for i := range children {
+			children[i] = tracer.StartSpan("child", ChildOf(root.Context()))
+		}
+		children[0].Finish()
+		children[1].Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		chunk := traces[0]
+		require.Len(t, chunk, 2)
+		assert.Equal(children[0].(*span).SpanID, chunk[0].SpanID)
+		assert.Equal(children[1].(*span).SpanID, chunk[1].SpanID)
+		// the first span of a chunk carries the trace level tags and priority
+		assert.Equal("-1", chunk[0].Meta[keyDecisionMaker])
+		assert.Equal(1., chunk[0].Metrics[keySamplingPriority])
+		assert.NotContains(chunk[1].Meta, keyDecisionMaker)
+		assert.Len(root.(*span).context.trace.spans, 2)
+
+		children[2].Finish()
+		root.Finish()
+		flush(1)
+
+		traces = transport.Traces()
+		require.Len(t, traces, 1)
+		chunk = traces[0]
+		require.Len(t, chunk, 2)
+		assert.Equal(root.(*span).SpanID, chunk[0].SpanID)
+		assert.Equal("-1", chunk[0].Meta[keyDecisionMaker])
+		assert.Equal(1., chunk[0].Metrics[keySamplingPriority])
+
+		tg.Wait(6, time.Second)
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.partial_flushes"])
+		assert.Equal(int64(2), tg.Counts()["datadog.tracer.partial_flush_spans"])
+	})
+
+	t.Run("priority-locked", func(t *testing.T) {
+		tracer, transport, flush, stop := startTestTracer(t, WithPartialFlushing(1))
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		child := tracer.StartSpan("child", ChildOf(root.Context()))
+		child.Finish()
+		flush(1)
+		require.Len(t, transport.Traces(), 1)
+		// the priority was sent with the first chunk and can't change anymore
+		root.SetTag(ext.ManualKeep, true)
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		assert.Equal(t, 1., traces[0][0].Metrics[keySamplingPriority])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, transport, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		for i := 0; i < 3; i++ {
+			tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		}
+		tracer.flushSync()
+		assert.Equal(t, 0, transport.Len())
+		assert.Len(t, root.(*span).context.trace.spans, 4)
+		root.Finish()
+	})
+}
+
 // TestSpanFinishPriority asserts that the root span will have the sampling
 // priority metric set by inheriting it from a child.
 func TestSpanFinishPriority(t *testing.T) {
@@ -0,0 +1,236 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// spoolMaxBytesDefault is the default maximum size of the spooled payloads.
+	spoolMaxBytesDefault = 64 << 20 // 64MB
+
+	// spoolMaxAgeDefault is the default maximum age of the spooled payloads.
+	spoolMaxAgeDefault = time.Hour
+
+	// spoolFileExt is the extension of the files holding spooled payloads.
+	spoolFileExt = ".msgp"
+
+	// spoolReplayBatch is the maximum number of spooled payloads replayed after
+	// a successful flush, to spread the replay of a large spool over several
+	// flushes.
+	spoolReplayBatch = 8
+)
+
+// traceSpool is a bounded on-disk buffer of encoded payloads which could not be
+// sent to the agent. Spooled payloads are replayed, oldest first, once the agent
+// is reachable again, including by a later process using the same directory.
+// Payloads are evicted, oldest first, once they exceed maxAge or when the total
+// size of the spool exceeds maxBytes.
+//
+// Each payload is stored in its own file named <timestamp>-<seq>-<traces>-<protocol>.msgp
+// which holds the complete encoded payload, as sent to the agent. Files named
+// <timestamp>-<seq>-<traces>.msgp, spooled by earlier versions of the tracer, hold
+// v0.4 msgpack-encoded traces without the array header; they are replayed and
+// evicted as well.
+type traceSpool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	statsd   statsdClient
+
+	// mu guards the files in dir.
+	mu sync.Mutex
+
+	// seq is incremented for every stored payload, to keep the names of the
+	// files unique. It is guarded by mu.
+	seq uint64
+
+	// replaying is 1 while a replay is in progress. It is accessed atomically.
+	replaying uint32
+}
+
+func newTraceSpool(dir string, maxBytes int64, maxAge time.Duration, statsd statsdClient) (*traceSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &traceSpool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		statsd:   statsd,
+	}, nil
+}
+
+// spoolEntry describes a spooled payload.
+type spoolEntry struct {
+	name     string
+	created  time.Time
+	traces   int
+	protocol float64
+	size     int64
+	legacy   bool // the file holds traces without the array header
+}
+
+// payload returns the payload holding data, the contents of the file of e.
+func (e spoolEntry) payload(data []byte) *payload {
+	if !e.legacy {
+		return newRawPayload(e.protocol, e.traces, data)
+	}
+	p := newPayload()
+	p.buf.Write(data)
+	p.count = uint32(e.traces)
+	p.updateHeader()
+	return p
+}
+
+// entries returns the spooled payloads, oldest first.
+func (s *traceSpool) entries() []spoolEntry {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Error("Unable to read trace spool directory: %v", err)
+		return nil
+	}
+	var entries []spoolEntry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, spoolFileExt) {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(name, spoolFileExt), "-")
+		legacy := len(parts) == 3
+		if len(parts) != 4 && !legacy {
+			continue
+		}
+		nanos, err1 := strconv.ParseInt(parts[0], 10, 64)
+		n, err2 := strconv.Atoi(parts[2])
+		protocol := float64(traceProtocolV04)
+		var err3 error
+		if !legacy {
+			protocol, err3 = strconv.ParseFloat(parts[3], 64)
+		}
+		info, err4 := f.Info()
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		entries = append(entries, spoolEntry{
+			name:     name,
+			created:  time.Unix(0, nanos),
+			traces:   n,
+			protocol: protocol,
+			size:     info.Size(),
+			legacy:   legacy,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+// store persists the contents of p to disk, evicting older payloads as needed.
+func (s *traceSpool) store(p *payload) error {
+	p.reset()
+	data, err := io.ReadAll(p)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.maxBytes {
+		s.statsd.Incr("datadog.tracer.spool.evicted", []string{"reason:max_bytes"}, 1)
+		return fmt.Errorf("payload of %d bytes exceeds the spool size limit of %d bytes", len(data), s.maxBytes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	protocol := strconv.FormatFloat(p.protocol, 'f', -1, 64)
+	name := fmt.Sprintf("%020d-%010d-%d-%s%s", now(), s.seq, p.itemCount(), protocol, spoolFileExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.statsd.Incr("datadog.tracer.spool.spooled", nil, 1)
+	s.evictLocked(time.Now())
+	return nil
+}
+
+// evictLocked removes the spooled payloads which are older than maxAge, as well
+// as the oldest payloads until the total size of the spool is within maxBytes.
+// s.mu must be held.
+func (s *traceSpool) evictLocked(at time.Time) {
+	entries := s.entries()
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	for _, e := range entries {
+		var reason string
+		switch {
+		case at.Sub(e.created) > s.maxAge:
+			reason = "max_age"
+		case total > s.maxBytes:
+			reason = "max_bytes"
+		default:
+			// entries are sorted from oldest to newest, so the rest are kept
+			return
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.name)); err != nil && !os.IsNotExist(err) {
+			log.Error("Unable to evict spooled traces: %v", err)
+			continue
+		}
+		total -= e.size
+		s.statsd.Incr("datadog.tracer.spool.evicted", []string{"reason:" + reason}, 1)
+		s.statsd.Count("datadog.tracer.traces_dropped", int64(e.traces), []string{"reason:spool_evicted"}, 1)
+	}
+}
+
+// replay sends at most max spooled payloads using send, oldest first, and removes
+// them once sent. It stops at the first failure, leaving the remaining payloads in
+// the spool. Only one replay may run at a time; concurrent calls return immediately.
+func (s *traceSpool) replay(send func(p *payload) error, max int) {
+	if !atomic.CompareAndSwapUint32(&s.replaying, 0, 1) {
+		return
+	}
+	defer atomic.StoreUint32(&s.replaying, 0)
+
+	s.mu.Lock()
+	s.evictLocked(time.Now())
+	entries := s.entries()
+	s.mu.Unlock()
+	if len(entries) > max {
+		entries = entries[:max]
+	}
+	for _, e := range entries {
+		path := filepath.Join(s.dir, e.name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// the payload was evicted in the meantime
+			continue
+		}
+		if err := send(e.payload(data)); err != nil {
+			log.Debug("Unable to replay spooled traces, will retry later: %v", err)
+			return
+		}
+		s.mu.Lock()
+		os.Remove(path)
+		s.mu.Unlock()
+		s.statsd.Incr("datadog.tracer.spool.replayed", nil, 1)
+	}
+}
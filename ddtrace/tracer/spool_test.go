@@ -0,0 +1,257 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outageTransport is a dummyTransport which fails to send payloads while down.
+type outageTransport struct {
+	*dummyTransport
+
+	mu   sync.Mutex
+	down bool
+}
+
+func (t *outageTransport) setDown(down bool) {
+	t.mu.Lock()
+	t.down = down
+	t.mu.Unlock()
+}
+
+func (t *outageTransport) send(p *payload) (io.ReadCloser, error) {
+	t.mu.Lock()
+	down := t.down
+	t.mu.Unlock()
+	if down {
+		return nil, errors.New("connection refused")
+	}
+	return t.dummyTransport.send(p)
+}
+
+func spoolPayload(t *testing.T, traces ...[]*span) *payload {
+	p, err := encode(traces)
+	require.NoError(t, err)
+	return p
+}
+
+func TestTraceSpool(t *testing.T) {
+	t.Run("replay", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		s, err := newTraceSpool(t.TempDir(), spoolMaxBytesDefault, spoolMaxAgeDefault, &tg)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(1)}, []*span{makeSpan(1)})))
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(2)})))
+		entries := s.entries()
+		require.Len(t, entries, 2)
+		assert.Equal(2, entries[0].traces)
+		assert.Equal(1, entries[1].traces)
+
+		var got []spanLists
+		s.replay(func(p *payload) error {
+			traces, err := decode(p)
+			got = append(got, traces)
+			return err
+		}, spoolReplayBatch)
+		require.Len(t, got, 2)
+		assert.Len(got[0], 2)
+		assert.Len(got[1], 1)
+		assert.Len(got[1][0][0].Meta, 2)
+		assert.Empty(s.entries())
+		assert.Equal(int64(2), tg.Counts()["datadog.tracer.spool.spooled"])
+		assert.Equal(int64(2), tg.Counts()["datadog.tracer.spool.replayed"])
+	})
+
+	t.Run("replay-failure", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		s, err := newTraceSpool(t.TempDir(), spoolMaxBytesDefault, spoolMaxAgeDefault, &tg)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		var attempts int
+		s.replay(func(p *payload) error {
+			attempts++
+			return errors.New("connection refused")
+		}, spoolReplayBatch)
+		assert.Equal(1, attempts)
+		assert.Len(s.entries(), 2)
+		assert.Equal(int64(0), tg.Counts()["datadog.tracer.spool.replayed"])
+	})
+
+	t.Run("replay-batch", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		s, err := newTraceSpool(t.TempDir(), spoolMaxBytesDefault, spoolMaxAgeDefault, &tg)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		}
+		last := s.entries()[2].name
+		var sent int
+		send := func(p *payload) error {
+			sent++
+			return nil
+		}
+		s.replay(send, 2)
+		assert.Equal(2, sent)
+		entries := s.entries()
+		require.Len(t, entries, 1)
+		assert.Equal(last, entries[0].name)
+
+		s.replay(send, 2)
+		assert.Equal(3, sent)
+		assert.Empty(s.entries())
+	})
+
+	t.Run("max-bytes", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		p := spoolPayload(t, []*span{makeSpan(0)})
+		size := int64(p.size())
+		s, err := newTraceSpool(t.TempDir(), 2*size, spoolMaxAgeDefault, &tg)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		first := s.entries()[0].name
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		entries := s.entries()
+		require.Len(t, entries, 2)
+		assert.NotEqual(first, entries[0].name)
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.spool.evicted"])
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.traces_dropped"])
+
+		assert.Error(s.store(spoolPayload(t, []*span{makeSpan(100)})))
+		assert.Len(s.entries(), 2)
+	})
+
+	t.Run("max-age", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		s, err := newTraceSpool(t.TempDir(), spoolMaxBytesDefault, time.Minute, &tg)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store(spoolPayload(t, []*span{makeSpan(0)})))
+		s.mu.Lock()
+		s.evictLocked(time.Now().Add(2 * time.Minute))
+		s.mu.Unlock()
+		assert.Empty(s.entries())
+		assert.Equal(int64(1), tg.Counts()["datadog.tracer.spool.evicted"])
+	})
+
+	t.Run("legacy-names", func(t *testing.T) {
+		assert := assert.New(t)
+		dir := t.TempDir()
+		var tg testStatsdClient
+		s, err := newTraceSpool(dir, spoolMaxBytesDefault, spoolMaxAgeDefault, &tg)
+		require.NoError(t, err)
+
+		// files spooled before the protocol was part of the name hold the
+		// traces without the array header
+		p := spoolPayload(t, []*span{makeSpan(1)}, []*span{makeSpan(1)})
+		name := fmt.Sprintf("%020d-%010d-%d%s", now(), 1, 2, spoolFileExt)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), p.buf.Bytes(), 0o600))
+		entries := s.entries()
+		require.Len(t, entries, 1)
+		assert.True(entries[0].legacy)
+		assert.Equal(traceProtocolV04, entries[0].protocol)
+
+		var got spanLists
+		s.replay(func(p *payload) error {
+			got, err = decode(p)
+			return err
+		}, spoolReplayBatch)
+		assert.Len(got, 2)
+		assert.Empty(s.entries())
+	})
+
+	t.Run("ignores-other-files", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(dir+"/notes.txt", []byte("hello"), 0o600))
+		require.NoError(t, os.WriteFile(dir+"/bad"+spoolFileExt, []byte("hello"), 0o600))
+		s, err := newTraceSpool(dir, spoolMaxBytesDefault, spoolMaxAgeDefault, &testStatsdClient{})
+		require.NoError(t, err)
+		assert.Empty(t, s.entries())
+	})
+}
+
+func TestTraceWriterSpool(t *testing.T) {
+	assert := assert.New(t)
+	transport := &outageTransport{dummyTransport: newDummyTransport(), down: true}
+	c := newConfig(func(c *config) {
+		c.transport = transport
+	}, WithTraceSpool(t.TempDir(), 0, 0))
+	var tg testStatsdClient
+	h := newAgentTraceWriter(c, nil, &tg)
+	require.NotNil(t, h.spool)
+
+	// the agent is down: the traces are spooled instead of being dropped
+	h.add([]*span{makeSpan(0)})
+	h.flush()
+	h.wg.Wait()
+	assert.Len(h.spool.entries(), 1)
+	assert.Equal(int64(0), tg.Counts()["datadog.tracer.traces_dropped"])
+
+	// the agent is back: the next successful flush replays the spooled traces
+	transport.setDown(false)
+	h.add([]*span{makeSpan(0)})
+	h.flush()
+	h.wg.Wait()
+	assert.Empty(h.spool.entries())
+	assert.Equal(2, transport.Len())
+	assert.Equal(int64(1), tg.Counts()["datadog.tracer.spool.replayed"])
+	assert.Equal(int64(2), tg.Counts()["datadog.tracer.flush_traces"])
+}
+
+func TestTraceSpoolConfig(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		c := newConfig()
+		assert.Empty(t, c.spoolDir)
+		assert.Equal(t, int64(spoolMaxBytesDefault), c.spoolMaxBytes)
+		assert.Equal(t, spoolMaxAgeDefault, c.spoolMaxAge)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_SPOOL_DIR", "/tmp/spool")
+		t.Setenv("DD_TRACE_SPOOL_MAX_BYTES", "1024")
+		t.Setenv("DD_TRACE_SPOOL_MAX_AGE", "5m")
+		c := newConfig()
+		assert.Equal(t, "/tmp/spool", c.spoolDir)
+		assert.Equal(t, int64(1024), c.spoolMaxBytes)
+		assert.Equal(t, 5*time.Minute, c.spoolMaxAge)
+	})
+
+	t.Run("env-invalid", func(t *testing.T) {
+		t.Setenv("DD_TRACE_SPOOL_MAX_BYTES", "-1")
+		t.Setenv("DD_TRACE_SPOOL_MAX_AGE", "0s")
+		c := newConfig()
+		assert.Equal(t, int64(spoolMaxBytesDefault), c.spoolMaxBytes)
+		assert.Equal(t, spoolMaxAgeDefault, c.spoolMaxAge)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		c := newConfig(WithTraceSpool("/tmp/spool", 2048, time.Minute))
+		assert.Equal(t, "/tmp/spool", c.spoolDir)
+		assert.Equal(t, int64(2048), c.spoolMaxBytes)
+		assert.Equal(t, time.Minute, c.spoolMaxAge)
+	})
+}
@@ -8,10 +8,13 @@
 package tracer
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	"github.com/DataDog/datadog-go/v5/statsd"
@@ -219,6 +222,13 @@ type aggregation struct {
 	Service    string
 	StatusCode uint32
 	Synthetics bool
+	// PeerService is the peer.service of client and producer spans.
+	PeerService string
+	SpanKind    string
+	// PeerTags holds the peer tags of client, producer and consumer spans as
+	// "key:value" pairs separated by peerTagsSep, so that the key stays comparable.
+	PeerTags       string
+	GRPCStatusCode string
 }
 
 type rawBucket struct {
@@ -334,9 +344,133 @@ func (s *rawGroupedStats) export(k aggregation) (groupedStats, error) {
 		OkSummary:      okSummary,
 		ErrorSummary:   errSummary,
 		Synthetics:     k.Synthetics,
+		PeerService:    k.PeerService,
+		SpanKind:       k.SpanKind,
+		PeerTags:       splitPeerTags(k.PeerTags),
+		GRPCStatusCode: k.GRPCStatusCode,
 	}, nil
 }
 
+// defaultStatsPeerTags lists the tags client, producer and consumer spans are
+// aggregated by, when the agent supports peer tags aggregation.
+var defaultStatsPeerTags = []string{ext.PeerService, ext.DBSystem, ext.TargetHost}
+
+// peerTagsSep separates the peer tags of an aggregation key.
+const peerTagsSep = "\x00"
+
+// mergePeerTags returns the tags of configured followed by the tags of agent which
+// are not part of configured.
+func mergePeerTags(configured, agent []string) []string {
+	tags := append([]string(nil), configured...)
+	for _, t := range agent {
+		if t != "" && !containsString(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// spanPeerTags returns the peer tags of s out of the given tags, joined by peerTagsSep.
+// Only client, producer and consumer spans have peer tags.
+func spanPeerTags(s *span, tags []string) string {
+	switch s.Meta[ext.SpanKind] {
+	case ext.SpanKindClient, ext.SpanKindProducer, ext.SpanKindConsumer:
+	default:
+		return ""
+	}
+	var b strings.Builder
+	for _, t := range tags {
+		v := s.Meta[t]
+		if v == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(peerTagsSep)
+		}
+		b.WriteString(t)
+		b.WriteByte(':')
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// splitPeerTags returns the peer tags joined by spanPeerTags.
+func splitPeerTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, peerTagsSep)
+}
+
+const (
+	// keyGRPCCode holds the name of the status code of gRPC spans, as set by
+	// the gRPC integrations.
+	keyGRPCCode = "grpc.code"
+	// keyRPCGRPCStatusCode holds the numeric status code of gRPC spans, as set
+	// by OpenTelemetry instrumentations.
+	keyRPCGRPCStatusCode = "rpc.grpc.status_code"
+)
+
+// grpcCodes maps the upper-cased names of the gRPC status codes to their value.
+var grpcCodes = map[string]string{
+	"OK":                  "0",
+	"CANCELED":            "1",
+	"CANCELLED":           "1",
+	"UNKNOWN":             "2",
+	"INVALIDARGUMENT":     "3",
+	"INVALID_ARGUMENT":    "3",
+	"DEADLINEEXCEEDED":    "4",
+	"DEADLINE_EXCEEDED":   "4",
+	"NOTFOUND":            "5",
+	"NOT_FOUND":           "5",
+	"ALREADYEXISTS":       "6",
+	"ALREADY_EXISTS":      "6",
+	"PERMISSIONDENIED":    "7",
+	"PERMISSION_DENIED":   "7",
+	"RESOURCEEXHAUSTED":   "8",
+	"RESOURCE_EXHAUSTED":  "8",
+	"FAILEDPRECONDITION":  "9",
+	"FAILED_PRECONDITION": "9",
+	"ABORTED":             "10",
+	"OUTOFRANGE":          "11",
+	"OUT_OF_RANGE":        "11",
+	"UNIMPLEMENTED":       "12",
+	"INTERNAL":            "13",
+	"UNAVAILABLE":         "14",
+	"DATALOSS":            "15",
+	"DATA_LOSS":           "15",
+	"UNAUTHENTICATED":     "16",
+}
+
+// grpcStatusCode returns the numeric gRPC status code of s, or "" if s has none.
+func grpcStatusCode(s *span) string {
+	if v, ok := s.Metrics[keyRPCGRPCStatusCode]; ok {
+		return strconv.FormatUint(uint64(v), 10)
+	}
+	for _, k := range []string{keyRPCGRPCStatusCode, keyGRPCCode} {
+		v := s.Meta[k]
+		if v == "" {
+			continue
+		}
+		if c, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return strconv.FormatUint(c, 10)
+		}
+		if c, ok := grpcCodes[strings.ToUpper(v)]; ok {
+			return c
+		}
+	}
+	return ""
+}
+
 // nsTimestampToFloat converts a nanosec timestamp into a float nanosecond timestamp truncated to a fixed precision
 func nsTimestampToFloat(ns int64) float64 {
 	// 10 bits precision (any value will be +/- 1/1024)
@@ -53,4 +53,9 @@ type groupedStats struct {
 	ErrorSummary []byte `json:"errorSummary,omitempty"`
 	Synthetics   bool   `json:"synthetics,omitempty"`
 	TopLevelHits uint64 `json:"topLevelHits,omitempty"`
+	PeerService  string `json:"peerService,omitempty"`
+	SpanKind     string `json:"spanKind,omitempty"`
+	// PeerTags holds the peer tags of the aggregation, in the form "key:value".
+	PeerTags       []string `json:"peerTags,omitempty"`
+	GRPCStatusCode string   `json:"GRPCStatusCode,omitempty"`
 }
@@ -7,6 +7,7 @@ package tracer
 
 import (
 	"fmt"
+	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
@@ -46,11 +47,24 @@ func startTelemetry(c *config) {
 		{Name: "agent_url", Value: c.agentURL.String()},
 		{Name: "agent_hostname", Value: c.hostname},
 		{Name: "runtime_metrics_enabled", Value: c.runtimeMetrics},
+		{Name: "runtime_metrics_v2_enabled", Value: c.runtimeMetricsV2},
 		{Name: "dogstatsd_addr", Value: c.dogstatsdAddr},
 		{Name: "trace_debug_enabled", Value: !c.noDebugStack},
 		{Name: "profiling_hotspots_enabled", Value: c.profilerHotspots},
 		{Name: "profiling_endpoints_enabled", Value: c.profilerEndpoints},
 		{Name: "trace_enabled", Value: c.enabled},
+		{Name: "trace_partial_flush_enabled", Value: c.partialFlushEnabled},
+		{Name: "trace_partial_flush_min_spans", Value: c.partialFlushMinSpans},
+		{Name: "trace_otlp_endpoint", Value: c.otlpEndpoint},
+		{Name: "trace_spool_enabled", Value: c.spoolDir != ""},
+		{Name: "trace_agent_protocol_version", Value: c.traceProtocol},
+		{Name: "trace_adaptive_sampling_target_tps", Value: c.adaptiveSamplingTPS},
+		{Name: "trace_peer_service_defaults_enabled", Value: c.peerServiceDefaultsEnabled},
+		{Name: "trace_header_tags", Value: strings.Join(c.headerAsTags, ",")},
+		{Name: "trace_baggage_tag_keys", Value: strings.Join(c.baggageTagKeys, ",")},
+		{Name: "trace_stats_peer_tags", Value: strings.Join(c.statsPeerTags, ",")},
+		{Name: "trace_error_chain_depth", Value: c.errorCapture.chainDepth()},
+		{Name: "trace_deferred_sampling_enabled", Value: c.deferredSampling},
 	}
 	for k, v := range c.featureFlags {
 		telemetryConfigs = append(telemetryConfigs, telemetry.Configuration{Name: k, Value: v})
@@ -58,6 +72,9 @@ func startTelemetry(c *config) {
 	for k, v := range c.serviceMappings {
 		telemetryConfigs = append(telemetryConfigs, telemetry.Configuration{Name: "service_mapping_" + k, Value: v})
 	}
+	for k, v := range c.peerServiceMappings {
+		telemetryConfigs = append(telemetryConfigs, telemetry.Configuration{Name: "peer_service_mapping_" + k, Value: v})
+	}
 	for k, v := range c.globalTags {
 		telemetryConfigs = append(telemetryConfigs, telemetry.Configuration{Name: "global_tag_" + k, Value: v})
 	}
@@ -8,13 +8,16 @@ package tracer
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
@@ -72,6 +75,16 @@ const (
 
 	headerPropagationStyleInjectDeprecated  = "DD_PROPAGATION_STYLE_INJECT"  // deprecated
 	headerPropagationStyleExtractDeprecated = "DD_PROPAGATION_STYLE_EXTRACT" // deprecated
+
+	headerPropagationExtractFirst    = "DD_TRACE_PROPAGATION_EXTRACT_FIRST"
+	headerPropagationBehaviorExtract = "DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT"
+)
+
+// The behaviors of the extraction of span contexts, see PropagatorConfig.ExtractBehavior.
+const (
+	extractBehaviorContinue = "continue"
+	extractBehaviorRestart  = "restart"
+	extractBehaviorIgnore   = "ignore"
 )
 
 const (
@@ -127,6 +140,35 @@ type PropagatorConfig struct {
 	// B3 specifies if B3 headers should be added for trace propagation.
 	// See https://github.com/openzipkin/b3-propagation
 	B3 bool
+
+	// ExtractFirst specifies whether extraction stops at the first extractor finding a
+	// span context. Otherwise, all extractors are checked: the contexts of the same trace
+	// are reconciled, while the contexts of other traces are recorded as span links.
+	// It defaults to the value of DD_TRACE_PROPAGATION_EXTRACT_FIRST, or false.
+	ExtractFirst bool
+
+	// ExtractBehavior specifies what is done with extracted span contexts: "continue"
+	// continues the incoming trace, "restart" starts a new trace linked to the incoming
+	// one and keeps the baggage, and "ignore" ignores the incoming context altogether.
+	// It defaults to the value of DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT, or "continue".
+	ExtractBehavior string
+
+	// Propagators overrides the propagators of the given propagation styles, whether
+	// built-in or registered with RegisterPropagator, in the Propagator being created.
+	// Style names are case insensitive. This allows, for instance, using a "datadog"
+	// style with custom headers for some services only.
+	Propagators map[string]Propagator
+}
+
+// propagator returns the propagator of the given propagation style overridden in
+// cfg.Propagators, or def if there is none.
+func (cfg *PropagatorConfig) propagator(style string, def Propagator) Propagator {
+	for name, p := range cfg.Propagators {
+		if p != nil && strings.EqualFold(name, style) {
+			return p
+		}
+	}
+	return def
 }
 
 // NewPropagator returns a new propagator which uses TextMap to inject
@@ -155,10 +197,26 @@ func NewPropagator(cfg *PropagatorConfig, propagators ...Propagator) Propagator
 	if cfg.PriorityHeader == "" {
 		cfg.PriorityHeader = DefaultPriorityHeader
 	}
+	if !cfg.ExtractFirst {
+		cfg.ExtractFirst = internal.BoolEnv(headerPropagationExtractFirst, false)
+	}
+	if cfg.ExtractBehavior == "" {
+		cfg.ExtractBehavior = strings.ToLower(os.Getenv(headerPropagationBehaviorExtract))
+	}
+	switch cfg.ExtractBehavior {
+	case extractBehaviorContinue, extractBehaviorRestart, extractBehaviorIgnore:
+	case "":
+		cfg.ExtractBehavior = extractBehaviorContinue
+	default:
+		log.Warn("unrecognized propagation behavior on extraction: %s, using %s\n", cfg.ExtractBehavior, extractBehaviorContinue)
+		cfg.ExtractBehavior = extractBehaviorContinue
+	}
 	if len(propagators) > 0 {
 		return &chainedPropagator{
-			injectors:  propagators,
-			extractors: propagators,
+			injectors:        propagators,
+			extractors:       propagators,
+			onlyExtractFirst: cfg.ExtractFirst,
+			extractBehavior:  cfg.ExtractBehavior,
 		}
 	}
 	injectorsPs := os.Getenv(headerPropagationStyleInject)
@@ -174,17 +232,23 @@ func NewPropagator(cfg *PropagatorConfig, propagators ...Propagator) Propagator
 		}
 	}
 	return &chainedPropagator{
-		injectors:  getPropagators(cfg, injectorsPs),
-		extractors: getPropagators(cfg, extractorsPs),
+		injectors:        getPropagators(cfg, injectorsPs),
+		extractors:       getPropagators(cfg, extractorsPs),
+		onlyExtractFirst: cfg.ExtractFirst,
+		extractBehavior:  cfg.ExtractBehavior,
 	}
 }
 
 // chainedPropagator implements Propagator and applies a list of injectors and extractors.
 // When injecting, all injectors are called to propagate the span context.
-// When extracting, it tries each extractor, selecting the first successful one.
+// When extracting, it tries each extractor, selecting the first successful one, and
+// reconciles it with the contexts found by the other extractors, unless onlyExtractFirst
+// is set.
 type chainedPropagator struct {
-	injectors  []Propagator
-	extractors []Propagator
+	injectors        []Propagator
+	extractors       []Propagator
+	onlyExtractFirst bool   // stop extraction at the first successful extractor
+	extractBehavior  string // one of extractBehaviorContinue, Restart or Ignore
 }
 
 // getPropagators returns a list of propagators based on ps, which is a comma seperated
@@ -192,10 +256,12 @@ type chainedPropagator struct {
 // default propagator will be returned. Any invalid values in the list will log
 // a warning and be ignored.
 func getPropagators(cfg *PropagatorConfig, ps string) []Propagator {
-	dd := &propagator{cfg}
-	defaultPs := []Propagator{&propagatorW3c{}, dd}
+	dd := cfg.propagator("datadog", &propagator{cfg})
+	w3c := cfg.propagator("tracecontext", &propagatorW3c{})
+	b3 := cfg.propagator("b3multi", &propagatorB3{})
+	defaultPs := []Propagator{w3c, dd}
 	if cfg.B3 {
-		defaultPs = append(defaultPs, &propagatorB3{})
+		defaultPs = append(defaultPs, b3)
 	}
 	if ps == "" {
 		if prop := os.Getenv(headerPropagationStyle); prop != "" {
@@ -210,25 +276,35 @@ func getPropagators(cfg *PropagatorConfig, ps string) []Propagator {
 	}
 	var list []Propagator
 	if cfg.B3 {
-		list = append(list, &propagatorB3{})
+		list = append(list, b3)
 	}
 	for _, v := range strings.Split(ps, ",") {
-		switch strings.ToLower(v) {
+		switch v {
 		case "datadog":
 			list = append(list, dd)
 		case "tracecontext":
-			list = append([]Propagator{&propagatorW3c{}}, list...)
+			list = append([]Propagator{w3c}, list...)
 		case "b3", "b3multi":
 			if !cfg.B3 {
 				// propagatorB3 hasn't already been added, add a new one.
-				list = append(list, &propagatorB3{})
+				list = append(list, b3)
 			}
 		case "b3 single header":
-			list = append(list, &propagatorB3SingleHeader{})
+			list = append(list, cfg.propagator(v, &propagatorB3SingleHeader{}))
+		case "xray":
+			list = append(list, cfg.propagator(v, &propagatorXRay{}))
+		case "jaeger":
+			list = append(list, cfg.propagator(v, &propagatorJaeger{}))
+		case "baggage":
+			list = append(list, cfg.propagator(v, &propagatorBaggage{}))
 		case "none":
 			log.Warn("Propagator \"none\" has no effect when combined with other propagators. " +
 				"To disable the propagator, set to `none`")
 		default:
+			if p := cfg.propagator(v, registeredPropagator(v)); p != nil {
+				list = append(list, p)
+				continue
+			}
 			log.Warn("unrecognized propagator: %s\n", v)
 		}
 	}
@@ -251,21 +327,215 @@ func (p *chainedPropagator) Inject(spanCtx ddtrace.SpanContext, carrier interfac
 	return nil
 }
 
-// Extract implements Propagator.
+// Extract implements Propagator. The span context is extracted by the first
+// successful extractor, while the baggage extracted by the baggage propagators is
+// merged into it. When only baggage is found, a span context holding only that
+// baggage is returned.
 func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	if p.extractBehavior == extractBehaviorIgnore {
+		return nil, ErrSpanContextNotFound
+	}
+	ctx, extractor, err := p.extractSpanContext(carrier)
+	if err != nil && err != ErrSpanContextNotFound {
+		return nil, err
+	}
+	if ctx != nil && p.extractBehavior == extractBehaviorRestart {
+		ctx = restartSpanContext(ctx, extractor)
+	}
 	for _, v := range p.extractors {
-		ctx, err := v.Extract(carrier)
-		if ctx != nil {
-			// first extractor returns
-			log.Debug("Extracted span context: %#v", ctx)
-			return ctx, nil
+		if _, ok := v.(*propagatorBaggage); !ok {
+			continue
 		}
+		bctx, err := v.Extract(carrier)
 		if err == ErrSpanContextNotFound {
 			continue
 		}
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		if ctx == nil {
+			ctx = bctx
+			continue
+		}
+		if c, ok := ctx.(*spanContext); ok {
+			bctx.ForeachBaggageItem(func(k, v string) bool {
+				c.setBaggageItem(k, v)
+				return true
+			})
+		}
+	}
+	if ctx == nil {
+		return nil, ErrSpanContextNotFound
+	}
+	log.Debug("Extracted span context: %#v", ctx)
+	return ctx, nil
+}
+
+// extractSpanContext returns the span context extracted by the first successful
+// extractor of p, ignoring the baggage propagators, along with that extractor. Unless
+// p.onlyExtractFirst is set, the span contexts found by the following extractors are
+// reconciled with it, and the errors of these extractors are ignored.
+func (p *chainedPropagator) extractSpanContext(carrier interface{}) (ddtrace.SpanContext, Propagator, error) {
+	var (
+		ctx       ddtrace.SpanContext
+		extractor Propagator
+	)
+	for _, v := range p.extractors {
+		if _, ok := v.(*propagatorBaggage); ok {
+			continue
+		}
+		extracted, err := v.Extract(carrier)
+		if ctx == nil {
+			if extracted != nil {
+				ctx, extractor = extracted, v
+				if p.onlyExtractFirst {
+					break
+				}
+				continue
+			}
+			if err == ErrSpanContextNotFound {
+				continue
+			}
+			return nil, nil, err
+		}
+		if extracted == nil {
+			if err != ErrSpanContextNotFound {
+				log.Debug("Ignoring span context extracted by %s: %v", propagatorName(v), err)
+			}
+			continue
+		}
+		reconcileSpanContext(ctx, extractor, extracted, v)
+	}
+	if ctx == nil {
+		return nil, nil, ErrSpanContextNotFound
+	}
+	return ctx, extractor, nil
+}
+
+// reconcileSpanContext reconciles the span context ctx, extracted by the first successful
+// extractor, with the span context other, extracted by a following extractor v. When both
+// belong to the same trace but were extracted from Datadog headers and W3C trace context
+// with different parents, the trace went through a non-Datadog tracer after the last
+// Datadog span: the W3C parent is used, and the ID of the last Datadog span is recorded,
+// taken from the tracestate p: value if present, or from the Datadog parent otherwise.
+// When they belong to different traces, other is recorded as a span link.
+func reconcileSpanContext(ctx ddtrace.SpanContext, extractor Propagator, other ddtrace.SpanContext, v Propagator) {
+	c, ok := ctx.(*spanContext)
+	if !ok {
+		return
+	}
+	o, ok := other.(*spanContext)
+	if !ok {
+		return
+	}
+	if !sameTrace(c.traceID, o.traceID) {
+		c.spanLinks = append(c.spanLinks, spanLinkFromContext(o, map[string]string{
+			"reason":          "terminated_context",
+			"context_headers": propagatorName(v),
+		}))
+		return
+	}
+	if _, ok := v.(*propagator); ok {
+		if _, ok := extractor.(*propagatorW3c); ok && c.spanID != o.spanID && c.reparentID == "" {
+			c.reparentID = fmt.Sprintf("%016x", o.spanID)
+		}
+		return
+	}
+	if _, ok := v.(*propagatorW3c); !ok {
+		return
+	}
+	if !c.traceID.HasUpper() {
+		c.traceID.SetUpper(o.traceID.Upper())
+	}
+	if c.spanID != o.spanID {
+		if o.reparentID != "" {
+			c.reparentID = o.reparentID
+		} else if _, ok := extractor.(*propagator); ok {
+			c.reparentID = fmt.Sprintf("%016x", c.spanID)
+		}
+		c.spanID = o.spanID
+	}
+	// keep the tracestate of the other vendors of the trace
+	if o.trace != nil && o.trace.hasPropagatingTag(tracestateHeader) {
+		o.trace.mu.RLock()
+		ts := o.trace.propagatingTags[tracestateHeader]
+		o.trace.mu.RUnlock()
+		setPropagatingTag(c, tracestateHeader, ts)
+	}
+}
+
+// sameTrace reports whether a and b are the IDs of the same trace. Only the lower
+// 64 bits are compared when one of them is a 64-bit ID, as with Datadog headers
+// lacking the _dd.p.tid tag.
+func sameTrace(a, b traceID) bool {
+	if a.HasUpper() && b.HasUpper() {
+		return a == b
+	}
+	return a.Lower() == b.Lower()
+}
+
+// restartSpanContext returns a span context starting a new trace, linked to the trace
+// of ctx extracted by extractor, and holding the baggage of ctx.
+func restartSpanContext(ctx ddtrace.SpanContext, extractor Propagator) ddtrace.SpanContext {
+	c, ok := ctx.(*spanContext)
+	if !ok || c.traceID.Empty() {
+		return ctx
+	}
+	var restarted spanContext
+	c.ForeachBaggageItem(func(k, v string) bool {
+		restarted.setBaggageItem(k, v)
+		return true
+	})
+	restarted.spanLinks = append(c.spanLinks, spanLinkFromContext(c, map[string]string{
+		"reason":          "propagation_behavior_extract",
+		"context_headers": propagatorName(extractor),
+	}))
+	return &restarted
+}
+
+// spanLinkFromContext returns a span link to the remote span context ctx.
+func spanLinkFromContext(ctx *spanContext, attributes map[string]string) ddtrace.SpanLink {
+	link := ddtrace.SpanLink{
+		TraceID:     ctx.traceID.Lower(),
+		TraceIDHigh: ctx.traceID.Upper(),
+		SpanID:      ctx.spanID,
+		Attributes:  attributes,
+	}
+	if p, ok := ctx.samplingPriority(); ok {
+		link.Flags = 1 << 31
+		if p > 0 {
+			link.Flags |= 1
+		}
+	}
+	if ctx.trace != nil && ctx.trace.hasPropagatingTag(tracestateHeader) {
+		ctx.trace.mu.RLock()
+		link.Tracestate = ctx.trace.propagatingTags[tracestateHeader]
+		ctx.trace.mu.RUnlock()
+	}
+	return link
+}
+
+// propagatorName returns the name of the propagation style implemented by p, as
+// used in DD_TRACE_PROPAGATION_STYLE.
+func propagatorName(p Propagator) string {
+	switch p.(type) {
+	case *propagator:
+		return "datadog"
+	case *propagatorW3c:
+		return "tracecontext"
+	case *propagatorB3:
+		return "b3multi"
+	case *propagatorB3SingleHeader:
+		return "b3 single header"
+	case *propagatorXRay:
+		return "xray"
+	case *propagatorJaeger:
+		return "jaeger"
+	case *propagatorBaggage:
+		return "baggage"
+	default:
+		return fmt.Sprintf("%T", p)
 	}
-	return nil, ErrSpanContextNotFound
 }
 
 // propagator implements Propagator and injects/extracts span contexts
@@ -914,6 +1184,8 @@ func parseTracestate(ctx *spanContext, header string) {
 			key, val := keyVal[0], keyVal[1]
 			if key == "o" {
 				ctx.origin = strings.ReplaceAll(val, "~", "=")
+			} else if key == "p" {
+				ctx.reparentID = val
 			} else if key == "s" {
 				stateP, err := strconv.Atoi(val)
 				if err != nil {
@@ -978,3 +1250,429 @@ func extractTraceID128(ctx *spanContext, v string) error {
 	}
 	return nil
 }
+
+const (
+	baggageHeader = "baggage"
+
+	// baggageMaxItems and baggageMaxBytes limit the number of list-members and
+	// the size of the baggage header, as recommended by the W3C Baggage specification.
+	baggageMaxItems = 64
+	baggageMaxBytes = 8192
+
+	// baggageTagPrefix prefixes the span tags holding baggage items.
+	baggageTagPrefix = "baggage."
+)
+
+// propagatorBaggage implements Propagator and injects/extracts the baggage of span
+// contexts using the W3C baggage header. It does not propagate the trace context, and
+// is meant to be used along with other propagators. Only TextMap carriers are supported.
+// See https://www.w3.org/TR/baggage/
+type propagatorBaggage struct{}
+
+func (p *propagatorBaggage) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+// injectTextMap propagates the baggage items of spanCtx into the writer, as a
+// comma-separated list of percent-encoded key=value list-members. Items exceeding
+// baggageMaxItems or baggageMaxBytes are not propagated.
+func (*propagatorBaggage) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok {
+		return ErrInvalidSpanContext
+	}
+	baggage := make(map[string]string)
+	ctx.ForeachBaggageItem(func(k, v string) bool {
+		baggage[k] = v
+		return true
+	})
+	if len(baggage) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	// sort the keys so that the items dropped because of the limits are deterministic
+	sort.Strings(keys)
+	var sb strings.Builder
+	var n int
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		if n == baggageMaxItems {
+			log.Warn("Won't propagate baggage item %q: maximum number of baggage items (%d) reached.", k, baggageMaxItems)
+			break
+		}
+		item := encodeBaggage(k, isBaggageKeyChar) + "=" + encodeBaggage(baggage[k], isBaggageValueChar)
+		if sb.Len() > 0 {
+			item = "," + item
+		}
+		if sb.Len()+len(item) > baggageMaxBytes {
+			log.Warn("Won't propagate baggage item %q: maximum baggage header size (%d) reached.", k, baggageMaxBytes)
+			break
+		}
+		sb.WriteString(item)
+		n++
+	}
+	if sb.Len() > 0 {
+		writer.Set(baggageHeader, sb.String())
+	}
+	return nil
+}
+
+func (p *propagatorBaggage) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+// extractTextMap returns a span context holding the baggage items found in the
+// baggage header(s) of reader, without any trace context.
+func (*propagatorBaggage) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var ctx spanContext
+	var items, size int
+	err := reader.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == baggageHeader {
+			parseBaggage(&ctx, v, &items, &size)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if items == 0 {
+		return nil, ErrSpanContextNotFound
+	}
+	return &ctx, nil
+}
+
+// parseBaggage sets the baggage items of ctx from the baggage header value. items and
+// size hold the number of items and bytes parsed so far, across multiple headers.
+// Malformed list-members are skipped, and the properties of list-members are ignored.
+func parseBaggage(ctx *spanContext, header string, items, size *int) {
+	for _, member := range strings.Split(header, ",") {
+		*size += len(member) + 1
+		if *items == baggageMaxItems || *size > baggageMaxBytes+1 {
+			log.Warn("Did not extract all baggage items: maximum number of baggage items (%d) or size (%d) exceeded.", baggageMaxItems, baggageMaxBytes)
+			return
+		}
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		kv := strings.SplitN(member, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, err := url.PathUnescape(strings.Trim(kv[0], " \t"))
+		if err != nil || key == "" {
+			continue
+		}
+		val, err := url.PathUnescape(strings.Trim(kv[1], " \t"))
+		if err != nil {
+			continue
+		}
+		ctx.setBaggageItem(key, val)
+		*items++
+	}
+}
+
+// isBaggageKeyChar reports whether c may be used as is in a baggage key, which is
+// a token as defined by RFC 7230. The percent sign is always encoded.
+func isBaggageKeyChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&'*+-.^_`|~", c) >= 0
+}
+
+// isBaggageValueChar reports whether c may be used as is in a baggage value, which
+// excludes control characters, whitespace, double quotes, commas, semicolons and
+// backslashes. The percent sign is always encoded.
+func isBaggageValueChar(c byte) bool {
+	return c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%'
+}
+
+// encodeBaggage percent-encodes the bytes of s not allowed by the allowed func.
+func encodeBaggage(s string, allowed func(byte) bool) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if allowed(c) {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[c>>4])
+		sb.WriteByte(hex[c&0xf])
+	}
+	return sb.String()
+}
+
+// setBaggageTags sets the baggage items of the context of s with the given keys as
+// span tags prefixed with "baggage.". The key "*" stands for all the baggage items.
+func setBaggageTags(s *span, keys []string) {
+	s.context.ForeachBaggageItem(func(k, v string) bool {
+		for _, key := range keys {
+			if key == "*" || key == k {
+				s.setMeta(baggageTagPrefix+k, v)
+				break
+			}
+		}
+		return true
+	})
+}
+
+const (
+	xrayTraceIDHeader = "x-amzn-trace-id"
+	xrayRootVersion   = "1"
+)
+
+// propagatorXRay implements Propagator and injects/extracts span contexts
+// using the AWS X-Ray X-Amzn-Trace-Id header. Only TextMap carriers are supported.
+// See https://docs.aws.amazon.com/xray/latest/devguide/xray-concepts.html#xray-concepts-tracingheader
+type propagatorXRay struct{}
+
+func (p *propagatorXRay) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+// injectTextMap propagates span context attributes into the writer, in the format
+// of the xrayTraceIDHeader: `Root=1-<epoch>-<unique id>;Parent=<span id>;Sampled=<0|1>`.
+// The 128-bit trace ID is split into the 8 hex-encoded digits of the epoch, and the
+// 24 hex-encoded digits of the unique id, so 64-bit trace IDs have a zero epoch.
+func (*propagatorXRay) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID.Empty() || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	traceID := ctx.traceID.HexEncoded()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Root=%s-%s-%s;Parent=%016x", xrayRootVersion, traceID[:8], traceID[8:], ctx.spanID))
+	if p, ok := ctx.samplingPriority(); ok {
+		if p >= ext.PriorityAutoKeep {
+			sb.WriteString(";Sampled=1")
+		} else {
+			sb.WriteString(";Sampled=0")
+		}
+	}
+	if ctx.origin != "" {
+		sb.WriteString(";" + keyOrigin + "=" + originRgx.ReplaceAllString(ctx.origin, "_"))
+	}
+	writer.Set(xrayTraceIDHeader, sb.String())
+	return nil
+}
+
+func (p *propagatorXRay) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+func (*propagatorXRay) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var ctx spanContext
+	err := reader.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == xrayTraceIDHeader {
+			return parseXRayHeader(&ctx, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ctx.traceID.Empty() {
+		return nil, ErrSpanContextNotFound
+	}
+	return &ctx, nil
+}
+
+// parseXRayHeader parses the xrayTraceIDHeader into ctx. The header is a list of
+// semicolon-separated key=value fields, where the Root field holds the trace ID,
+// the Parent field the span ID and the Sampled field the sampling decision, which
+// may be deferred ("?"). Unknown fields are ignored. The Parent field is optional,
+// as load balancers such as AWS ALB only set the Root field when starting a trace.
+func parseXRayHeader(ctx *spanContext, header string) error {
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.Trim(field, " \t"), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, val := kv[0], strings.ToLower(kv[1])
+		switch key {
+		case "Root":
+			parts := strings.Split(val, "-")
+			if len(parts) != 3 || parts[0] != xrayRootVersion || len(parts[1]) != 8 || len(parts[2]) != 24 {
+				return ErrSpanContextCorrupted
+			}
+			id := parts[1] + parts[2]
+			if !validIDRgx.MatchString(id) {
+				return ErrSpanContextCorrupted
+			}
+			if err := extractTraceID128(ctx, id); err != nil {
+				return err
+			}
+		case "Parent":
+			if len(val) != 16 || !validIDRgx.MatchString(val) {
+				return ErrSpanContextCorrupted
+			}
+			var err error
+			if ctx.spanID, err = strconv.ParseUint(val, 16, 64); err != nil {
+				return ErrSpanContextCorrupted
+			}
+		case "Sampled":
+			switch val {
+			case "1":
+				ctx.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Unknown)
+			case "0":
+				ctx.setSamplingPriority(ext.PriorityAutoReject, samplernames.Unknown)
+			}
+		case keyOrigin:
+			ctx.origin = kv[1]
+		}
+	}
+	return nil
+}
+
+const (
+	jaegerTraceIDHeader       = "uber-trace-id"
+	jaegerBaggageHeaderPrefix = "uberctx-"
+
+	jaegerFlagSampled = 0x1
+	jaegerFlagDebug   = 0x2
+)
+
+// propagatorJaeger implements Propagator and injects/extracts span contexts
+// using the Jaeger uber-trace-id header, and the baggage using uberctx- prefixed
+// headers. Only TextMap carriers are supported.
+// See https://www.jaegertracing.io/docs/1.48/client-libraries/#propagation-format
+type propagatorJaeger struct{}
+
+func (p *propagatorJaeger) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+// injectTextMap propagates span context attributes into the writer, in the format
+// of the jaegerTraceIDHeader: `<trace id>:<span id>:<parent span id>:<flags>`, where
+// the deprecated parent span ID is always 0. Baggage values are URL-encoded, as
+// expected by the Jaeger clients.
+func (*propagatorJaeger) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID.Empty() || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	var traceID string
+	if ctx.traceID.HasUpper() {
+		traceID = ctx.traceID.HexEncoded()
+	} else {
+		traceID = fmt.Sprintf("%016x", ctx.traceID.Lower())
+	}
+	var flags int
+	if p, ok := ctx.samplingPriority(); ok && p >= ext.PriorityAutoKeep {
+		flags = jaegerFlagSampled
+	}
+	writer.Set(jaegerTraceIDHeader, fmt.Sprintf("%s:%016x:0:%x", traceID, ctx.spanID, flags))
+	ctx.ForeachBaggageItem(func(k, v string) bool {
+		writer.Set(jaegerBaggageHeaderPrefix+k, url.QueryEscape(v))
+		return true
+	})
+	return nil
+}
+
+func (p *propagatorJaeger) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+func (*propagatorJaeger) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var ctx spanContext
+	err := reader.ForeachKey(func(k, v string) error {
+		key := strings.ToLower(k)
+		switch {
+		case key == jaegerTraceIDHeader:
+			return parseJaegerHeader(&ctx, v)
+		case strings.HasPrefix(key, jaegerBaggageHeaderPrefix):
+			if uv, err := url.QueryUnescape(v); err == nil {
+				v = uv
+			}
+			ctx.setBaggageItem(strings.TrimPrefix(key, jaegerBaggageHeaderPrefix), v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ctx.traceID.Empty() || ctx.spanID == 0 {
+		return nil, ErrSpanContextNotFound
+	}
+	return &ctx, nil
+}
+
+// parseJaegerHeader parses the jaegerTraceIDHeader into ctx. The trace ID holds up
+// to 32 hex-encoded digits, and may omit leading zeros, as does the span ID with
+// up to 16 digits. The header may be URL-encoded. The sampled flag sets the auto
+// keep or reject sampling priority, while the debug flag sets the user keep priority.
+func parseJaegerHeader(ctx *spanContext, header string) error {
+	if v, err := url.QueryUnescape(header); err == nil {
+		header = v
+	}
+	parts := strings.Split(strings.ToLower(strings.Trim(header, " \t")), ":")
+	if len(parts) != 4 {
+		return ErrSpanContextCorrupted
+	}
+	traceID, spanID, flags := parts[0], parts[1], parts[3]
+	if len(traceID) == 0 || len(traceID) > 32 || !validIDRgx.MatchString(traceID) {
+		return ErrSpanContextCorrupted
+	}
+	if err := extractTraceID128(ctx, traceID); err != nil {
+		return err
+	}
+	if len(spanID) == 0 || len(spanID) > 16 || !validIDRgx.MatchString(spanID) {
+		return ErrSpanContextCorrupted
+	}
+	var err error
+	if ctx.spanID, err = strconv.ParseUint(spanID, 16, 64); err != nil {
+		return ErrSpanContextCorrupted
+	}
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return ErrSpanContextCorrupted
+	}
+	switch {
+	case f&jaegerFlagDebug != 0:
+		ctx.setSamplingPriority(ext.PriorityUserKeep, samplernames.Unknown)
+	case f&jaegerFlagSampled != 0:
+		ctx.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Unknown)
+	default:
+		ctx.setSamplingPriority(ext.PriorityAutoReject, samplernames.Unknown)
+	}
+	return nil
+}
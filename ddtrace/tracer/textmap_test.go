@@ -19,6 +19,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/httpmem"
@@ -1754,6 +1755,788 @@ func TestNonePropagator(t *testing.T) {
 	})
 }
 
+func TestChainedPropagatorExtract(t *testing.T) {
+	const (
+		w3cParent = "00-000000000000000000000000075bcd15-000000003ade68b1-01"
+		ddTraceID = "123456789"
+	)
+
+	t.Run("reparent/tracestate", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "datadog,tracecontext")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  ddTraceID,
+			DefaultParentIDHeader: "1",
+			DefaultPriorityHeader: "1",
+			traceparentHeader:     w3cParent,
+			tracestateHeader:      "dd=s:1;p:0000000000000002,foo=1",
+		})
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, uint64(987654321), sctx.spanID)
+		assert.Equal(t, "0000000000000002", sctx.reparentID)
+		assert.Empty(t, sctx.spanLinks)
+
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal(t, uint64(987654321), root.ParentID)
+		assert.Equal(t, "0000000000000002", root.Meta[keyReparentID])
+	})
+
+	t.Run("reparent/datadog", func(t *testing.T) {
+		for name, p := range map[string]Propagator{
+			"datadog,tracecontext": NewPropagator(nil, &propagator{&PropagatorConfig{TraceHeader: DefaultTraceIDHeader, ParentHeader: DefaultParentIDHeader, PriorityHeader: DefaultPriorityHeader, BaggagePrefix: DefaultBaggageHeaderPrefix}}, &propagatorW3c{}),
+			"tracecontext,datadog": NewPropagator(nil, &propagatorW3c{}, &propagator{&PropagatorConfig{TraceHeader: DefaultTraceIDHeader, ParentHeader: DefaultParentIDHeader, PriorityHeader: DefaultPriorityHeader, BaggagePrefix: DefaultBaggageHeaderPrefix}}),
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx, err := p.Extract(TextMapCarrier{
+					DefaultTraceIDHeader:  ddTraceID,
+					DefaultParentIDHeader: "1",
+					traceparentHeader:     w3cParent,
+					tracestateHeader:      "foo=1",
+				})
+				require.NoError(t, err)
+				sctx := ctx.(*spanContext)
+				assert.Equal(t, uint64(987654321), sctx.spanID)
+				assert.Equal(t, "0000000000000001", sctx.reparentID)
+				assert.Equal(t, "foo=1", sctx.trace.propagatingTags[tracestateHeader])
+				assert.Empty(t, sctx.spanLinks)
+			})
+		}
+	})
+
+	t.Run("same-parent", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  ddTraceID,
+			DefaultParentIDHeader: "987654321",
+			traceparentHeader:     w3cParent,
+		})
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, uint64(987654321), sctx.spanID)
+		assert.Empty(t, sctx.reparentID)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.NotContains(t, root.Meta, keyReparentID)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "tracecontext,datadog,b3multi")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			traceparentHeader:     w3cParent,
+			tracestateHeader:      "dd=s:1",
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+			DefaultPriorityHeader: "0",
+			b3TraceIDHeader:       "75bcd15",
+			b3SpanIDHeader:        "3ade68b1",
+		})
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, uint64(123456789), sctx.traceID.Lower())
+		assert.Equal(t, uint64(987654321), sctx.spanID)
+		want := ddtrace.SpanLink{
+			TraceID:    1,
+			SpanID:     2,
+			Flags:      1 << 31,
+			Attributes: map[string]string{"reason": "terminated_context", "context_headers": "datadog"},
+		}
+		assert.Equal(t, []ddtrace.SpanLink{want}, sctx.spanLinks)
+
+		root := tracer.StartSpan("web.request", ChildOf(ctx), WithSpanLinks([]ddtrace.SpanLink{{TraceID: 4, SpanID: 5}})).(*span)
+		assert.Equal(t, []ddtrace.SpanLink{{TraceID: 4, SpanID: 5}, want}, root.SpanLinks)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		assert.Empty(t, child.SpanLinks)
+	})
+
+	t.Run("extract-first", func(t *testing.T) {
+		t.Setenv(headerPropagationExtractFirst, "true")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			traceparentHeader:     w3cParent,
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+		})
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, uint64(987654321), sctx.spanID)
+		assert.Empty(t, sctx.spanLinks)
+	})
+
+	t.Run("ignore-errors", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			traceparentHeader:     w3cParent,
+			DefaultTraceIDHeader:  "invalid",
+			DefaultParentIDHeader: "2",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(987654321), ctx.SpanID())
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "tracecontext,baggage")
+		t.Setenv(headerPropagationBehaviorExtract, "Restart")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{
+			traceparentHeader: w3cParent,
+			tracestateHeader:  "dd=s:1",
+			baggageHeader:     "tenant=acme",
+		})
+		require.NoError(t, err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.NotEqual(t, uint64(123456789), root.TraceID)
+		assert.Zero(t, root.ParentID)
+		assert.Equal(t, "acme", root.BaggageItem("tenant"))
+		assert.Equal(t, []ddtrace.SpanLink{{
+			TraceID:    123456789,
+			SpanID:     987654321,
+			Tracestate: "dd=s:1",
+			Flags:      1 | 1<<31,
+			Attributes: map[string]string{"reason": "propagation_behavior_extract", "context_headers": "tracecontext"},
+		}}, root.SpanLinks)
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		t.Setenv(headerPropagationBehaviorExtract, "ignore")
+		tracer := newTracer()
+		defer tracer.Stop()
+		_, err := tracer.Extract(TextMapCarrier{traceparentHeader: w3cParent})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		p := NewPropagator(nil).(*chainedPropagator)
+		assert.False(t, p.onlyExtractFirst)
+		assert.Equal(t, extractBehaviorContinue, p.extractBehavior)
+
+		p = NewPropagator(&PropagatorConfig{ExtractFirst: true, ExtractBehavior: extractBehaviorRestart}).(*chainedPropagator)
+		assert.True(t, p.onlyExtractFirst)
+		assert.Equal(t, extractBehaviorRestart, p.extractBehavior)
+
+		t.Setenv(headerPropagationBehaviorExtract, "invalid")
+		p = NewPropagator(nil).(*chainedPropagator)
+		assert.Equal(t, extractBehaviorContinue, p.extractBehavior)
+	})
+}
+
+// testPropagator is a third-party like propagator, injecting the trace context
+// in a single header.
+type testPropagator struct {
+	header string
+}
+
+// testSpanContext is the span context extracted by testPropagator.
+type testSpanContext struct {
+	traceID traceID
+	spanID  uint64
+}
+
+func (c *testSpanContext) SpanID() uint64                                    { return c.spanID }
+func (c *testSpanContext) TraceID() uint64                                   { return c.traceID.Lower() }
+func (c *testSpanContext) TraceID128() string                                { return c.traceID.HexEncoded() }
+func (c *testSpanContext) TraceID128Bytes() [16]byte                         { return c.traceID }
+func (c *testSpanContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+
+func (p *testPropagator) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	w, ok := carrier.(TextMapWriter)
+	if !ok {
+		return ErrInvalidCarrier
+	}
+	ctx, ok := spanCtx.(ddtrace.SpanContextW3C)
+	if !ok {
+		return ErrInvalidSpanContext
+	}
+	w.Set(p.header, fmt.Sprintf("%s-%016x", ctx.TraceID128(), ctx.SpanID()))
+	return nil
+}
+
+func (p *testPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	r, ok := carrier.(TextMapReader)
+	if !ok {
+		return nil, ErrInvalidCarrier
+	}
+	var ctx *testSpanContext
+	err := r.ForeachKey(func(k, v string) error {
+		if !strings.EqualFold(k, p.header) {
+			return nil
+		}
+		parts := strings.Split(v, "-")
+		if len(parts) != 2 {
+			return ErrSpanContextCorrupted
+		}
+		var sctx spanContext
+		if err := extractTraceID128(&sctx, parts[0]); err != nil {
+			return err
+		}
+		spanID, err := strconv.ParseUint(parts[1], 16, 64)
+		if err != nil {
+			return ErrSpanContextCorrupted
+		}
+		ctx = &testSpanContext{traceID: sctx.traceID, spanID: spanID}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		return nil, ErrSpanContextNotFound
+	}
+	return ctx, nil
+}
+
+func TestRegisterPropagator(t *testing.T) {
+	assert.NoError(t, RegisterPropagator(" Custom ", &testPropagator{header: "x-custom"}))
+	defer RegisterPropagator("custom", nil)
+	assert.Error(t, RegisterPropagator("datadog", &testPropagator{}))
+	assert.Error(t, RegisterPropagator("B3 single header", &testPropagator{}))
+	assert.Error(t, RegisterPropagator("", &testPropagator{}))
+	assert.Error(t, RegisterPropagator("a,b", &testPropagator{}))
+
+	t.Run("style", func(t *testing.T) {
+		t.Setenv(headerPropagationStyleInject, "datadog,CUSTOM")
+		t.Setenv(headerPropagationStyleExtract, "custom,datadog")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		headers := TextMapCarrier{}
+		err := tracer.Inject(root.Context(), headers)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s-%016x", root.context.TraceID128(), root.SpanID), headers["x-custom"])
+		assert.Equal(t, strconv.FormatUint(root.TraceID, 10), headers[DefaultTraceIDHeader])
+
+		delete(headers, DefaultTraceIDHeader)
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		assert.IsType(t, &testSpanContext{}, ctx)
+		child := tracer.StartSpan("child", ChildOf(ctx)).(*span)
+		assert.Equal(t, root.TraceID, child.TraceID)
+		assert.Equal(t, root.SpanID, child.ParentID)
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		assert.NoError(t, RegisterPropagator("other", &testPropagator{header: "x-other"}))
+		assert.NoError(t, RegisterPropagator("other", nil))
+		t.Setenv(headerPropagationStyle, "other")
+		p := NewPropagator(nil).(*chainedPropagator)
+		assert.Equal(t, []Propagator{&propagatorW3c{}, &propagator{&PropagatorConfig{
+			BaggagePrefix:   DefaultBaggageHeaderPrefix,
+			TraceHeader:     DefaultTraceIDHeader,
+			ParentHeader:    DefaultParentIDHeader,
+			PriorityHeader:  DefaultPriorityHeader,
+			ExtractBehavior: extractBehaviorContinue,
+		}}}, p.injectors)
+	})
+}
+
+func TestPropagatorConfigPropagators(t *testing.T) {
+	custom := &testPropagator{header: "x-custom"}
+	assert.NoError(t, RegisterPropagator("custom", &testPropagator{header: "x-registered"}))
+	defer RegisterPropagator("custom", nil)
+
+	t.Setenv(headerPropagationStyle, "Datadog,custom,xray")
+	p := NewPropagator(&PropagatorConfig{
+		Propagators: map[string]Propagator{
+			"DATADOG": custom,
+			"custom":  custom,
+			"b3multi": custom,
+		},
+	}).(*chainedPropagator)
+	assert.Equal(t, []Propagator{custom, custom, &propagatorXRay{}}, p.injectors)
+	assert.Equal(t, []Propagator{custom, custom, &propagatorXRay{}}, p.extractors)
+
+	t.Setenv(headerPropagationStyle, "")
+	p = NewPropagator(&PropagatorConfig{
+		Propagators: map[string]Propagator{"tracecontext": custom},
+	}).(*chainedPropagator)
+	require.Len(t, p.injectors, 2)
+	assert.Equal(t, custom, p.injectors[0])
+	assert.IsType(t, &propagator{}, p.injectors[1])
+}
+
+func TestCheckPropagator(t *testing.T) {
+	cfg := &PropagatorConfig{}
+	chained := NewPropagator(cfg)
+	for name, p := range map[string]Propagator{
+		"chained":          chained,
+		"datadog":          &propagator{cfg},
+		"tracecontext":     &propagatorW3c{},
+		"b3multi":          &propagatorB3{},
+		"b3 single header": &propagatorB3SingleHeader{},
+		"xray":             &propagatorXRay{},
+		"jaeger":           &propagatorJaeger{},
+		"custom":           &testPropagator{header: "x-custom"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, CheckPropagator(p))
+		})
+	}
+
+	t.Run("violations", func(t *testing.T) {
+		err := CheckPropagator(&propagatorBaggage{})
+		assert.EqualError(t, err, "calling Extract with a reader-only carrier returned trace ID 0 and span ID 0, expected 651345242494996240 and 1234605616436508552")
+		err = CheckPropagator(&caseSensitivePropagator{testPropagator{header: "x-custom"}})
+		assert.EqualError(t, err, "calling Extract with HTTP headers returned span context not found")
+	})
+}
+
+// caseSensitivePropagator breaks the propagator contract by matching keys case sensitively.
+type caseSensitivePropagator struct {
+	testPropagator
+}
+
+func (p *caseSensitivePropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	r, ok := carrier.(TextMapReader)
+	if !ok {
+		return nil, ErrInvalidCarrier
+	}
+	var found bool
+	var m = TextMapCarrier{}
+	r.ForeachKey(func(k, v string) error {
+		if k == p.header {
+			found = true
+			m[k] = v
+		}
+		return nil
+	})
+	if !found {
+		return nil, ErrSpanContextNotFound
+	}
+	return p.testPropagator.Extract(m)
+}
+
+func TestXRayPropagator(t *testing.T) {
+	t.Run("inject", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			traceID  traceID
+			priority int
+			origin   string
+			out      string
+		}{
+			"64-bit": {
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				priority: ext.PriorityAutoKeep,
+				out:      "Root=1-00000000-00000000000504ab30404b09;Parent=00068bdfb1eb0428;Sampled=1",
+			},
+			"128-bit": {
+				traceID:  traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+				priority: ext.PriorityAutoReject,
+				out:      "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=00068bdfb1eb0428;Sampled=0",
+			},
+			"origin": {
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				priority: ext.PriorityUserKeep,
+				origin:   "synthetics",
+				out:      "Root=1-00000000-00000000000504ab30404b09;Parent=00068bdfb1eb0428;Sampled=1;_dd.origin=synthetics",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx := &spanContext{traceID: tt.traceID, spanID: 0x00068bdfb1eb0428, origin: tt.origin}
+				ctx.setSamplingPriority(tt.priority, samplernames.Unknown)
+				headers := TextMapCarrier{}
+				err := (&propagatorXRay{}).Inject(ctx, headers)
+				require.NoError(t, err)
+				assert.Equal(t, tt.out, headers[xrayTraceIDHeader])
+			})
+		}
+
+		err := (&propagatorXRay{}).Inject(&spanContext{}, TextMapCarrier{})
+		assert.Equal(t, ErrInvalidSpanContext, err)
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			header   string
+			traceID  traceID
+			spanID   uint64
+			priority int
+			sampled  bool
+			origin   string
+		}{
+			"full": {
+				header:   "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+				traceID:  traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+				spanID:   0x53995c3f42cd8ad8,
+				priority: ext.PriorityAutoKeep,
+				sampled:  true,
+			},
+			"64-bit": {
+				header:   "Root=1-00000000-00000000000504ab30404b09;Parent=00068bdfb1eb0428;Sampled=0;_dd.origin=synthetics",
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				spanID:   0x00068bdfb1eb0428,
+				priority: ext.PriorityAutoReject,
+				sampled:  true,
+				origin:   "synthetics",
+			},
+			"deferred": {
+				header:  "Self=1-67891233-12456789abcdef012345678;Root=1-5759E988-BD862E3FE1BE46A994272793;Parent=53995c3f42cd8ad8;Sampled=?",
+				traceID: traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+				spanID:  0x53995c3f42cd8ad8,
+			},
+			"no-parent": {
+				header:  "Root=1-5759e988-bd862e3fe1be46a994272793",
+				traceID: traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx, err := (&propagatorXRay{}).Extract(TextMapCarrier{"X-Amzn-Trace-Id": tt.header})
+				require.NoError(t, err)
+				sctx := ctx.(*spanContext)
+				assert.Equal(t, tt.traceID, sctx.traceID)
+				assert.Equal(t, tt.spanID, sctx.spanID)
+				assert.Equal(t, tt.origin, sctx.origin)
+				p, ok := sctx.samplingPriority()
+				assert.Equal(t, tt.sampled, ok)
+				assert.Equal(t, tt.priority, p)
+			})
+		}
+	})
+
+	t.Run("extract/errors", func(t *testing.T) {
+		for header, want := range map[string]error{
+			"":                        ErrSpanContextNotFound,
+			"Parent=53995c3f42cd8ad8": ErrSpanContextNotFound,
+			"Root=2-5759e988-bd862e3fe1be46a994272793":                        ErrSpanContextCorrupted,
+			"Root=1-5759e98-bd862e3fe1be46a994272793":                         ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a99427279z":                        ErrSpanContextCorrupted,
+			"Root=1-00000000-000000000000000000000000":                        ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad": ErrSpanContextCorrupted,
+		} {
+			_, err := (&propagatorXRay{}).Extract(TextMapCarrier{xrayTraceIDHeader: header})
+			assert.Equal(t, want, err, header)
+		}
+	})
+
+	t.Run("style", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "xray")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		headers := TextMapCarrier{}
+		err := tracer.Inject(root.Context(), headers)
+		require.NoError(t, err)
+		assert.Len(t, headers, 1)
+
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, root.context.traceID, sctx.traceID)
+		assert.Equal(t, root.SpanID, sctx.spanID)
+	})
+}
+
+func TestJaegerPropagator(t *testing.T) {
+	t.Run("inject", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			traceID  traceID
+			priority int
+			out      string
+		}{
+			"64-bit": {
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				priority: ext.PriorityAutoKeep,
+				out:      "000504ab30404b09:00068bdfb1eb0428:0:1",
+			},
+			"128-bit": {
+				traceID:  traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+				priority: ext.PriorityUserReject,
+				out:      "5759e988bd862e3fe1be46a994272793:00068bdfb1eb0428:0:0",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx := &spanContext{traceID: tt.traceID, spanID: 0x00068bdfb1eb0428}
+				ctx.setSamplingPriority(tt.priority, samplernames.Unknown)
+				ctx.setBaggageItem("tenant", "acme corp")
+				headers := TextMapCarrier{}
+				err := (&propagatorJaeger{}).Inject(ctx, headers)
+				require.NoError(t, err)
+				assert.Equal(t, tt.out, headers[jaegerTraceIDHeader])
+				assert.Equal(t, "acme+corp", headers[jaegerBaggageHeaderPrefix+"tenant"])
+			})
+		}
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			header   string
+			traceID  traceID
+			spanID   uint64
+			priority int
+		}{
+			"64-bit": {
+				header:   "000504ab30404b09:00068bdfb1eb0428:0:1",
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				spanID:   0x00068bdfb1eb0428,
+				priority: ext.PriorityAutoKeep,
+			},
+			"128-bit": {
+				header:   "5759e988bd862e3fe1be46a994272793:68bdfb1eb0428:0:0",
+				traceID:  traceIDFrom128Bits(0x5759e988bd862e3f, 0xe1be46a994272793),
+				spanID:   0x00068bdfb1eb0428,
+				priority: ext.PriorityAutoReject,
+			},
+			"debug": {
+				header:   "504AB30404B09:68BDFB1EB0428:0:3",
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				spanID:   0x00068bdfb1eb0428,
+				priority: ext.PriorityUserKeep,
+			},
+			"url-encoded": {
+				header:   "504ab30404b09%3A68bdfb1eb0428%3A0%3A1",
+				traceID:  traceIDFrom64Bits(0x000504ab30404b09),
+				spanID:   0x00068bdfb1eb0428,
+				priority: ext.PriorityAutoKeep,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				headers := TextMapCarrier{
+					"Uber-Trace-Id":  tt.header,
+					"uberctx-tenant": "acme+corp",
+				}
+				ctx, err := (&propagatorJaeger{}).Extract(headers)
+				require.NoError(t, err)
+				sctx := ctx.(*spanContext)
+				assert.Equal(t, tt.traceID, sctx.traceID)
+				assert.Equal(t, tt.spanID, sctx.spanID)
+				p, ok := sctx.samplingPriority()
+				assert.True(t, ok)
+				assert.Equal(t, tt.priority, p)
+				assert.Equal(t, "acme corp", sctx.baggageItem("tenant"))
+			})
+		}
+	})
+
+	t.Run("extract/errors", func(t *testing.T) {
+		for header, want := range map[string]error{
+			"":                                  ErrSpanContextCorrupted,
+			"504ab30404b09:68bdfb1eb0428:0":     ErrSpanContextCorrupted,
+			"504ab30404b0z:68bdfb1eb0428:0:1":   ErrSpanContextCorrupted,
+			"0:68bdfb1eb0428:0:1":               ErrSpanContextCorrupted,
+			"504ab30404b09:0:0:1":               ErrSpanContextNotFound,
+			"504ab30404b09:68bdfb1eb0428:0:xyz": ErrSpanContextCorrupted,
+			"5759e988bd862e3fe1be46a9942727930:68bdfb1eb0428:0:1": ErrSpanContextCorrupted,
+		} {
+			_, err := (&propagatorJaeger{}).Extract(TextMapCarrier{jaegerTraceIDHeader: header})
+			assert.Equal(t, want, err, header)
+		}
+		_, err := (&propagatorJaeger{}).Extract(TextMapCarrier{})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+	})
+
+	t.Run("style", func(t *testing.T) {
+		t.Setenv(headerPropagationStyleInject, "jaeger")
+		t.Setenv(headerPropagationStyleExtract, "jaeger")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		root.SetBaggageItem("tenant", "acme")
+		headers := TextMapCarrier{}
+		err := tracer.Inject(root.Context(), headers)
+		require.NoError(t, err)
+		assert.Len(t, headers, 2)
+
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, root.context.traceID, sctx.traceID)
+		assert.Equal(t, root.SpanID, sctx.spanID)
+		assert.Equal(t, "acme", sctx.baggageItem("tenant"))
+	})
+}
+
+func TestBaggagePropagator(t *testing.T) {
+	baggageOf := func(ctx interface {
+		ForeachBaggageItem(func(k, v string) bool)
+	}) map[string]string {
+		m := make(map[string]string)
+		ctx.ForeachBaggageItem(func(k, v string) bool {
+			m[k] = v
+			return true
+		})
+		return m
+	}
+
+	t.Run("inject", func(t *testing.T) {
+		ctx := &spanContext{}
+		ctx.setBaggageItem("tenant.id", "acme corp")
+		ctx.setBaggageItem("user=id", "a,b;c%d\"é")
+		headers := TextMapCarrier{}
+		err := (&propagatorBaggage{}).Inject(ctx, headers)
+		assert.NoError(t, err)
+		assert.Equal(t, "tenant.id=acme%20corp,user%3Did=a%2Cb%3Bc%25d%22%C3%A9", headers[baggageHeader])
+	})
+
+	t.Run("inject/empty", func(t *testing.T) {
+		headers := TextMapCarrier{}
+		err := (&propagatorBaggage{}).Inject(&spanContext{}, headers)
+		assert.NoError(t, err)
+		assert.Empty(t, headers)
+	})
+
+	t.Run("inject/max-items", func(t *testing.T) {
+		ctx := &spanContext{}
+		for i := 0; i < baggageMaxItems+10; i++ {
+			ctx.setBaggageItem(fmt.Sprintf("key%03d", i), "v")
+		}
+		headers := TextMapCarrier{}
+		err := (&propagatorBaggage{}).Inject(ctx, headers)
+		assert.NoError(t, err)
+		items := strings.Split(headers[baggageHeader], ",")
+		assert.Len(t, items, baggageMaxItems)
+		assert.Equal(t, "key000=v", items[0])
+	})
+
+	t.Run("inject/max-bytes", func(t *testing.T) {
+		ctx := &spanContext{}
+		ctx.setBaggageItem("a", strings.Repeat("x", baggageMaxBytes/2))
+		ctx.setBaggageItem("b", strings.Repeat("x", baggageMaxBytes/2))
+		headers := TextMapCarrier{}
+		err := (&propagatorBaggage{}).Inject(ctx, headers)
+		assert.NoError(t, err)
+		assert.Equal(t, "a="+strings.Repeat("x", baggageMaxBytes/2), headers[baggageHeader])
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		headers := TextMapCarrier{
+			"Baggage": " tenant.id = acme%20corp ,user%3Did=a%2Cb%3Bc%25d%22%C3%A9;prop=1,malformed,=nokey,bad=%zz",
+		}
+		ctx, err := (&propagatorBaggage{}).Extract(headers)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"tenant.id": "acme corp", "user=id": "a,b;c%d\"é"}, baggageOf(ctx))
+		assert.True(t, ctx.(*spanContext).traceID.Empty())
+	})
+
+	t.Run("extract/not-found", func(t *testing.T) {
+		_, err := (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: "malformed"})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+		_, err = (&propagatorBaggage{}).Extract(TextMapCarrier{})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+	})
+
+	t.Run("extract/limits", func(t *testing.T) {
+		var items []string
+		for i := 0; i < baggageMaxItems+10; i++ {
+			items = append(items, fmt.Sprintf("key%d=v", i))
+		}
+		ctx, err := (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: strings.Join(items, ",")})
+		require.NoError(t, err)
+		assert.Len(t, baggageOf(ctx), baggageMaxItems)
+
+		header := "a=" + strings.Repeat("x", baggageMaxBytes-10) + ",b=" + strings.Repeat("x", 10)
+		ctx, err = (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: header})
+		require.NoError(t, err)
+		b := baggageOf(ctx)
+		assert.Len(t, b, 1)
+		assert.Contains(t, b, "a")
+	})
+
+	t.Run("style", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "datadog,tracecontext,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		root.SetBaggageItem("tenant.id", "acme")
+		headers := TextMapCarrier{}
+		err := tracer.Inject(root.Context(), headers)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant.id=acme", headers[baggageHeader])
+		assert.Equal(t, "acme", headers[DefaultBaggageHeaderPrefix+"tenant.id"])
+		assert.NotEmpty(t, headers[traceparentHeader])
+
+		// the baggage header takes precedence over the datadog baggage headers
+		headers[baggageHeader] = "tenant.id=other,region=eu"
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		sctx := ctx.(*spanContext)
+		assert.Equal(t, root.context.traceID, sctx.traceID)
+		assert.Equal(t, root.SpanID, sctx.spanID)
+		assert.Equal(t, map[string]string{"tenant.id": "other", "region": "eu"}, baggageOf(sctx))
+	})
+
+	t.Run("baggage-only", func(t *testing.T) {
+		t.Setenv(headerPropagationStyle, "datadog,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+
+		ctx, err := tracer.Extract(TextMapCarrier{baggageHeader: "tenant.id=acme"})
+		require.NoError(t, err)
+		s := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.NotZero(t, s.TraceID)
+		assert.Equal(t, s.SpanID, s.TraceID)
+		assert.Zero(t, s.ParentID)
+		assert.Equal(t, "acme", s.BaggageItem("tenant.id"))
+
+		_, err = tracer.Extract(TextMapCarrier{})
+		assert.Equal(t, ErrSpanContextNotFound, err)
+	})
+
+	t.Run("not-selected", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		root.SetBaggageItem("tenant.id", "acme")
+		headers := TextMapCarrier{}
+		err := tracer.Inject(root.Context(), headers)
+		require.NoError(t, err)
+		assert.NotContains(t, headers, baggageHeader)
+	})
+}
+
+func TestBaggageTags(t *testing.T) {
+	t.Setenv(headerPropagationStyle, "tracecontext,baggage")
+	headers := TextMapCarrier{
+		traceparentHeader: "00-12345678901234567890123456789012-1234567890123456-01",
+		baggageHeader:     "tenant.id=acme,region=eu,user.id=42",
+	}
+
+	t.Run("keys", func(t *testing.T) {
+		tracer := newTracer(WithBaggageTagKeys("tenant.id", "region"))
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		assert.Equal(t, "acme", root.Meta["baggage.tenant.id"])
+		assert.Equal(t, "eu", root.Meta["baggage.region"])
+		assert.NotContains(t, root.Meta, "baggage.user.id")
+		assert.NotContains(t, child.Meta, "baggage.tenant.id")
+	})
+
+	t.Run("all", func(t *testing.T) {
+		t.Setenv("DD_TRACE_BAGGAGE_TAG_KEYS", "*")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal(t, "acme", root.Meta["baggage.tenant.id"])
+		assert.Equal(t, "eu", root.Meta["baggage.region"])
+		assert.Equal(t, "42", root.Meta["baggage.user.id"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(headers)
+		require.NoError(t, err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.NotContains(t, root.Meta, "baggage.tenant.id")
+	})
+
+	t.Run("config", func(t *testing.T) {
+		t.Setenv("DD_TRACE_BAGGAGE_TAG_KEYS", "tenant.id, region,")
+		assert.Equal(t, []string{"tenant.id", "region"}, newConfig().baggageTagKeys)
+		assert.Equal(t, []string{"user.id"}, newConfig(WithBaggageTagKeys("user.id")).baggageTagKeys)
+	})
+}
+
 func assertTraceTags(t *testing.T, expected, actual string) {
 	assert.ElementsMatch(t, strings.Split(expected, ","), strings.Split(actual, ","))
 }
@@ -1964,3 +2747,113 @@ func FuzzExtractTraceID128(f *testing.F) {
 		extractTraceID128(ctx, v) // make sure it doesn't panic
 	})
 }
+
+func FuzzParseXRayHeader(f *testing.F) {
+	testCases := []struct {
+		epoch, id, parent, sampled string
+	}{
+		{"5759e988", "bd862e3fe1be46a994272793", "53995c3f42cd8ad8", "1"},
+		{"00000000", "00000000000504ab30404b09", "00068bdfb1eb0428", "0"},
+		{"5759e988", "bd862e3fe1be46a994272793", "53995c3f42cd8ad8", "?"},
+	}
+	for _, tc := range testCases {
+		f.Add(tc.epoch, tc.id, tc.parent, tc.sampled)
+	}
+	f.Fuzz(func(t *testing.T, epoch, id, parent, sampled string) {
+		ctx := new(spanContext)
+		header := fmt.Sprintf("Root=1-%s-%s;Parent=%s;Sampled=%s", epoch, id, parent, sampled)
+		if parseXRayHeader(ctx, header) != nil {
+			t.Skipf("Error parsing header")
+		}
+		if strings.ContainsAny(epoch+id+parent+sampled, ";=") {
+			t.Skipf("Skipping ambiguous header")
+		}
+		if gotTraceID := ctx.TraceID128(); gotTraceID != strings.ToLower(epoch+id) {
+			t.Fatalf(`Inconsistent trace id parsing:
+					got: %s
+					wanted: %s
+					for header of: %s`, gotTraceID, epoch+id, header)
+		}
+		expectedSpanID, err := strconv.ParseUint(parent, 16, 64)
+		if err != nil {
+			t.Fatalf("Parsed invalid span id: %s", header)
+		}
+		if ctx.spanID != expectedSpanID {
+			t.Fatalf(`Inconsistent span id parsing:
+				got: %d
+				wanted: %d
+				for header of: %s`, ctx.spanID, expectedSpanID, header)
+		}
+		// inject the parsed context back, which must yield the same header
+		headers := TextMapCarrier{}
+		if err := (&propagatorXRay{}).Inject(ctx, headers); err != nil {
+			t.Skipf("Error injecting context")
+		}
+		recvCtx := new(spanContext)
+		if err := parseXRayHeader(recvCtx, headers[xrayTraceIDHeader]); err != nil {
+			t.Fatalf("Error parsing injected header %s: %v", headers[xrayTraceIDHeader], err)
+		}
+		if recvCtx.traceID != ctx.traceID || recvCtx.spanID != ctx.spanID {
+			t.Fatalf("Inconsistent injection/extraction of header %s", header)
+		}
+	})
+}
+
+func FuzzParseJaegerHeader(f *testing.F) {
+	testCases := []struct {
+		traceID, spanID, flags string
+	}{
+		{"5759e988bd862e3fe1be46a994272793", "53995c3f42cd8ad8", "1"},
+		{"504ab30404b09", "68bdfb1eb0428", "0"},
+		{"504ab30404b09", "68bdfb1eb0428", "3"},
+	}
+	for _, tc := range testCases {
+		f.Add(tc.traceID, tc.spanID, tc.flags)
+	}
+	f.Fuzz(func(t *testing.T, traceID, spanID, flags string) {
+		ctx := new(spanContext)
+		header := strings.Join([]string{traceID, spanID, "0", flags}, ":")
+		if parseJaegerHeader(ctx, header) != nil {
+			t.Skipf("Error parsing header")
+		}
+		if strings.ContainsAny(header, "%+ \t") {
+			t.Skipf("Skipping URL-encoded or padded header")
+		}
+		expectedTraceID := fmt.Sprintf("%032s", strings.ToLower(traceID))
+		if gotTraceID := ctx.TraceID128(); gotTraceID != strings.ReplaceAll(expectedTraceID, " ", "0") {
+			t.Fatalf(`Inconsistent trace id parsing:
+					got: %s
+					wanted: %s
+					for header of: %s`, gotTraceID, traceID, header)
+		}
+		expectedSpanID, err := strconv.ParseUint(spanID, 16, 64)
+		if err != nil {
+			t.Fatalf("Parsed invalid span id: %s", header)
+		}
+		if ctx.spanID != expectedSpanID {
+			t.Fatalf(`Inconsistent span id parsing:
+				got: %d
+				wanted: %d
+				for header of: %s`, ctx.spanID, expectedSpanID, header)
+		}
+		if ctx.spanID == 0 {
+			t.Skipf("Skipping invalid span id")
+		}
+		headers := TextMapCarrier{}
+		if err := (&propagatorJaeger{}).Inject(ctx, headers); err != nil {
+			t.Fatalf("Error injecting context: %v", err)
+		}
+		recvCtx := new(spanContext)
+		if err := parseJaegerHeader(recvCtx, headers[jaegerTraceIDHeader]); err != nil {
+			t.Fatalf("Error parsing injected header %s: %v", headers[jaegerTraceIDHeader], err)
+		}
+		if recvCtx.traceID != ctx.traceID || recvCtx.spanID != ctx.spanID {
+			t.Fatalf("Inconsistent injection/extraction of header %s", header)
+		}
+		p, _ := ctx.samplingPriority()
+		recvP, _ := recvCtx.samplingPriority()
+		if (p > 0) != (recvP > 0) {
+			t.Fatalf("Inconsistent sampling decision for header %s: %d, %d", header, p, recvP)
+		}
+	})
+}
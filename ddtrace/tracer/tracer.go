@@ -81,12 +81,36 @@ type tracer struct {
 	// partialTrace the number of partially dropped traces.
 	partialTraces uint32
 
+	// partialFlushes and partialFlushSpans track the number of partial flushes
+	// of unfinished traces and the number of spans sent by them.
+	partialFlushes, partialFlushSpans uint32
+
+	// processorDroppedTraces and processorDroppedSpans track the number of traces
+	// and spans dropped by span processors.
+	processorDroppedTraces, processorDroppedSpans uint32
+
+	// processorPanics tracks the number of traces whose processing panicked.
+	processorPanics uint32
+
 	// rulesSampling holds an instance of the rules sampler used to apply either trace sampling,
 	// or single span sampling rules on spans. These are user-defined
 	// rules for applying a sampling rate to spans that match the designated service
 	// or operation name.
 	rulesSampling *rulesSampler
 
+	// adaptiveSampling holds the adaptive sampler applied to traces which do not match
+	// sampling rules. It is nil unless adaptive sampling is enabled.
+	adaptiveSampling *adaptiveSampler
+
+	// dynamic holds the settings which can be updated at runtime through remote
+	// configuration.
+	dynamic *dynamicConfig
+
+	// rc is the remote configuration client receiving the tracer settings, shared
+	// with AppSec. It is nil unless the tracer was started using Start with remote
+	// configuration enabled.
+	rc *remoteconfig.Client
+
 	// obfuscator holds the obfuscator used to obfuscate resources in aggregated stats.
 	// obfuscator may be nil if disabled.
 	obfuscator *obfuscate.Obfuscator
@@ -120,6 +144,12 @@ var statsInterval = 10 * time.Second
 // Start starts the tracer with the given set of options. It will stop and replace
 // any running tracer, meaning that calling it several times will result in a restart
 // of the tracer by replacing the current instance with a new one.
+//
+// Unless DD_REMOTE_CONFIGURATION_ENABLED is set to false, the started tracer polls
+// the agent's remote configuration endpoint every DD_REMOTE_CONFIG_POLL_INTERVAL_SECONDS
+// seconds (5 by default) for updates of its sample rate, sampling rules and global tags,
+// using the same client as AppSec. Remote configuration is disabled when traces aren't
+// sent to an agent, i.e. when they are logged or sent over OTLP.
 func Start(opts ...StartOption) {
 	if internal.Testing {
 		return // mock tracer active
@@ -137,20 +167,37 @@ func Start(opts ...StartOption) {
 	if t.config.logStartup {
 		logStartup(t)
 	}
-	// Start AppSec with remote configuration
-	cfg := remoteconfig.DefaultClientConfig()
-	cfg.AgentURL = t.config.agentURL.String()
-	cfg.AppVersion = t.config.version
-	cfg.Env = t.config.env
-	cfg.HTTP = t.config.httpClient
-	cfg.ServiceName = t.config.serviceName
-	appsec.Start(appsec.WithRCConfig(cfg))
+	if t.config.remoteConfigEnabled && !t.config.logToStdout && t.config.otlpEndpoint == "" {
+		if err := t.newRemoteConfig(remoteConfigClientConfig(t.config)); err != nil {
+			log.Warn("Remote config: unable to create the client, tracer settings won't be updated at runtime: %v", err)
+		}
+	}
+	if t.rc != nil {
+		// Start AppSec with the shared remote configuration client, which is
+		// started once AppSec registered its products
+		appsec.Start(appsec.WithRCClient(t.rc))
+		t.rc.Start()
+	} else {
+		appsec.Start()
+	}
 	// start instrumentation telemetry unless it is disabled through the
 	// DD_INSTRUMENTATION_TELEMETRY_ENABLED env var
 	startTelemetry(t.config)
 	_ = t.hostname() // Prime the hostname cache
 }
 
+// remoteConfigClientConfig returns the configuration of a remote configuration
+// client for the tracer configured by c.
+func remoteConfigClientConfig(c *config) remoteconfig.ClientConfig {
+	cfg := remoteconfig.DefaultClientConfig()
+	cfg.AgentURL = c.agentURL.String()
+	cfg.AppVersion = c.version
+	cfg.Env = c.env
+	cfg.HTTP = c.httpClient
+	cfg.ServiceName = c.serviceName
+	return cfg
+}
+
 // Stop stops the started tracer. Subsequent calls are valid but become no-op.
 func Stop() {
 	internal.SetGlobalTracer(&internal.NoopTracer{})
@@ -213,6 +260,8 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	var writer traceWriter
 	if c.logToStdout {
 		writer = newLogTraceWriter(c, statsd)
+	} else if c.otlpEndpoint != "" {
+		writer = newOTLPTraceWriter(c, statsd)
 	} else {
 		writer = newAgentTraceWriter(c, sampler, statsd)
 	}
@@ -247,6 +296,13 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 		}),
 		statsd: statsd,
 	}
+	t.dynamic = newDynamicConfig(c, t.rulesSampling.traces)
+	if c.partialFlushEnabled && t.rulesSampling.traces.matchesLateFields() {
+		log.Warn("Partial flushing is enabled: trace sampling rules matching the resource or tags of spans only match those set when the root span starts.")
+	}
+	if c.adaptiveSamplingTPS > 0 {
+		t.adaptiveSampling = newAdaptiveSampler(c.adaptiveSamplingTPS)
+	}
 	return t
 }
 
@@ -262,6 +318,14 @@ func newTracer(opts ...StartOption) *tracer {
 			t.reportRuntimeMetrics(defaultMetricsReportInterval)
 		}()
 	}
+	if c.runtimeMetricsV2 {
+		log.Debug("Runtime metrics v2 enabled.")
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.reportRuntimeMetricsV2(defaultMetricsReportInterval)
+		}()
+	}
 	t.wg.Add(1)
 	go func() {
 		defer t.wg.Done()
@@ -312,6 +376,7 @@ func (t *tracer) worker(tick <-chan time.Time) {
 		select {
 		case trace := <-t.out:
 			t.sampleFinishedTrace(trace)
+			t.processTrace(trace)
 			if len(trace.spans) != 0 {
 				t.traceWriter.add(trace.spans)
 			}
@@ -337,6 +402,7 @@ func (t *tracer) worker(tick <-chan time.Time) {
 				select {
 				case trace := <-t.out:
 					t.sampleFinishedTrace(trace)
+					t.processTrace(trace)
 					if len(trace.spans) != 0 {
 						t.traceWriter.add(trace.spans)
 					}
@@ -430,6 +496,16 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 			}
 		}
 	}
+	var remote *spanContext
+	if context != nil && context.span == nil {
+		remote = context
+		if context.traceID.Empty() {
+			// the remote context only holds baggage or span links, e.g. extracted from
+			// a W3C baggage header without trace context, or when restarting traces on
+			// extraction: start a new trace carrying them
+			context = nil
+		}
+	}
 	if pprofContext == nil {
 		// For root span's without context, there is no pprofContext, but we need
 		// one to avoid a panic() in pprof.WithLabels(). Using context.Background()
@@ -453,6 +529,7 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 		TraceID:      id,
 		Start:        startTime,
 		noDebugStack: t.config.noDebugStack,
+		errorCapture: &t.config.errorCapture,
 	}
 	if t.config.hostname != "" {
 		span.setMeta(keyHostname, t.config.hostname)
@@ -477,7 +554,26 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 			}
 		}
 	}
+	if len(opts.SpanLinks) > 0 {
+		span.SpanLinks = append(make([]ddtrace.SpanLink, 0, len(opts.SpanLinks)), opts.SpanLinks...)
+	}
+	if remote != nil {
+		span.SpanLinks = append(span.SpanLinks, remote.spanLinks...)
+		if remote.reparentID != "" {
+			span.setMeta(keyReparentID, remote.reparentID)
+		}
+	}
 	span.context = newSpanContext(span, context)
+	if remote != nil && context == nil {
+		remote.ForeachBaggageItem(func(k, v string) bool {
+			span.context.setBaggageItem(k, v)
+			return true
+		})
+	}
+	if len(t.config.baggageTagKeys) > 0 && (context == nil || context.span == nil) {
+		// remote parent or new trace
+		setBaggageTags(span, t.config.baggageTagKeys)
+	}
 	span.setMetric(ext.Pid, float64(t.pid))
 	span.setMeta("language", "go")
 
@@ -486,7 +582,7 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 		span.SetTag(k, v)
 	}
 	// add global tags
-	for k, v := range t.config.globalTags {
+	for k, v := range t.dynamic.tags() {
 		span.SetTag(k, v)
 	}
 	if t.config.serviceMappings != nil {
@@ -511,9 +607,14 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	if t.config.env != "" {
 		span.setMeta(ext.Environment, t.config.env)
 	}
-	if _, ok := span.context.samplingPriority(); !ok {
-		// if not already sampled or a brand new trace, sample it
-		t.sample(span)
+	if _, ok := span.context.samplingPriority(); !ok && !span.context.trace.samplingIsDeferred() {
+		if context == nil && t.defersSampling() {
+			// brand new trace, sample it when its root finishes
+			span.context.trace.deferSampling()
+		} else {
+			// if not already sampled or a brand new trace, sample it
+			t.sample(span)
+		}
 	}
 	pprofContext, span.taskEnd = startExecutionTracerTask(pprofContext, span)
 	if t.config.profilerHotspots || t.config.profilerEndpoints {
@@ -584,6 +685,9 @@ func (t *tracer) Stop() {
 	t.stopOnce.Do(func() {
 		close(t.stop)
 		t.statsd.Incr("datadog.tracer.stopped", nil, 1)
+		if t.rc != nil {
+			t.rc.Stop()
+		}
 	})
 	t.stats.Stop()
 	t.wg.Wait()
@@ -594,6 +698,10 @@ func (t *tracer) Stop() {
 
 // Inject uses the configured or default TextMap Propagator.
 func (t *tracer) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	if sctx, ok := ctx.(*spanContext); ok && sctx.trace != nil && sctx.trace.root != nil {
+		// the sampling decision is propagated, it can't be deferred any longer
+		t.sampleDeferred(sctx, now()-sctx.trace.root.Start)
+	}
 	return t.config.propagator.Inject(ctx, carrier)
 }
 
@@ -605,7 +713,8 @@ func (t *tracer) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
 // sampleRateMetricKey is the metric key holding the applied sample rate. Has to be the same as the Agent.
 const sampleRateMetricKey = "_sample_rate"
 
-// Sample samples a span with the internal sampler.
+// Sample samples a span with the internal sampler. The span is guarded while
+// being read, as deferred sampling samples root spans which are already shared.
 func (t *tracer) sample(span *span) {
 	if _, ok := span.context.samplingPriority(); ok {
 		// sampling decision was already made
@@ -617,14 +726,52 @@ func (t *tracer) sample(span *span) {
 		return
 	}
 	if rs, ok := sampler.(RateSampler); ok && rs.Rate() < 1 {
+		span.Lock()
 		span.setMetric(sampleRateMetricKey, rs.Rate())
+		span.Unlock()
 	}
 	if t.rulesSampling.SampleTrace(span) {
 		return
 	}
+	if t.adaptiveSampling != nil {
+		t.adaptiveSampling.apply(span)
+		return
+	}
 	t.prioritySampling.apply(span)
 }
 
+// defersSampling reports whether the sampling decision of the traces started locally
+// is deferred to the finish of their root span. It is with deferred sampling, and
+// when trace sampling rules match the resource or tags of spans, unless partial
+// flushing is enabled, as it sends spans before their root span finishes.
+func (t *tracer) defersSampling() bool {
+	if t.config.deferredSampling {
+		return true
+	}
+	return !t.config.partialFlushEnabled && t.rulesSampling.traces.matchesLateFields()
+}
+
+// sampleDeferred makes the sampling decision of the trace of ctx if it was deferred,
+// d being the duration of the root span so far. With deferred sampling, traces with
+// errors or whose root span lasted at least the latency threshold are kept, within
+// the limit of the trace rate limiter. The others are sampled as usual.
+func (t *tracer) sampleDeferred(ctx *spanContext, d int64) {
+	if !ctx.trace.undeferSampling() {
+		return
+	}
+	root := ctx.trace.root
+	if _, ok := root.context.samplingPriority(); ok {
+		// sampling decision was already made, e.g. manually
+		return
+	}
+	if t.config.deferredSampling && (atomic.LoadUint32(&ctx.trace.errored) == 1 ||
+		(t.config.deferredSamplingLatency > 0 && d >= t.config.deferredSamplingLatency.Nanoseconds())) &&
+		t.rulesSampling.traces.keepDeferred(root, time.Now()) {
+		return
+	}
+	t.sample(root)
+}
+
 func startExecutionTracerTask(ctx gocontext.Context, span *span) (gocontext.Context, func()) {
 	if !rt.IsEnabled() {
 		return ctx, func() {}
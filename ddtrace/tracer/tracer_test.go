@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"runtime"
 	rt "runtime/trace"
 	"strconv"
@@ -33,6 +34,7 @@ import (
 	maininternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
 
@@ -2375,3 +2377,163 @@ func TestExecutionTraceSpanTagged(t *testing.T) {
 	assert.Equal(t, tracedSpan.Meta["go_execution_traced"], "yes")
 	assert.NotContains(t, untracedSpan.Meta, "go_execution_traced")
 }
+
+func TestDeferredSampling(t *testing.T) {
+	// keep the traces of server errors and drop the others
+	rules := WithSamplingRules([]SamplingRule{
+		{Tags: map[string]*regexp.Regexp{ext.HTTPCode: regexp.MustCompile("^5")}, Rate: 1},
+		RateRule(0),
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		child := tracer.StartSpan("db.query", ChildOf(root.Context())).(*span)
+		_, ok := root.context.samplingPriority()
+		assert.False(ok)
+		assert.True(root.context.trace.samplingIsDeferred())
+		child.Finish()
+		root.SetTag(ext.HTTPCode, "503")
+		root.Finish()
+		p, ok := root.context.samplingPriority()
+		assert.True(ok)
+		assert.Equal(ext.PriorityUserKeep, p)
+		assert.EqualValues(ext.PriorityUserKeep, root.Metrics[keySamplingPriority])
+
+		root = tracer.StartSpan("web.request").(*span)
+		root.SetTag(ext.HTTPCode, "200")
+		root.Finish()
+		p, _ = root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserReject, p)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		child := tracer.StartSpan("db.query", ChildOf(root.Context())).(*span)
+		child.Finish(WithError(errors.New("timeout")))
+		root.Finish()
+		p, _ := root.context.samplingPriority()
+		assert.Equal(t, ext.PriorityAutoKeep, p)
+		assert.Equal(t, "-"+strconv.Itoa(int(samplernames.Deferred)), root.context.trace.propagatingTags[keyDecisionMaker])
+	})
+
+	t.Run("rate-limited", func(t *testing.T) {
+		t.Setenv("DD_TRACE_RATE_LIMIT", "1")
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		var priorities []int
+		for i := 0; i < 3; i++ {
+			root := tracer.StartSpan("web.request").(*span)
+			root.Finish(WithError(errors.New("timeout")))
+			p, _ := root.context.samplingPriority()
+			priorities = append(priorities, p)
+		}
+		// errored traces above the rate limit are sampled as usual
+		assert.Equal(t, []int{ext.PriorityAutoKeep, ext.PriorityUserReject, ext.PriorityUserReject}, priorities)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(time.Second))
+		defer stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		root.Finish(FinishTime(time.Unix(0, root.Start).Add(2 * time.Second)))
+		p, _ := root.context.samplingPriority()
+		assert.Equal(ext.PriorityAutoKeep, p)
+		assert.Equal("-"+strconv.Itoa(int(samplernames.Deferred)), root.context.trace.propagatingTags[keyDecisionMaker])
+
+		root = tracer.StartSpan("web.request").(*span)
+		root.Finish(FinishTime(time.Unix(0, root.Start).Add(time.Millisecond)))
+		p, _ = root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserReject, p)
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		root.SetTag(ext.ManualDrop, true)
+		root.Finish(WithError(errors.New("boom")))
+		p, _ := root.context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+	})
+
+	t.Run("inject", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		root := tracer.StartSpan("web.request").(*span)
+		child := tracer.StartSpan("http.request", ChildOf(root.Context())).(*span)
+		carrier := TextMapCarrier{}
+		assert.NoError(tracer.Inject(child.Context(), carrier))
+		assert.Equal("-1", carrier[DefaultPriorityHeader])
+		assert.False(root.context.trace.samplingIsDeferred())
+
+		// the propagated decision is kept, even though the trace has an error
+		child.Finish(WithError(errors.New("boom")))
+		root.Finish()
+		p, _ := root.context.samplingPriority()
+		assert.Equal(ext.PriorityUserReject, p)
+	})
+
+	t.Run("inject-concurrent", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		// the root span is sampled on injection while it is being tagged
+		root := tracer.StartSpan("web.request").(*span)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				root.SetTag("key"+strconv.Itoa(i), i)
+			}
+		}()
+		assert.NoError(t, tracer.Inject(root.Context(), TextMapCarrier{}))
+		wg.Wait()
+		root.Finish()
+		_, ok := root.context.samplingPriority()
+		assert.True(t, ok)
+	})
+
+	t.Run("remote-parent", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, rules, WithDeferredSampling(0))
+		defer stop()
+
+		sctx, err := tracer.Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+		})
+		assert.NoError(t, err)
+		root := tracer.StartSpan("web.request", ChildOf(sctx)).(*span)
+		assert.False(t, root.context.trace.samplingIsDeferred())
+		_, ok := root.context.samplingPriority()
+		assert.True(t, ok)
+		root.Finish()
+	})
+
+	t.Run("config", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.False(newConfig().deferredSampling)
+
+		t.Setenv("DD_TRACE_DEFERRED_SAMPLING_ENABLED", "true")
+		t.Setenv("DD_TRACE_DEFERRED_SAMPLING_LATENCY_THRESHOLD", "500ms")
+		c := newConfig()
+		assert.True(c.deferredSampling)
+		assert.Equal(500*time.Millisecond, c.deferredSamplingLatency)
+
+		// not compatible with partial flushing
+		assert.False(newConfig(WithPartialFlushing(10)).deferredSampling)
+	})
+}
@@ -74,10 +74,11 @@ type transport interface {
 }
 
 type httpTransport struct {
-	traceURL string            // the delivery URL for traces
-	statsURL string            // the delivery URL for stats
-	client   *http.Client      // the HTTP client used in the POST
-	headers  map[string]string // the Transport headers
+	traceURL    string            // the delivery URL for traces
+	traceURLV05 string            // the delivery URL for traces encoded using the v0.5 protocol
+	statsURL    string            // the delivery URL for stats
+	client      *http.Client      // the HTTP client used in the POST
+	headers     map[string]string // the Transport headers
 }
 
 // newTransport returns a new Transport implementation that sends traces to a
@@ -100,10 +101,11 @@ func newHTTPTransport(url string, client *http.Client) *httpTransport {
 		defaultHeaders["Datadog-Container-ID"] = cid
 	}
 	return &httpTransport{
-		traceURL: fmt.Sprintf("%s/v0.4/traces", url),
-		statsURL: fmt.Sprintf("%s/v0.6/stats", url),
-		client:   client,
-		headers:  defaultHeaders,
+		traceURL:    fmt.Sprintf("%s/v0.4/traces", url),
+		traceURLV05: fmt.Sprintf("%s/v0.5/traces", url),
+		statsURL:    fmt.Sprintf("%s/v0.6/stats", url),
+		client:      client,
+		headers:     defaultHeaders,
 	}
 }
 
@@ -136,7 +138,11 @@ func (t *httpTransport) sendStats(p *statsPayload) error {
 }
 
 func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
-	req, err := http.NewRequest("POST", t.traceURL, p)
+	traceURL := t.traceURL
+	if p.protocol == traceProtocolV05 {
+		traceURL = t.traceURLV05
+	}
+	req, err := http.NewRequest("POST", traceURL, p)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create http request: %v", err)
 	}
@@ -144,7 +150,7 @@ func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
 		req.Header.Set(header, value)
 	}
 	req.Header.Set(traceCountHeader, strconv.Itoa(p.itemCount()))
-	req.Header.Set("Content-Length", strconv.Itoa(p.size()))
+	req.ContentLength = int64(p.size())
 	req.Header.Set(headerComputedTopLevel, "yes")
 	if t, ok := traceinternal.GetGlobalTracer().(*tracer); ok {
 		if t.config.canComputeStats() {
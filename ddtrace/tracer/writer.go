@@ -49,16 +49,29 @@ type agentTraceWriter struct {
 
 	// statsd is used to send metrics
 	statsd statsdClient
+
+	// spool, if not nil, persists payloads which failed to be sent so that they
+	// can be replayed once the agent is reachable again
+	spool *traceSpool
 }
 
 func newAgentTraceWriter(c *config, s *prioritySampler, statsdClient statsdClient) *agentTraceWriter {
-	return &agentTraceWriter{
+	w := &agentTraceWriter{
 		config:           c,
-		payload:          newPayload(),
+		payload:          newPayloadForProtocol(c.traceProtocol),
 		climit:           make(chan struct{}, concurrentConnectionLimit),
 		prioritySampling: s,
 		statsd:           statsdClient,
 	}
+	if c.spoolDir != "" {
+		spool, err := newTraceSpool(c.spoolDir, c.spoolMaxBytes, c.spoolMaxAge, statsdClient)
+		if err != nil {
+			log.Warn("Unable to set up the trace spool, traces which fail to be sent will be dropped: %v", err)
+		} else {
+			w.spool = spool
+		}
+	}
+	return w
 }
 
 func (h *agentTraceWriter) add(trace []*span) {
@@ -86,7 +99,7 @@ func (h *agentTraceWriter) flush() {
 	h.wg.Add(1)
 	h.climit <- struct{}{}
 	oldp := h.payload
-	h.payload = newPayload()
+	h.payload = newPayloadForProtocol(h.config.traceProtocol)
 	go func(p *payload) {
 		defer func(start time.Time) {
 			// Once the payload has been used, clear the buffer for garbage
@@ -113,17 +126,49 @@ func (h *agentTraceWriter) flush() {
 				if err := h.prioritySampling.readRatesJSON(rc); err != nil {
 					h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
 				}
+				if h.spool != nil {
+					// the agent is reachable, send any traces which previously failed,
+					// without holding up the connection slot of this payload
+					h.wg.Add(1)
+					go func() {
+						defer h.wg.Done()
+						h.spool.replay(h.send, spoolReplayBatch)
+					}()
+				}
 				return
 			}
 			log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
 			p.reset()
 			time.Sleep(time.Millisecond)
 		}
+		if h.spool != nil {
+			if err := h.spool.store(p); err == nil {
+				log.Debug("spooled %d traces which failed to be sent", count)
+				return
+			}
+			log.Error("failure spooling traces: %v", err)
+		}
 		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
 		log.Error("lost %d traces: %v", count, err)
 	}(oldp)
 }
 
+// send sends the payload p to the agent, once, reading the sampling rates from
+// the response.
+func (h *agentTraceWriter) send(p *payload) error {
+	size, count := p.size(), p.itemCount()
+	rc, err := h.config.transport.send(p)
+	if err != nil {
+		return err
+	}
+	h.statsd.Count("datadog.tracer.flush_bytes", int64(size), nil, 1)
+	h.statsd.Count("datadog.tracer.flush_traces", int64(count), nil, 1)
+	if err := h.prioritySampling.readRatesJSON(rc); err != nil {
+		h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
+	}
+	return nil
+}
+
 // logWriter specifies the output target of the logTraceWriter; replaced in tests.
 var logWriter io.Writer = os.Stdout
 
@@ -239,6 +284,14 @@ func (h *logTraceWriter) encodeSpan(s *span) {
 	h.buf.Write(strconv.AppendInt(scratch[:0], s.Duration, 10))
 	h.buf.WriteString(`,"service":`)
 	h.marshalString(s.Service)
+	if len(s.SpanLinks) > 0 {
+		if links, err := json.Marshal(s.SpanLinks); err != nil {
+			log.Error("Error marshaling span links: %v", err)
+		} else {
+			h.buf.WriteString(`,"span_links":`)
+			h.buf.Write(links)
+		}
+	}
 	h.buf.WriteString(`}`)
 }
 
@@ -24,6 +24,7 @@ import (
 func TestImplementsTraceWriter(t *testing.T) {
 	assert.Implements(t, (*traceWriter)(nil), &agentTraceWriter{})
 	assert.Implements(t, (*traceWriter)(nil), &logTraceWriter{})
+	assert.Implements(t, (*traceWriter)(nil), &otlpTraceWriter{})
 }
 
 // makeSpan returns a span, adding n entries to meta and metrics each.
@@ -52,6 +52,10 @@ func Start(opts ...StartOption) {
 	appsec.startRC()
 
 	// If the env var is not set ASM is disabled, but can be enabled through remote config
+	if !set && cfg.rc == nil {
+		log.Debug("appsec: %s is not set and remote configuration is disabled. AppSec won't start", enabledEnvVar)
+		return
+	}
 	if !set {
 		log.Debug("appsec: %s is not set. AppSec won't start until activated through remote configuration", enabledEnvVar)
 		if err := appsec.enableRemoteActivation(); err != nil {
@@ -97,11 +101,19 @@ type appsec struct {
 	cfg       *Config
 	limiter   *TokenTicker
 	rc        *remoteconfig.Client
+	rcShared  bool // rc is shared with the tracer, which starts and stops it
 	wafHandle *waf.Handle
 	started   bool
 }
 
 func newAppSec(cfg *Config) *appsec {
+	if cfg.rcClient != nil {
+		return &appsec{
+			cfg:      cfg,
+			rc:       cfg.rcClient,
+			rcShared: true,
+		}
+	}
 	var client *remoteconfig.Client
 	var err error
 	if cfg.rc != nil {
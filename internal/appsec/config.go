@@ -50,6 +50,9 @@ type Config struct {
 	obfuscator ObfuscatorConfig
 	// rc is the remote configuration client used to receive product configuration updates. Nil if rc is disabled (default)
 	rc *remoteconfig.ClientConfig
+	// rcClient is the remote configuration client shared with the tracer, which starts and stops it.
+	// Nil unless set with WithRCClient.
+	rcClient *remoteconfig.Client
 }
 
 // WithRCConfig sets the AppSec remote config client configuration to the specified cfg
@@ -59,6 +62,16 @@ func WithRCConfig(cfg remoteconfig.ClientConfig) StartOption {
 	}
 }
 
+// WithRCClient makes AppSec register its products, capabilities and callbacks on the given remote config
+// client instead of creating its own. The client is owned by the caller, which is responsible for starting
+// and stopping it.
+func WithRCClient(client *remoteconfig.Client) StartOption {
+	return func(c *Config) {
+		c.rc = &client.ClientConfig
+		c.rcClient = client
+	}
+}
+
 // ObfuscatorConfig wraps the key and value regexp to be passed to the WAF to perform obfuscation.
 type ObfuscatorConfig struct {
 	KeyRegex   string
@@ -52,6 +52,18 @@ func mergeMaps[K comparable, V any](m1 map[K]V, m2 map[K]V) map[K]V {
 
 // combineRCRulesUpdates updates the state of the given rulesManager with the combination of all the provided rules updates
 func combineRCRulesUpdates(r *rulesManager, updates map[string]remoteconfig.ProductUpdate) (map[string]rc.ApplyStatus, error) {
+	// The updates of the products not related to rules, such as the tracer's on a shared client, are left to
+	// their own callbacks
+	rulesUpdates := make(map[string]remoteconfig.ProductUpdate, len(updates))
+	for p, u := range updates {
+		switch p {
+		case rc.ProductASMData, rc.ProductASMDD, rc.ProductASM:
+			rulesUpdates[p] = u
+		default:
+			log.Debug("appsec: Remote config: ignoring unsubscribed product %s", p)
+		}
+	}
+	updates = rulesUpdates
 	statuses := map[string]rc.ApplyStatus{}
 	// Set the default statuses for all updates to unacknowledged
 	for _, u := range updates {
@@ -113,8 +125,6 @@ updateLoop:
 				}
 				r.addEdit(path, f)
 			}
-		default:
-			log.Debug("appsec: Remote config: ignoring unsubscribed product %s", p)
 		}
 	}
 
@@ -289,13 +299,13 @@ func mergeRulesDataEntries(entries1, entries2 []rc.ASMDataRuleDataEntry) []rc.AS
 }
 
 func (a *appsec) startRC() {
-	if a.rc != nil {
+	if a.rc != nil && !a.rcShared {
 		a.rc.Start()
 	}
 }
 
 func (a *appsec) stopRC() {
-	if a.rc != nil {
+	if a.rc != nil && !a.rcShared {
 		a.rc.Stop()
 	}
 }
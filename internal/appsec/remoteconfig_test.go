@@ -358,6 +358,41 @@ func TestRemoteActivationScenarios(t *testing.T) {
 		require.Nil(t, activeAppSec)
 		require.False(t, Enabled())
 	})
+
+	t.Run("shared client", func(t *testing.T) {
+		t.Setenv(enabledEnvVar, "")
+		os.Unsetenv(enabledEnvVar)
+		client, err := remoteconfig.NewClient(remoteconfig.DefaultClientConfig())
+		require.NoError(t, err)
+		client.RegisterProduct(rc.ProductAPMTracing)
+		Start(WithRCClient(client))
+		// the shared client is neither started nor stopped by AppSec, Stop would block otherwise
+		defer Stop()
+
+		require.NotNil(t, activeAppSec)
+		require.Same(t, client, activeAppSec.rc)
+		require.Contains(t, client.Capabilities, remoteconfig.ASMActivation)
+		require.Contains(t, client.Products, rc.ProductASMFeatures)
+		require.Contains(t, client.Products, rc.ProductAPMTracing)
+	})
+
+	t.Run("remote config disabled", func(t *testing.T) {
+		t.Setenv(enabledEnvVar, "")
+		os.Unsetenv(enabledEnvVar)
+		Start()
+		defer Stop()
+		require.Nil(t, activeAppSec)
+	})
+}
+
+func TestCombineRCRulesUpdatesOtherProducts(t *testing.T) {
+	r, err := newRulesManager(nil)
+	require.NoError(t, err)
+	statuses, err := combineRCRulesUpdates(r, map[string]remoteconfig.ProductUpdate{
+		rc.ProductAPMTracing: {"datadog/2/APM_TRACING/config/config": []byte(`{}`)},
+	})
+	require.NoError(t, err)
+	require.Empty(t, statuses)
 }
 
 func TestCapabilities(t *testing.T) {
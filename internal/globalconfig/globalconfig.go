@@ -24,6 +24,7 @@ type config struct {
 	analyticsRate float64
 	serviceName   string
 	runtimeID     string
+	headersAsTags map[string]string
 }
 
 // AnalyticsRate returns the sampling rate at which events should be marked. It uses
@@ -62,3 +63,47 @@ func RuntimeID() string {
 	defer cfg.mu.RUnlock()
 	return cfg.runtimeID
 }
+
+// SetHeaderTag sets the tag the given lower-cased HTTP header is recorded in by the
+// HTTP integrations. An empty tag means the default tag name of the header is used.
+func SetHeaderTag(header, tag string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.headersAsTags == nil {
+		cfg.headersAsTags = make(map[string]string)
+	}
+	cfg.headersAsTags[header] = tag
+}
+
+// HeaderTag returns the tag the given lower-cased HTTP header is recorded in, and
+// whether the header is recorded at all.
+func HeaderTag(header string) (tag string, ok bool) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	tag, ok = cfg.headersAsTags[header]
+	return tag, ok
+}
+
+// ForEachHeaderTag calls fn for each HTTP header recorded as a tag, along with the
+// tag it is recorded in. fn must not modify the global configuration.
+func ForEachHeaderTag(fn func(header, tag string)) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	for header, tag := range cfg.headersAsTags {
+		fn(header, tag)
+	}
+}
+
+// HeaderTagsLen returns the number of HTTP headers recorded as tags.
+func HeaderTagsLen() int {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return len(cfg.headersAsTags)
+}
+
+// ClearHeaderTags removes all the HTTP headers recorded as tags.
+func ClearHeaderTags() {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.headersAsTags = nil
+}
@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+// Package normalizer provides helpers to normalize values used to configure the
+// tracer and the integrations.
+package normalizer
+
+import (
+	"strings"
+)
+
+// HeaderTag parses a header-to-tag mapping of the form "header" or "header:tag", as
+// used by DD_TRACE_HEADER_TAGS, and returns the lower-cased header name along with
+// the tag name. The returned tag is empty when the mapping does not specify one, in
+// which case the default tag name of the header should be used.
+func HeaderTag(headerAsTag string) (header string, tag string) {
+	header, tag, _ = strings.Cut(headerAsTag, ":")
+	return strings.ToLower(strings.TrimSpace(header)), strings.TrimSpace(tag)
+}
+
+// HeaderTagName returns the default name of the tag holding the value of the given
+// header, which is prefix followed by a dot and the lower-cased header name, in which
+// any character other than a letter, a digit or a hyphen is replaced by an underscore.
+func HeaderTagName(prefix, header string) string {
+	var b strings.Builder
+	b.Grow(len(prefix) + 1 + len(header))
+	b.WriteString(prefix)
+	b.WriteByte('.')
+	for _, r := range strings.ToLower(header) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
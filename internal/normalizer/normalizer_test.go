@@ -0,0 +1,32 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package normalizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderTag(t *testing.T) {
+	for _, tt := range []struct {
+		in, header, tag string
+	}{
+		{in: "X-Request-Id:req.id", header: "x-request-id", tag: "req.id"},
+		{in: " User-Agent ", header: "user-agent"},
+		{in: "X-Custom:", header: "x-custom"},
+		{in: "X-Custom : tag:with:colons ", header: "x-custom", tag: "tag:with:colons"},
+	} {
+		header, tag := HeaderTag(tt.in)
+		assert.Equal(t, tt.header, header, tt.in)
+		assert.Equal(t, tt.tag, tag, tt.in)
+	}
+}
+
+func TestHeaderTagName(t *testing.T) {
+	assert.Equal(t, "http.request.headers.x-request-id", HeaderTagName("http.request.headers", "X-Request-Id"))
+	assert.Equal(t, "http.response.headers.x_b3_trace_id", HeaderTagName("http.response.headers", "X.B3 Trace_Id"))
+}
@@ -51,6 +51,19 @@ const (
 	ASMUserBlocking
 )
 
+const (
+	// APMTracingSampleRate represents the capability to update the trace sample rate and
+	// sampling rules through remote configuration
+	APMTracingSampleRate Capability = 12 + iota
+	// the capabilities to update the trace/log correlation and the HTTP header tags
+	// are not supported
+	_
+	_
+	// APMTracingCustomTags represents the capability to update the global tags through remote
+	// configuration
+	APMTracingCustomTags
+)
+
 // ProductUpdate represents an update for a specific product.
 // It is a map of file path to raw file content
 type ProductUpdate map[string][]byte
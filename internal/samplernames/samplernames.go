@@ -34,4 +34,11 @@ const (
 	// SingleSpan specifies that the span was sampled by single
 	// span sampling rules.
 	SingleSpan SamplerName = 8
+	// Adaptive specifies that the span was sampled by the adaptive
+	// sampler, with a rate computed by the tracer to keep a target
+	// throughput of traces.
+	Adaptive SamplerName = 9
+	// Deferred specifies that the span was kept by deferred sampling,
+	// because its trace had an error or was slow.
+	Deferred SamplerName = 10
 )
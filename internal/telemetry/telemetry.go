@@ -43,6 +43,15 @@ func (c *client) ProductStart(namespace Namespace, configuration []Configuration
 	}
 }
 
+// ConfigChange sends an app-client-configuration-change event reporting the
+// given configuration, such as after it was updated at runtime. It is a no-op
+// if the client has not started.
+func (c *client) ConfigChange(configuration []Configuration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configChange(configuration)
+}
+
 // configChange enqueues an app-client-configuration-change event to be flushed.
 // Must be called with c.mu locked.
 func (c *client) configChange(configuration []Configuration) {
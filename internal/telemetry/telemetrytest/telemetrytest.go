@@ -42,6 +42,13 @@ func (c *MockClient) ProductStart(namespace telemetry.Namespace, configuration [
 	}
 }
 
+// ConfigChange adds configuration data to the mock client.
+func (c *MockClient) ConfigChange(configuration []telemetry.Configuration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Configuration = append(c.Configuration, configuration...)
+}
+
 // ProductStop signals a product has stopped and disables that product in the mock client.
 // ProductStop is NOOP for the tracer namespace, since the tracer is not considered a product.
 func (c *MockClient) ProductStop(namespace telemetry.Namespace) {
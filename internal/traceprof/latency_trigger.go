@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// globalLatencyTrigger is shared between the profiler and the tracer.
+var globalLatencyTrigger = &LatencyTrigger{}
+
+// GlobalLatencyTrigger returns the latency trigger that is shared between
+// tracing and profiling to support latency-triggered execution traces.
+func GlobalLatencyTrigger() *LatencyTrigger {
+	return globalLatencyTrigger
+}
+
+// SlowSpan describes a local root span which lasted longer than the threshold
+// of a LatencyTrigger.
+type SlowSpan struct {
+	TraceID  uint64
+	SpanID   uint64
+	Endpoint string // empty if the resource of the span may contain PII
+	Duration time.Duration
+}
+
+// LatencyTrigger calls a function for the local root spans of configured
+// endpoints lasting longer than a threshold. The tracer reports the local root
+// spans to it, and the profiler sets it up to collect execution traces of the
+// following requests. It is disabled by default, which makes Observe almost
+// zero-cost.
+type LatencyTrigger struct {
+	cfg atomic.Value // *latencyTriggerConfig
+}
+
+type latencyTriggerConfig struct {
+	threshold time.Duration
+	endpoints map[string]struct{} // nil matches all endpoints
+	fn        func(SlowSpan)
+}
+
+// Set configures l to call fn for the spans of the given endpoints, or of all
+// endpoints if none is given, lasting longer than threshold. fn must not block.
+// A nil fn disables l.
+func (l *LatencyTrigger) Set(threshold time.Duration, endpoints []string, fn func(SlowSpan)) {
+	cfg := &latencyTriggerConfig{threshold: threshold, fn: fn}
+	if len(endpoints) > 0 {
+		cfg.endpoints = make(map[string]struct{}, len(endpoints))
+		for _, e := range endpoints {
+			cfg.endpoints[e] = struct{}{}
+		}
+	}
+	l.cfg.Store(cfg)
+}
+
+// config returns the configuration of l, or nil if l is disabled.
+func (l *LatencyTrigger) config() *latencyTriggerConfig {
+	cfg, _ := l.cfg.Load().(*latencyTriggerConfig)
+	if cfg == nil || cfg.fn == nil {
+		return nil
+	}
+	return cfg
+}
+
+// Enabled reports whether l is enabled.
+func (l *LatencyTrigger) Enabled() bool {
+	return l.config() != nil
+}
+
+// Observe reports the local root span s to l, which calls its function if s
+// matches its configuration.
+func (l *LatencyTrigger) Observe(s SlowSpan) {
+	cfg := l.config()
+	if cfg == nil || s.Duration <= cfg.threshold {
+		return
+	}
+	if cfg.endpoints != nil {
+		if _, ok := cfg.endpoints[s.Endpoint]; !ok {
+			return
+		}
+	}
+	cfg.fn(s)
+}
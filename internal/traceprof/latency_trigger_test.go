@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package traceprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTrigger(t *testing.T) {
+	var got []SlowSpan
+	record := func(s SlowSpan) { got = append(got, s) }
+
+	t.Run("disabled", func(t *testing.T) {
+		got = nil
+		var l LatencyTrigger
+		require.False(t, l.Enabled())
+		l.Observe(SlowSpan{Duration: time.Hour})
+		l.Set(time.Second, nil, nil)
+		require.False(t, l.Enabled())
+		l.Observe(SlowSpan{Duration: time.Hour})
+		require.Empty(t, got)
+	})
+
+	t.Run("all endpoints", func(t *testing.T) {
+		got = nil
+		var l LatencyTrigger
+		l.Set(time.Second, nil, record)
+		require.True(t, l.Enabled())
+		l.Observe(SlowSpan{TraceID: 1, Endpoint: "GET /foo", Duration: time.Second})
+		l.Observe(SlowSpan{TraceID: 2, Endpoint: "GET /foo", Duration: 2 * time.Second})
+		l.Observe(SlowSpan{TraceID: 3, Duration: 2 * time.Second})
+		require.Equal(t, []SlowSpan{
+			{TraceID: 2, Endpoint: "GET /foo", Duration: 2 * time.Second},
+			{TraceID: 3, Duration: 2 * time.Second},
+		}, got)
+	})
+
+	t.Run("endpoints", func(t *testing.T) {
+		got = nil
+		var l LatencyTrigger
+		l.Set(time.Second, []string{"GET /foo"}, record)
+		l.Observe(SlowSpan{TraceID: 1, Endpoint: "GET /foo", Duration: 2 * time.Second})
+		l.Observe(SlowSpan{TraceID: 2, Endpoint: "GET /bar", Duration: 2 * time.Second})
+		l.Observe(SlowSpan{TraceID: 3, Duration: 2 * time.Second})
+		require.Len(t, got, 1)
+		require.Equal(t, uint64(1), got[0].TraceID)
+	})
+}
@@ -0,0 +1,277 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// captureTrigger is the value of the trigger tag of the batches uploaded by CaptureNow.
+const captureTrigger = "manual"
+
+// defaultCaptureDuration is the default duration of the captures triggered through
+// CaptureHandler.
+const defaultCaptureDuration = 30 * time.Second
+
+var (
+	errNotRunning        = errors.New("profiler is not running")
+	errCaptureInProgress = errors.New("a profile capture is already in progress")
+)
+
+// partialCaptureError is returned by capture when some of the profiles could not
+// be collected, the others being uploaded.
+type partialCaptureError struct{ err error }
+
+func (e *partialCaptureError) Error() string { return e.err.Error() }
+
+// capturableTypes lists the profile types supported by CaptureNow, in the order
+// they are uploaded.
+var capturableTypes = []ProfileType{
+	CPUProfile,
+	HeapProfile,
+	BlockProfile,
+	MutexProfile,
+	GoroutineProfile,
+}
+
+func isCapturable(t ProfileType) bool {
+	for _, c := range capturableTypes {
+		if c == t {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptureNow collects the profiles of the given types out of band of the periodic
+// collection, and uploads them as a batch tagged with trigger:manual. When no types
+// are given, all the enabled types supported by CaptureNow are collected, except
+// CPUProfile. It returns once the batch is uploaded, or an error if the profiler
+// isn't running, another capture is in progress or some of the profiles could not
+// be collected or uploaded. The profiles collected are uploaded even if others
+// could not be.
+//
+// The profiles cover the time until ctx is done, up to the profiling period set with
+// WithPeriod. The supported types are CPUProfile, HeapProfile, BlockProfile,
+// MutexProfile and GoroutineProfile. The CPU profile can only be captured while the
+// periodic CPU profiling is idle, see CPUDuration, which is why it must be requested
+// explicitly. Heap, block and mutex profiles are delta profiles of the capture
+// duration when delta profiling is enabled, which does not affect the periodic
+// delta profiles.
+func CaptureNow(ctx context.Context, types ...ProfileType) error {
+	mu.Lock()
+	p := activeProfiler
+	mu.Unlock()
+	if p == nil {
+		return errNotRunning
+	}
+	return p.capture(ctx, types)
+}
+
+// capture implements CaptureNow for p.
+func (p *profiler) capture(ctx context.Context, types []ProfileType) error {
+	if len(types) == 0 {
+		for _, t := range capturableTypes {
+			if t == CPUProfile {
+				// the periodic CPU profile is most likely being collected
+				continue
+			}
+			if _, ok := p.cfg.types[t]; ok {
+				types = append(types, t)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return errors.New("no profile type to capture")
+	}
+	for _, t := range types {
+		if !isCapturable(t) {
+			return fmt.Errorf("profile type %s can't be captured", t)
+		}
+	}
+	if !atomic.CompareAndSwapUint32(&p.capturing, 0, 1) {
+		return errCaptureInProgress
+	}
+	defer atomic.StoreUint32(&p.capturing, 0)
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.period)
+	defer cancel()
+	bat := batch{
+		host:    p.cfg.hostname,
+		start:   now(),
+		trigger: captureTrigger,
+	}
+	var (
+		wg    sync.WaitGroup
+		pmu   sync.Mutex // guards below fields
+		profs = make([]*profile, len(types))
+		err   error
+	)
+	for i, t := range types {
+		wg.Add(1)
+		go func(i int, t ProfileType) {
+			defer wg.Done()
+			prof, perr := p.captureProfile(ctx, t)
+			pmu.Lock()
+			defer pmu.Unlock()
+			if perr != nil {
+				log.Error("Error capturing %s profile: %v; skipping.", t, perr)
+				if err == nil {
+					err = fmt.Errorf("capturing %s profile: %v", t, perr)
+				}
+				return
+			}
+			profs[i] = prof
+		}(i, t)
+	}
+	wg.Wait()
+	bat.end = now()
+	for _, prof := range profs {
+		if prof != nil {
+			bat.addProfile(prof)
+		}
+	}
+	if len(bat.profiles) == 0 {
+		return err
+	}
+	select {
+	case <-p.exit:
+		return errNotRunning
+	default:
+	}
+	if uerr := p.export(bat); uerr != nil {
+		return fmt.Errorf("uploading profiles: %v", uerr)
+	}
+	if err != nil {
+		return &partialCaptureError{err: err}
+	}
+	return nil
+}
+
+// captureProfile collects the profile of type t until ctx is done or the profiler
+// is stopped.
+func (p *profiler) captureProfile(ctx context.Context, t ProfileType) (*profile, error) {
+	wait := func() {
+		select {
+		case <-ctx.Done():
+		case <-p.exit:
+		}
+	}
+	pt := t.lookup()
+	if t == CPUProfile {
+		var buf bytes.Buffer
+		if err := p.startCPUProfile(&buf); err != nil {
+			return nil, err
+		}
+		wait()
+		p.stopCPUProfile()
+		return &profile{name: pt.Filename, pt: t, data: buf.Bytes()}, nil
+	}
+	if !p.cfg.deltaProfiles || len(pt.DeltaValues) == 0 {
+		wait()
+		var buf bytes.Buffer
+		if err := p.lookupProfile(pt.Name, &buf, 0); err != nil {
+			return nil, err
+		}
+		return &profile{name: pt.Filename, pt: t, data: buf.Bytes()}, nil
+	}
+	// a delta profiler of its own keeps the baseline of the periodic delta
+	// profiles untouched
+	dp := newDeltaProfiler(p.cfg, pt.DeltaValues...)
+	var start bytes.Buffer
+	if err := p.lookupProfile(pt.Name, &start, 0); err != nil {
+		return nil, err
+	}
+	if _, err := dp.Delta(start.Bytes()); err != nil {
+		return nil, fmt.Errorf("delta profile error: %s", err)
+	}
+	wait()
+	var end bytes.Buffer
+	if err := p.lookupProfile(pt.Name, &end, 0); err != nil {
+		return nil, err
+	}
+	data, err := dp.Delta(end.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("delta profile error: %s", err)
+	}
+	return &profile{name: "delta-" + pt.Filename, pt: t, data: data}, nil
+}
+
+// CaptureHandler returns an http.Handler triggering a capture of profiles with
+// CaptureNow on POST requests, meant to be mounted on an administration mux. The
+// "types" query parameter holds the comma-separated names of the profile types to
+// capture, e.g. "cpu,heap", all the enabled ones but cpu by default, and the
+// "seconds" parameter the duration of the capture, 30 by default. The handler
+// responds once the profiles are uploaded, successfully when only some of them
+// could be collected, with the errors of the others in the response body.
+func CaptureHandler() http.Handler {
+	return http.HandlerFunc(serveCapture)
+}
+
+func serveCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d := defaultCaptureDuration
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		sec, err := strconv.ParseFloat(v, 64)
+		if err != nil || sec <= 0 {
+			http.Error(w, fmt.Sprintf("invalid seconds %q", v), http.StatusBadRequest)
+			return
+		}
+		d = time.Duration(sec * float64(time.Second))
+	}
+	var types []ProfileType
+	if v := r.URL.Query().Get("types"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			t, ok := captureTypeByName(strings.TrimSpace(name))
+			if !ok {
+				http.Error(w, fmt.Sprintf("invalid profile type %q", name), http.StatusBadRequest)
+				return
+			}
+			types = append(types, t)
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	defer cancel()
+	err := CaptureNow(ctx, types...)
+	var partial *partialCaptureError
+	switch {
+	case err == nil:
+		w.Write([]byte("profiles captured and uploaded\n"))
+	case errors.As(err, &partial):
+		fmt.Fprintf(w, "profiles partially captured and uploaded: %v\n", err)
+	case err == errNotRunning:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err == errCaptureInProgress:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// captureTypeByName returns the profile type supported by CaptureNow named name.
+func captureTypeByName(name string) (ProfileType, bool) {
+	for _, t := range capturableTypes {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,213 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDeltaProfiler is a deltaProfiler recording the profiles it is given.
+type recordingDeltaProfiler struct{ calls int }
+
+func (d *recordingDeltaProfiler) Delta(data []byte) ([]byte, error) {
+	d.calls++
+	return data, nil
+}
+
+func TestCaptureNow(t *testing.T) {
+	t.Run("not-running", func(t *testing.T) {
+		assert.Equal(t, errNotRunning, CaptureNow(context.Background()))
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		p, err := unstartedProfiler(WithProfileTypes(CPUProfile, HeapProfile, GoroutineProfile))
+		require.NoError(t, err)
+		var cpuStarted, cpuStopped bool
+		p.testHooks.startCPUProfile = func(w io.Writer) error {
+			cpuStarted = true
+			_, err := w.Write([]byte("cpu"))
+			return err
+		}
+		p.testHooks.stopCPUProfile = func() { cpuStopped = true }
+		heap := &recordingDeltaProfiler{}
+		p.deltas[HeapProfile] = heap
+		var got []batch
+		p.uploadFunc = func(bat batch) error {
+			got = append(got, bat)
+			return nil
+		}
+
+		capture := func(types ...ProfileType) []string {
+			t.Helper()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			got = nil
+			require.NoError(t, p.capture(ctx, types))
+			require.Len(t, got, 1)
+			bat := got[0]
+			assert.Equal(t, captureTrigger, bat.trigger)
+			assert.GreaterOrEqual(t, bat.end.Sub(bat.start), 10*time.Millisecond)
+			var names []string
+			for _, prof := range bat.profiles {
+				names = append(names, prof.name)
+				assert.NotEmpty(t, prof.data)
+			}
+			return names
+		}
+
+		// the CPU profile must be requested explicitly
+		assert.Equal(t, []string{"delta-heap.pprof", "goroutines.pprof"}, capture())
+		assert.False(t, cpuStarted)
+		assert.Equal(t, []string{"cpu.pprof", "delta-heap.pprof"}, capture(CPUProfile, HeapProfile))
+		assert.True(t, cpuStarted)
+		assert.True(t, cpuStopped)
+		// the periodic delta profiles are not affected
+		assert.Zero(t, heap.calls)
+	})
+
+	t.Run("cpu-busy", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		p.testHooks.startCPUProfile = func(_ io.Writer) error {
+			return errors.New("cpu profiling already in use")
+		}
+		p.uploadFunc = func(_ batch) error {
+			t.Fatal("nothing to upload")
+			return nil
+		}
+		err = p.capture(context.Background(), []ProfileType{CPUProfile})
+		assert.EqualError(t, err, "capturing cpu profile: cpu profiling already in use")
+	})
+
+	t.Run("cpu-periodic", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		p.testHooks.startCPUProfile = func(_ io.Writer) error {
+			t.Fatal("the periodic CPU profile is being collected")
+			return nil
+		}
+		p.uploadFunc = func(_ batch) error {
+			t.Fatal("nothing to upload")
+			return nil
+		}
+		p.cpuProfiling = 1
+		err = p.capture(context.Background(), []ProfileType{CPUProfile})
+		assert.EqualError(t, err, "capturing cpu profile: cpu profiling already in use")
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		p.testHooks.startCPUProfile = func(_ io.Writer) error {
+			return errors.New("cpu profiling already in use")
+		}
+		var got []batch
+		p.uploadFunc = func(bat batch) error {
+			got = append(got, bat)
+			return nil
+		}
+		err = p.capture(context.Background(), []ProfileType{CPUProfile, GoroutineProfile})
+		var partial *partialCaptureError
+		require.True(t, errors.As(err, &partial))
+		assert.EqualError(t, err, "capturing cpu profile: cpu profiling already in use")
+		require.Len(t, got, 1)
+		require.Len(t, got[0].profiles, 1)
+		assert.Equal(t, "goroutines.pprof", got[0].profiles[0].name)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		assert.Error(t, p.capture(context.Background(), []ProfileType{MetricsProfile}))
+	})
+
+	t.Run("in-progress", func(t *testing.T) {
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		p.capturing = 1
+		assert.Equal(t, errCaptureInProgress, p.capture(context.Background(), []ProfileType{HeapProfile}))
+	})
+
+	t.Run("period", func(t *testing.T) {
+		p, err := unstartedProfiler(WithPeriod(10 * time.Millisecond))
+		require.NoError(t, err)
+		start := time.Now()
+		require.NoError(t, p.capture(context.Background(), []ProfileType{GoroutineProfile}))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestCaptureHandler(t *testing.T) {
+	got := make(chan profileMeta, 10)
+	server := httptest.NewServer(&mockBackend{t: t, profiles: got})
+	defer server.Close()
+	Start(
+		WithAgentAddr(server.Listener.Addr().String()),
+		WithProfileTypes(HeapProfile),
+		WithPeriod(time.Minute),
+	)
+	defer Stop()
+
+	h := CaptureHandler()
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, serve("GET", "/").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/?seconds=x").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/?types=metrics").Code)
+
+	waitCapture := func(attachments ...string) {
+		t.Helper()
+		for {
+			select {
+			case prof := <-got:
+				if !contains(prof.tags, "trigger:manual") {
+					continue // periodic upload
+				}
+				assert.ElementsMatch(t, attachments, prof.event.Attachments)
+				for _, tag := range prof.tags {
+					assert.NotContains(t, tag, "profile_seq:")
+				}
+				return
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the captured profiles")
+			}
+		}
+	}
+
+	w := serve("POST", "/?types=heap,goroutine&seconds=0.01")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	waitCapture("delta-heap.pprof", "goroutines.pprof")
+
+	// the profiles collected are uploaded when the CPU profile is busy
+	require.NoError(t, pprof.StartCPUProfile(io.Discard))
+	w = serve("POST", "/?types=cpu,goroutine&seconds=0.01")
+	pprof.StopCPUProfile()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "capturing cpu profile")
+	waitCapture("goroutines.pprof")
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
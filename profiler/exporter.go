@@ -0,0 +1,270 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch is a set of profiles of different types, collected over the same
+// period, as passed to an Exporter.
+type Batch struct {
+	// Start and End delimit the profiling period.
+	Start, End time.Time
+	// Tags holds the tags of the batch, as uploaded to Datadog.
+	Tags []string
+	// Profiles holds the profiles of the batch. Their data is shared by all
+	// exporters and must not be modified.
+	Profiles []Profile
+	// EndpointCounts holds the number of hits of each endpoint during the
+	// period, if endpoint counting is enabled.
+	EndpointCounts map[string]uint64
+}
+
+// Profile is a profile of a Batch.
+type Profile struct {
+	// Name is the filename of the profile, e.g. cpu.pprof or delta-heap.pprof.
+	Name string
+	// Data holds the profile, in the pprof format for .pprof files.
+	Data []byte
+}
+
+// Exporter exports batches of profiles, in addition to or instead of uploading
+// them to Datadog. See WithExporter. Export may be called concurrently.
+type Exporter interface {
+	// Export exports the profiles of b.
+	Export(b Batch) error
+}
+
+// exportedBatch returns bat as passed to exporters and uploaded to Datadog.
+func (p *profiler) exportedBatch(bat batch) Batch {
+	b := Batch{
+		Start:          bat.start,
+		End:            bat.end,
+		Tags:           p.batchTags(bat),
+		EndpointCounts: bat.endpointCounts,
+	}
+	for _, prof := range bat.profiles {
+		b.Profiles = append(b.Profiles, Profile{Name: prof.name, Data: prof.data})
+	}
+	return b
+}
+
+// fileExporterLayout is the time layout of the names of the directories written
+// by FileExporter, which sort in chronological order.
+const fileExporterLayout = "20060102T150405.000000000Z"
+
+// FileExporter is an Exporter writing every batch of profiles to its own
+// subdirectory of a directory, along with the event.json metadata uploaded to
+// Datadog. The subdirectories are named after the end of the profiling period,
+// in UTC, e.g. 20230102T150405.000000000Z.
+type FileExporter struct {
+	dir        string
+	maxBatches int
+	maxBytes   int64
+
+	mu sync.Mutex // serializes exports
+}
+
+// NewFileExporter returns a FileExporter writing to dir, which is created if
+// needed. Once more than maxBatches batches, or batches totaling more than
+// maxBytes bytes, are stored in dir, the oldest ones are removed, always keeping
+// the most recent one. Zero or negative limits mean no limit.
+func NewFileExporter(dir string, maxBatches int, maxBytes int64) (*FileExporter, error) {
+	// 0755 is what mkdir does, should be reasonable for the use cases here.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileExporter{
+		dir:        dir,
+		maxBatches: maxBatches,
+		maxBytes:   maxBytes,
+	}, nil
+}
+
+// Export implements Exporter. The batch is written to a temporary directory
+// first, so that readers never see partially written batches.
+func (e *FileExporter) Export(b Batch) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	name := b.End.UTC().Format(fileExporterLayout)
+	tmp := filepath.Join(e.dir, "."+name+".tmp")
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	for _, prof := range b.Profiles {
+		if err := os.WriteFile(filepath.Join(tmp, prof.Name), prof.Data, 0644); err != nil {
+			return err
+		}
+	}
+	event, err := json.Marshal(newUploadEvent(b))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "event.json"), event, 0644); err != nil {
+		return err
+	}
+	dst := filepath.Join(e.dir, name)
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return e.prune()
+}
+
+// prune removes the oldest batches exceeding the retention limits of e.
+func (e *FileExporter) prune() error {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+	var (
+		dirs  []string // batch directories, oldest first
+		sizes []int64
+		total int64
+	)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(fileExporterLayout, entry.Name()); err != nil {
+			continue // not written by e
+		}
+		dir := filepath.Join(e.dir, entry.Name())
+		size, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+		sizes = append(sizes, size)
+		total += size
+	}
+	for len(dirs) > 1 {
+		if (e.maxBatches <= 0 || len(dirs) <= e.maxBatches) && (e.maxBytes <= 0 || total <= e.maxBytes) {
+			break
+		}
+		if err := os.RemoveAll(dirs[0]); err != nil {
+			return err
+		}
+		total -= sizes[0]
+		dirs, sizes = dirs[1:], sizes[1:]
+	}
+	return nil
+}
+
+// dirSize returns the total size of the files of dir.
+func dirSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// HTTPExporter is an Exporter serving the most recent profile of each type over
+// HTTP, in the format of the net/http/pprof endpoints. Profiles are served under
+// the names they are uploaded with, e.g. delta-heap.pprof, for which the
+// "delta-" prefix and the ".pprof" suffix may be omitted:
+//
+//	mux.Handle("/debug/datadog/", exporter)
+//	// go tool pprof http://localhost:6060/debug/datadog/heap
+//
+// The last element of the request path names the profile. If it is empty, the
+// names of the available profiles are listed. The zero value is ready to use.
+type HTTPExporter struct {
+	mu       sync.Mutex // guards profiles
+	profiles map[string]exportedProfile
+}
+
+// exportedProfile is a profile held by an HTTPExporter.
+type exportedProfile struct {
+	Profile
+	end time.Time // end of the profiling period
+}
+
+// NewHTTPExporter returns a new HTTPExporter.
+func NewHTTPExporter() *HTTPExporter {
+	return &HTTPExporter{profiles: make(map[string]exportedProfile)}
+}
+
+// Export implements Exporter.
+func (e *HTTPExporter) Export(b Batch) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.profiles == nil {
+		e.profiles = make(map[string]exportedProfile)
+	}
+	for _, prof := range b.Profiles {
+		e.profiles[prof.Name] = exportedProfile{Profile: prof, end: b.End}
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (e *HTTPExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if name == "" {
+		e.serveIndex(w)
+		return
+	}
+	prof, ok := e.lookup(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+prof.Name+`"`)
+	http.ServeContent(w, r, prof.Name, prof.end, bytes.NewReader(prof.Data))
+}
+
+// lookup returns the profile named name, or name with the "delta-" prefix
+// and/or the ".pprof" suffix.
+func (e *HTTPExporter) lookup(name string) (exportedProfile, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, n := range []string{name, name + ".pprof", "delta-" + name, "delta-" + name + ".pprof"} {
+		if prof, ok := e.profiles[n]; ok {
+			return prof, true
+		}
+	}
+	return exportedProfile{}, false
+}
+
+func (e *HTTPExporter) serveIndex(w http.ResponseWriter) {
+	e.mu.Lock()
+	names := make([]string, 0, len(e.profiles))
+	for name := range e.profiles {
+		names = append(names, name)
+	}
+	e.mu.Unlock()
+	sort.Strings(names)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, name := range names {
+		w.Write([]byte(name + "\n"))
+	}
+}
@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExporter is an Exporter recording the batches it exports.
+type recordingExporter struct{ batches []Batch }
+
+func (e *recordingExporter) Export(b Batch) error {
+	e.batches = append(e.batches, b)
+	return nil
+}
+
+func TestWithExporter(t *testing.T) {
+	rec := &recordingExporter{}
+	p, err := unstartedProfiler(
+		WithExporter(rec),
+		WithUpload(false),
+		WithService("my-service"),
+		WithHostname("my-host"),
+	)
+	require.NoError(t, err)
+	p.uploadFunc = func(_ batch) error {
+		t.Fatal("uploading is disabled")
+		return nil
+	}
+	bat := batch{
+		seq:   3,
+		start: time.Now(),
+		end:   time.Now(),
+		host:  p.cfg.hostname,
+		profiles: []*profile{
+			{name: "cpu.pprof", pt: CPUProfile, data: []byte("cpu")},
+		},
+	}
+	require.NoError(t, p.export(bat))
+	require.Len(t, rec.batches, 1)
+	b := rec.batches[0]
+	assert.Equal(t, []Profile{{Name: "cpu.pprof", Data: []byte("cpu")}}, b.Profiles)
+	assert.Subset(t, b.Tags, []string{"service:my-service", "profile_seq:3", "host:my-host", "runtime:go"})
+
+	var uploaded int
+	p.uploadFunc = func(_ batch) error {
+		// exporters run once the batch is uploaded
+		assert.Len(t, rec.batches, 1)
+		uploaded++
+		return errors.New("upload failed")
+	}
+	p.cfg.upload = true
+	assert.EqualError(t, p.export(bat), "upload failed")
+	assert.Equal(t, 1, uploaded)
+	assert.Len(t, rec.batches, 2)
+}
+
+func TestFileExporter(t *testing.T) {
+	testBatch := func(end time.Time, data string) Batch {
+		return Batch{
+			Start:    end.Add(-time.Minute),
+			End:      end,
+			Tags:     []string{"service:my-service"},
+			Profiles: []Profile{{Name: "delta-heap.pprof", Data: []byte(data)}},
+		}
+	}
+	batchDirs := func(t *testing.T, dir string) []string {
+		t.Helper()
+		dirs, err := filepath.Glob(filepath.Join(dir, "*T*Z"))
+		require.NoError(t, err)
+		for i, d := range dirs {
+			dirs[i] = filepath.Base(d)
+		}
+		return dirs
+	}
+	end := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("layout", func(t *testing.T) {
+		dir := t.TempDir()
+		e, err := NewFileExporter(filepath.Join(dir, "profiles"), 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, e.Export(testBatch(end, "heap")))
+
+		batch := filepath.Join(dir, "profiles", "20230102T150405.000000000Z")
+		data, err := os.ReadFile(filepath.Join(batch, "delta-heap.pprof"))
+		require.NoError(t, err)
+		assert.Equal(t, "heap", string(data))
+		data, err = os.ReadFile(filepath.Join(batch, "event.json"))
+		require.NoError(t, err)
+		var event uploadEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		assert.Equal(t, []string{"delta-heap.pprof"}, event.Attachments)
+		assert.Equal(t, "service:my-service", event.Tags)
+		assert.Equal(t, "2023-01-02T15:04:05Z", event.End)
+	})
+
+	t.Run("max-batches", func(t *testing.T) {
+		dir := t.TempDir()
+		e, err := NewFileExporter(dir, 2, 0)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, e.Export(testBatch(end.Add(time.Duration(i)*time.Minute), "heap")))
+		}
+		assert.Equal(t, []string{"20230102T150505.000000000Z", "20230102T150605.000000000Z"}, batchDirs(t, dir))
+	})
+
+	t.Run("max-bytes", func(t *testing.T) {
+		dir := t.TempDir()
+		e, err := NewFileExporter(dir, 0, 1)
+		require.NoError(t, err)
+		require.NoError(t, e.Export(testBatch(end, "heap")))
+		require.NoError(t, e.Export(testBatch(end.Add(time.Minute), "heap")))
+		// the most recent batch is kept, even if it exceeds the limit
+		assert.Equal(t, []string{"20230102T150505.000000000Z"}, batchDirs(t, dir))
+	})
+}
+
+func TestHTTPExporter(t *testing.T) {
+	e := NewHTTPExporter()
+	get := func(target string) (int, string) {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		body, err := io.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return w.Code, string(body)
+	}
+	code, body := get("/debug/datadog/")
+	assert.Equal(t, 200, code)
+	assert.Empty(t, body)
+
+	require.NoError(t, e.Export(Batch{
+		End: time.Now(),
+		Profiles: []Profile{
+			{Name: "cpu.pprof", Data: []byte("cpu-1")},
+			{Name: "delta-heap.pprof", Data: []byte("heap-1")},
+		},
+	}))
+	require.NoError(t, e.Export(Batch{
+		End:      time.Now(),
+		Profiles: []Profile{{Name: "cpu.pprof", Data: []byte("cpu-2")}},
+	}))
+
+	code, body = get("/debug/datadog/")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "cpu.pprof\ndelta-heap.pprof\n", body)
+	for target, want := range map[string]string{
+		"/debug/datadog/cpu.pprof":        "cpu-2",
+		"/debug/datadog/cpu":              "cpu-2",
+		"/debug/datadog/delta-heap.pprof": "heap-1",
+		"/debug/datadog/heap":             "heap-1",
+	} {
+		code, body = get(target)
+		assert.Equal(t, 200, code, target)
+		assert.Equal(t, want, body, target)
+	}
+	code, _ = get("/debug/datadog/mutex")
+	assert.Equal(t, 404, code)
+}
+
+func TestHTTPExporterZeroValue(t *testing.T) {
+	var e HTTPExporter
+	require.NoError(t, e.Export(Batch{Profiles: []Profile{{Name: "cpu.pprof", Data: []byte("cpu")}}}))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/debug/datadog/cpu", nil))
+	assert.Equal(t, "cpu", w.Body.String())
+}
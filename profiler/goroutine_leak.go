@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// gaugeClient is implemented by the StatsdClients supporting gauges, such as
+// the datadog-go statsd client.
+type gaugeClient interface {
+	Gauge(name string, value float64, tags []string, rate float64) error
+}
+
+// goroutineLeakDetector tracks the number of goroutines of every stack across
+// profiling periods, to find the stacks whose number of goroutines grew at
+// every one of the last window periods. Those reaching threshold goroutines are
+// suspected to leak: they are reported with a warning and a statsd gauge.
+type goroutineLeakDetector struct {
+	cfg       *config
+	window    int // number of periods over which stacks must grow
+	threshold int // number of goroutines of growing stacks suspected to leak
+	stacks    map[string]*stackHistory
+	suspected map[string]bool // stacks suspected to leak at the previous period
+	noGauge   bool            // whether the statsd client was found not to support gauges
+}
+
+// stackFrame is a frame of a goroutine stack.
+type stackFrame struct {
+	fn, file string
+	line     int64
+}
+
+// stackHistory holds the number of goroutines of a stack at the last periods.
+type stackHistory struct {
+	stack  []stackFrame
+	counts []int // at most window+1 counts, oldest first
+}
+
+// growing reports whether the number of goroutines grew at every one of the
+// last window periods.
+func (h *stackHistory) growing(window int) bool {
+	if len(h.counts) < window+1 {
+		return false
+	}
+	for i := 1; i < len(h.counts); i++ {
+		if h.counts[i] <= h.counts[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func newGoroutineLeakDetector(cfg *config) *goroutineLeakDetector {
+	window := cfg.leakWindow
+	if window < 1 {
+		window = 1
+	}
+	return &goroutineLeakDetector{
+		cfg:       cfg,
+		window:    window,
+		threshold: cfg.leakThreshold,
+		stacks:    make(map[string]*stackHistory),
+		suspected: make(map[string]bool),
+	}
+}
+
+// stackSignature identifies the goroutines with the same stack, regardless of
+// their arguments, state or wait duration.
+func stackSignature(stack []stackFrame) string {
+	var sb strings.Builder
+	for _, f := range stack {
+		fmt.Fprintf(&sb, "%s %s:%d\n", f.fn, f.file, f.line)
+	}
+	return sb.String()
+}
+
+// profile records the goroutines of the pprof goroutine profile read from r,
+// and writes the pprof profile of the growing stacks to w. The samples hold the
+// number of goroutines of the stacks and their growth over the window. Unlike
+// the debug=2 goroutine profile, the pprof one counts the goroutines by stack,
+// which keeps it cheap with as many goroutines as leaks can pile up.
+func (d *goroutineLeakDetector) profile(r io.Reader, w io.Writer, t time.Time) error {
+	goroutines, err := pprofile.Parse(r)
+	if err != nil {
+		return fmt.Errorf("parsing goroutine profile: %s", err)
+	}
+	growing := d.update(goroutines.Sample)
+
+	p := &pprofile.Profile{
+		TimeNanos:     t.UnixNano(),
+		DurationNanos: int64(d.window) * d.cfg.period.Nanoseconds(),
+		Mapping:       []*pprofile.Mapping{{ID: 1, HasFunctions: true}},
+		SampleType: []*pprofile.ValueType{
+			{Type: "goroutines", Unit: "count"},
+			{Type: "growth", Unit: "count"},
+		},
+	}
+	for _, h := range growing {
+		last := h.counts[len(h.counts)-1]
+		p.Sample = append(p.Sample, &pprofile.Sample{
+			Value:    []int64{int64(last), int64(last - h.counts[0])},
+			Location: addFrames(p, h.stack),
+		})
+	}
+	if err := p.CheckValid(); err != nil {
+		return fmt.Errorf("marshalGoroutineLeakProfile: %s", err)
+	} else if err := p.Write(w); err != nil {
+		return fmt.Errorf("marshalGoroutineLeakProfile: %s", err)
+	}
+	return nil
+}
+
+// update records the number of goroutines of every stack of the samples of a
+// goroutine profile, and returns the histories of the growing stacks, most
+// goroutines first. It reports the suspected leaks.
+func (d *goroutineLeakDetector) update(samples []*pprofile.Sample) []*stackHistory {
+	counts := make(map[string]int)
+	for _, s := range samples {
+		if len(s.Value) == 0 {
+			continue
+		}
+		stack := sampleStack(s)
+		sig := stackSignature(stack)
+		if _, ok := d.stacks[sig]; !ok {
+			d.stacks[sig] = &stackHistory{stack: stack}
+		}
+		counts[sig] += int(s.Value[0])
+	}
+	var growing []*stackHistory
+	suspected := make(map[string]bool)
+	for sig, h := range d.stacks {
+		n, ok := counts[sig]
+		if !ok {
+			// a stack without goroutines can't grow over the window
+			delete(d.stacks, sig)
+			continue
+		}
+		h.counts = append(h.counts, n)
+		if len(h.counts) > d.window+1 {
+			h.counts = h.counts[1:]
+		}
+		if !h.growing(d.window) {
+			continue
+		}
+		growing = append(growing, h)
+		if d.threshold > 0 && n >= d.threshold {
+			suspected[sig] = true
+			if !d.suspected[sig] {
+				log.Warn("Suspected goroutine leak: %d goroutines (+%d over the last %d profiling periods) in %s",
+					n, n-h.counts[0], d.window, h.describe())
+			}
+		}
+	}
+	d.suspected = suspected
+	if g, ok := d.cfg.statsd.(gaugeClient); ok {
+		g.Gauge("datadog.profiling.go.goroutine_leaks", float64(len(suspected)), d.cfg.tags.Slice(), 1)
+	} else if !d.noGauge {
+		d.noGauge = true
+		log.Warn("The statsd client doesn't support gauges, the number of suspected goroutine leaks won't be reported as the datadog.profiling.go.goroutine_leaks gauge.")
+	}
+	sort.Slice(growing, func(i, j int) bool {
+		return growing[i].counts[len(growing[i].counts)-1] > growing[j].counts[len(growing[j].counts)-1]
+	})
+	return growing
+}
+
+// sampleStack returns the frames of the stack of the goroutine profile sample s,
+// innermost first.
+func sampleStack(s *pprofile.Sample) []stackFrame {
+	var stack []stackFrame
+	for _, loc := range s.Location {
+		// the lines of a location are its inlined calls, innermost first
+		for _, l := range loc.Line {
+			f := stackFrame{line: l.Line}
+			if l.Function != nil {
+				f.fn, f.file = l.Function.Name, l.Function.Filename
+			}
+			stack = append(stack, f)
+		}
+	}
+	return stack
+}
+
+// addFrames adds a function and a location of the first mapping of p for every
+// frame of stack to p, and returns the locations.
+func addFrames(p *pprofile.Profile, stack []stackFrame) []*pprofile.Location {
+	locations := make([]*pprofile.Location, 0, len(stack))
+	for _, f := range stack {
+		function := &pprofile.Function{
+			ID:       uint64(len(p.Function) + 1),
+			Name:     f.fn,
+			Filename: f.file,
+		}
+		p.Function = append(p.Function, function)
+		location := &pprofile.Location{
+			ID:      uint64(len(p.Location) + 1),
+			Mapping: p.Mapping[0],
+			Line:    []pprofile.Line{{Function: function, Line: f.line}},
+		}
+		p.Location = append(p.Location, location)
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// describe returns a short description of the stack of h for logging.
+func (h *stackHistory) describe() string {
+	if len(h.stack) == 0 {
+		return "unknown function"
+	}
+	desc := h.stack[0].fn
+	if len(h.stack) > 1 {
+		desc += " started in " + h.stack[len(h.stack)-1].fn
+	}
+	return desc
+}
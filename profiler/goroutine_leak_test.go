@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// gaugeRecorder is a StatsdClient recording the gauges it receives.
+type gaugeRecorder struct {
+	gauges map[string]float64
+}
+
+func (g *gaugeRecorder) Count(_ string, _ int64, _ []string, _ float64) error { return nil }
+
+func (g *gaugeRecorder) Timing(_ string, _ time.Duration, _ []string, _ float64) error { return nil }
+
+func (g *gaugeRecorder) Gauge(name string, value float64, _ []string, _ float64) error {
+	g.gauges[name] = value
+	return nil
+}
+
+// goroutineProfile returns a pprof goroutine profile with the given number of
+// goroutines blocked in each function, started in main.main.
+func goroutineProfile(t *testing.T, goroutines map[string]int) []byte {
+	t.Helper()
+	p := &pprofile.Profile{
+		Mapping:    []*pprofile.Mapping{{ID: 1, HasFunctions: true}},
+		SampleType: []*pprofile.ValueType{{Type: "goroutine", Unit: "count"}},
+	}
+	for fn, n := range goroutines {
+		p.Sample = append(p.Sample, &pprofile.Sample{
+			Value: []int64{int64(n)},
+			Location: addFrames(p, []stackFrame{
+				{fn: fn, file: "/app/main.go", line: 10},
+				{fn: "main.main", file: "/app/main.go", line: 5},
+			}),
+		})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestGoroutineLeakDetector(t *testing.T) {
+	statsd := &gaugeRecorder{gauges: make(map[string]float64)}
+	p, err := unstartedProfiler(WithStatsd(statsd))
+	require.NoError(t, err)
+	p.cfg.leakWindow = 3
+	p.cfg.leakThreshold = 4
+	d := newGoroutineLeakDetector(p.cfg)
+
+	collect := func(goroutines map[string]int) *pprofile.Profile {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, d.profile(bytes.NewReader(goroutineProfile(t, goroutines)), &buf, time.Now()))
+		prof, err := pprofile.ParseData(buf.Bytes())
+		require.NoError(t, err)
+		return prof
+	}
+
+	// main.leak grows at every period, main.pool doesn't and main.burst
+	// stops growing
+	for i := 1; i <= 3; i++ {
+		prof := collect(map[string]int{"main.leak": i, "main.pool": 8, "main.burst": i})
+		assert.Empty(t, prof.Sample)
+	}
+	prof := collect(map[string]int{"main.leak": 4, "main.pool": 8, "main.burst": 3})
+	require.Len(t, prof.Sample, 1)
+	sample := prof.Sample[0]
+	assert.Equal(t, []int64{4, 3}, sample.Value)
+	assert.Equal(t, "main.leak", sample.Location[0].Line[0].Function.Name)
+	assert.Equal(t, "main.main", sample.Location[1].Line[0].Function.Name)
+	assert.Equal(t, "goroutines", prof.SampleType[0].Type)
+	assert.Equal(t, "growth", prof.SampleType[1].Type)
+	assert.Equal(t, 1., statsd.gauges["datadog.profiling.go.goroutine_leaks"])
+
+	// the growth must be sustained over the whole window
+	prof = collect(map[string]int{"main.leak": 4, "main.pool": 8})
+	assert.Empty(t, prof.Sample)
+	assert.Equal(t, 0., statsd.gauges["datadog.profiling.go.goroutine_leaks"])
+
+	// stacks without goroutines are forgotten
+	collect(map[string]int{"main.pool": 8})
+	assert.Len(t, d.stacks, 1)
+}
+
+func TestGoroutineLeakDetectorWithoutGauges(t *testing.T) {
+	p, err := unstartedProfiler(WithStatsd(&countRecorder{counts: make(map[string]int64)}))
+	require.NoError(t, err)
+	d := newGoroutineLeakDetector(p.cfg)
+	rl := &log.RecordLogger{}
+	defer log.UseLogger(rl)()
+
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, d.profile(bytes.NewReader(goroutineProfile(t, map[string]int{"main.leak": i})), io.Discard, time.Now()))
+	}
+	// the missing gauge is reported once
+	require.Len(t, rl.Logs(), 1)
+	assert.Contains(t, rl.Logs()[0], "datadog.profiling.go.goroutine_leaks")
+}
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	t.Setenv("DD_PROFILING_GOROUTINE_LEAK_PROFILE", "1")
+	t.Setenv("DD_PROFILING_GOROUTINE_LEAK_WINDOW", "1")
+	p, err := unstartedProfiler(WithPeriod(time.Millisecond))
+	require.NoError(t, err)
+	assert.Contains(t, p.enabledProfileTypes(), expGoroutineLeakProfile)
+	assert.Equal(t, 1, p.leaks.window)
+
+	n := 1
+	p.testHooks.lookupProfile = func(_ string, w io.Writer, _ int) error {
+		_, err := w.Write(goroutineProfile(t, map[string]int{"main.leak": n}))
+		return err
+	}
+	profs, err := p.runProfile(expGoroutineLeakProfile)
+	require.NoError(t, err)
+	assert.Equal(t, "goroutineleaks.pprof", profs[0].name)
+
+	n = 2
+	profs, err = p.runProfile(expGoroutineLeakProfile)
+	require.NoError(t, err)
+	prof, err := pprofile.ParseData(profs[0].data)
+	require.NoError(t, err)
+	require.Len(t, prof.Sample, 1)
+	assert.Equal(t, []int64{2, 1}, prof.Sample[0].Value)
+
+	// the goroutines are counted regardless of their number
+	p.cfg.maxGoroutinesWait = 0
+	p.testHooks.lookupProfile = nil
+	_, err = p.runProfile(expGoroutineLeakProfile)
+	require.NoError(t, err)
+}
@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime/trace"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+)
+
+// latencyTrigger is the value of the trigger tag of latency-triggered execution
+// traces.
+const latencyTrigger = "latency"
+
+// startLatencyTraces sets up the global latency trigger for the tracer to report
+// slow requests, and starts collecting execution traces of the requests
+// following them, see executionTraceConfig.
+func (p *profiler) startLatencyTraces() {
+	// slow spans reported while a trace is being collected are dropped
+	slow := make(chan traceprof.SlowSpan, 1)
+	traceprof.GlobalLatencyTrigger().Set(
+		p.cfg.traceConfig.LatencyThreshold,
+		p.cfg.traceConfig.LatencyEndpoints,
+		func(s traceprof.SlowSpan) {
+			select {
+			case slow <- s:
+			default:
+			}
+		},
+	)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.collectLatencyTraces(slow)
+	}()
+}
+
+// collectLatencyTraces collects and uploads an execution trace for the spans
+// received from slow, attempting at most once per
+// executionTraceConfig.LatencyInterval.
+func (p *profiler) collectLatencyTraces(slow <-chan traceprof.SlowSpan) {
+	var last time.Time
+	for {
+		select {
+		case <-p.exit:
+			return
+		case s := <-slow:
+			if !last.IsZero() && time.Since(last) < p.cfg.traceConfig.LatencyInterval {
+				p.cfg.statsd.Count("datadog.profiling.go.latency_trace_skipped", 1, p.cfg.tags.Slice(), 1)
+				continue
+			}
+			// failed attempts are rate limited as well
+			last = time.Now()
+			bat, err := p.latencyTrace(s)
+			if err != nil {
+				// e.g. a periodic execution trace is being collected
+				log.Debug("Skipping latency-triggered execution trace: %v", err)
+				continue
+			}
+			select {
+			case <-p.exit:
+				return
+			default:
+			}
+			if err := p.export(bat); err != nil {
+				log.Error("Failed to upload profile: %v", err)
+			}
+		}
+	}
+}
+
+// errLatencyTracing is returned by the periodic execution trace when it can't
+// start because a latency-triggered execution trace is being collected.
+var errLatencyTracing = errors.New("a latency-triggered execution trace is being collected")
+
+// latencyTrace collects an execution trace following the slow span s, and
+// returns it as a batch tagged as triggered by s. The trace covers the requests
+// following s, not s itself.
+func (p *profiler) latencyTrace(s traceprof.SlowSpan) (batch, error) {
+	bat := batch{
+		host:    p.cfg.hostname,
+		start:   now(),
+		trigger: latencyTrigger,
+		tags: []string{
+			fmt.Sprintf("triggered_by_trace_id:%d", s.TraceID),
+			fmt.Sprintf("triggered_by_span_id:%d", s.SpanID),
+		},
+	}
+	buf := new(bytes.Buffer)
+	lt := newLimitedTraceCollector(buf, int64(p.cfg.traceConfig.Limit))
+	// set before starting, for the periodic execution trace failing to start
+	// to know why
+	atomic.StoreUint32(&p.latencyTracing, 1)
+	defer atomic.StoreUint32(&p.latencyTracing, 0)
+	if err := trace.Start(lt); err != nil {
+		return batch{}, err
+	}
+	select {
+	case <-p.exit: // Profiling was stopped
+	case <-time.After(p.cfg.traceConfig.LatencyDuration): // The tracing window has ended
+	case <-lt.done: // The trace size limit was exceeded
+	}
+	trace.Stop()
+	bat.end = now()
+	bat.addProfile(&profile{
+		name: executionTrace.Filename(),
+		pt:   executionTrace,
+		data: buf.Bytes(),
+	})
+	return bat, nil
+}
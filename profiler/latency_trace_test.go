@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2023 Datadog, Inc.
+
+package profiler
+
+import (
+	"io"
+	"runtime/trace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+)
+
+func TestLatencyTraceConfig(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Zero(t, cfg.traceConfig.LatencyThreshold)
+		assert.Equal(t, 5*time.Second, cfg.traceConfig.LatencyDuration)
+		assert.Equal(t, time.Minute, cfg.traceConfig.LatencyInterval)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_THRESHOLD", "500ms")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_ENDPOINTS", "GET /foo, POST /bar")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_DURATION", "2s")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_INTERVAL", "10m")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Equal(t, 500*time.Millisecond, cfg.traceConfig.LatencyThreshold)
+		assert.Equal(t, []string{"GET /foo", "POST /bar"}, cfg.traceConfig.LatencyEndpoints)
+		assert.Equal(t, 2*time.Second, cfg.traceConfig.LatencyDuration)
+		assert.Equal(t, 10*time.Minute, cfg.traceConfig.LatencyInterval)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_THRESHOLD", "500ms")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_DURATION", "0s")
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		assert.Zero(t, cfg.traceConfig.LatencyThreshold)
+	})
+}
+
+func TestLatencyTraces(t *testing.T) {
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_THRESHOLD", "100ms")
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_ENDPOINTS", "GET /foo")
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_DURATION", "10ms")
+	p, err := unstartedProfiler(WithProfileTypes())
+	require.NoError(t, err)
+	uploaded := make(chan batch, 2)
+	p.uploadFunc = func(bat batch) error {
+		uploaded <- bat
+		return nil
+	}
+	p.startLatencyTraces()
+	defer p.stop()
+
+	lt := traceprof.GlobalLatencyTrigger()
+	require.True(t, lt.Enabled())
+	lt.Observe(traceprof.SlowSpan{TraceID: 1, SpanID: 1, Endpoint: "GET /foo", Duration: 10 * time.Millisecond})
+	lt.Observe(traceprof.SlowSpan{TraceID: 2, SpanID: 2, Endpoint: "GET /bar", Duration: time.Second})
+	lt.Observe(traceprof.SlowSpan{TraceID: 3, SpanID: 4, Endpoint: "GET /foo", Duration: time.Second})
+
+	select {
+	case bat := <-uploaded:
+		assert.Equal(t, latencyTrigger, bat.trigger)
+		assert.Equal(t, []string{"triggered_by_trace_id:3", "triggered_by_span_id:4"}, bat.tags)
+		require.Len(t, bat.profiles, 1)
+		assert.Equal(t, "go.trace", bat.profiles[0].name)
+		assert.NotEmpty(t, bat.profiles[0].data)
+		assert.Subset(t, p.batchTags(bat), []string{"trigger:latency", "triggered_by_trace_id:3", "go_execution_traced:yes"})
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the execution trace")
+	}
+
+	// traces are rate limited
+	lt.Observe(traceprof.SlowSpan{TraceID: 5, SpanID: 5, Endpoint: "GET /foo", Duration: time.Second})
+	select {
+	case bat := <-uploaded:
+		t.Fatalf("unexpected trace: %v", bat.tags)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.stop()
+	assert.False(t, lt.Enabled())
+}
+
+func TestPeriodicTraceDuringLatencyTrace(t *testing.T) {
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_ENABLED", "true")
+	p, err := unstartedProfiler(WithProfileTypes())
+	require.NoError(t, err)
+	require.True(t, p.shouldTrace())
+
+	// e.g. a latency-triggered execution trace is being collected
+	require.NoError(t, trace.Start(io.Discard))
+	defer trace.Stop()
+	p.latencyTracing = 1
+	_, err = executionTrace.lookup().Collect(p)
+	assert.Equal(t, errLatencyTracing, err)
+	// the periodic trace is attempted again the next profiling cycle
+	assert.True(t, p.shouldTrace())
+}
+
+// countRecorder is a StatsdClient recording the counts it receives.
+type countRecorder struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *countRecorder) Count(name string, value int64, _ []string, _ float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name] += value
+	return nil
+}
+
+func (c *countRecorder) Timing(_ string, _ time.Duration, _ []string, _ float64) error { return nil }
+
+func TestLatencyTracesFailedAttempt(t *testing.T) {
+	statsd := &countRecorder{counts: make(map[string]int64)}
+	p, err := unstartedProfiler(WithProfileTypes(), WithStatsd(statsd))
+	require.NoError(t, err)
+	p.uploadFunc = func(_ batch) error {
+		t.Fatal("nothing to upload")
+		return nil
+	}
+
+	// e.g. a periodic execution trace is being collected
+	require.NoError(t, trace.Start(io.Discard))
+	defer trace.Stop()
+	slow := make(chan traceprof.SlowSpan)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.collectLatencyTraces(slow)
+	}()
+	slow <- traceprof.SlowSpan{TraceID: 1, SpanID: 1, Duration: time.Second}
+	// the failed attempt is rate limited like a successful one
+	slow <- traceprof.SlowSpan{TraceID: 2, SpanID: 2, Duration: time.Second}
+	p.stop()
+	assert.Equal(t, int64(1), statsd.counts["datadog.profiling.go.latency_trace_skipped"])
+}
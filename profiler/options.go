@@ -15,6 +15,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/pprof"
 	"strconv"
 	"strings"
 	"time"
@@ -97,14 +98,19 @@ type config struct {
 	httpClient           *http.Client
 	tags                 immutable.StringSlice
 	types                map[ProfileType]struct{}
+	customProfiles       []customProfile
 	period               time.Duration
 	cpuDuration          time.Duration
 	cpuProfileRate       int
 	uploadTimeout        time.Duration
 	maxGoroutinesWait    int
+	leakWindow           int
+	leakThreshold        int
 	mutexFraction        int
 	blockRate            int
 	outputDir            string
+	exporters            []Exporter
+	upload               bool
 	deltaProfiles        bool
 	deltaMethod          string
 	logStartup           bool
@@ -132,6 +138,7 @@ func logStartup(c *config) {
 		Tags                 []string `json:"tags"`
 		ProfilePeriod        string   `json:"profile_period"`
 		EnabledProfiles      []string `json:"enabled_profiles"`
+		CustomProfiles       []string `json:"custom_profiles"`
 		CPUDuration          string   `json:"cpu_duration"`
 		CPUProfileRate       int      `json:"cpu_profile_rate"`
 		BlockProfileRate     int      `json:"block_profile_rate"`
@@ -141,7 +148,10 @@ func logStartup(c *config) {
 		TraceEnabled         bool     `json:"execution_trace_enabled"`
 		TracePeriod          string   `json:"execution_trace_period"`
 		TraceSizeLimit       int      `json:"execution_trace_size_limit"`
+		TraceLatency         string   `json:"execution_trace_latency_threshold"`
 		EndpointCountEnabled bool     `json:"endpoint_count_enabled"`
+		UploadEnabled        bool     `json:"upload_enabled"`
+		Exporters            int      `json:"exporters"`
 	}{
 		Date:                 time.Now().Format(time.RFC3339),
 		OSName:               osinfo.OSName(),
@@ -167,11 +177,17 @@ func logStartup(c *config) {
 		TraceEnabled:         c.traceEnabled,
 		TracePeriod:          c.traceConfig.Period.String(),
 		TraceSizeLimit:       c.traceConfig.Limit,
+		TraceLatency:         c.traceConfig.LatencyThreshold.String(),
 		EndpointCountEnabled: c.endpointCountEnabled,
+		UploadEnabled:        c.upload,
+		Exporters:            len(c.exporters),
 	}
 	for t := range c.types {
 		info.EnabledProfiles = append(info.EnabledProfiles, t.String())
 	}
+	for _, cp := range c.customProfiles {
+		info.CustomProfiles = append(info.CustomProfiles, cp.name)
+	}
 	b, err := json.Marshal(info)
 	if err != nil {
 		log.Error("Marshaling profiler configuration: %s", err)
@@ -218,10 +234,13 @@ func defaultConfig() (*config, error) {
 		mutexFraction:        DefaultMutexFraction,
 		uploadTimeout:        DefaultUploadTimeout,
 		maxGoroutinesWait:    1000, // arbitrary value, should limit STW to ~30ms
+		leakWindow:           internal.IntEnv("DD_PROFILING_GOROUTINE_LEAK_WINDOW", 5),
+		leakThreshold:        internal.IntEnv("DD_PROFILING_GOROUTINE_LEAK_THRESHOLD", 100),
 		deltaProfiles:        internal.BoolEnv("DD_PROFILING_DELTA", true),
 		deltaMethod:          os.Getenv("DD_PROFILING_DELTA_METHOD"),
 		logStartup:           internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
 		endpointCountEnabled: internal.BoolEnv(traceprof.EndpointCountEnvVar, false),
+		upload:               true,
 	}
 	c.tags = c.tags.Append(fmt.Sprintf("process_id:%d", os.Getpid()))
 	for _, t := range defaultProfileTypes {
@@ -317,6 +336,20 @@ func defaultConfig() (*config, error) {
 		log.Warn("Invalid execution trace config, enabled is true but size limit or frequency is 0. Disabling execution trace.")
 		c.traceEnabled = false
 	}
+	c.traceConfig.LatencyThreshold = internal.DurationEnv("DD_PROFILING_EXECUTION_TRACE_LATENCY_THRESHOLD", 0)
+	if v := os.Getenv("DD_PROFILING_EXECUTION_TRACE_LATENCY_ENDPOINTS"); v != "" {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				c.traceConfig.LatencyEndpoints = append(c.traceConfig.LatencyEndpoints, e)
+			}
+		}
+	}
+	c.traceConfig.LatencyDuration = internal.DurationEnv("DD_PROFILING_EXECUTION_TRACE_LATENCY_DURATION", 5*time.Second)
+	c.traceConfig.LatencyInterval = internal.DurationEnv("DD_PROFILING_EXECUTION_TRACE_LATENCY_INTERVAL", time.Minute)
+	if c.traceConfig.LatencyThreshold > 0 && (c.traceConfig.LatencyDuration <= 0 || c.traceConfig.Limit == 0) {
+		log.Warn("Invalid latency-triggered execution trace config, threshold is set but duration or size limit is 0. Disabling latency-triggered execution traces.")
+		c.traceConfig.LatencyThreshold = 0
+	}
 	return &c, nil
 }
 
@@ -436,6 +469,23 @@ func WithProfileTypes(types ...ProfileType) Option {
 	}
 }
 
+// WithCustomProfile registers the runtime/pprof profile prof, e.g. created with
+// pprof.NewProfile, to be collected at the end of every profiling period and
+// uploaded alongside the other profiles as name.pprof. If delta is true and delta
+// profiling is enabled, the uploaded profiles hold the differences of the sample
+// values since the previous period, as for the heap, block and mutex profiles,
+// and are uploaded as delta-name.pprof. Names must be unique and distinct from the
+// names of the other profile types, otherwise starting the profiler fails.
+func WithCustomProfile(name string, prof *pprof.Profile, delta bool) Option {
+	return func(cfg *config) {
+		cfg.customProfiles = append(cfg.customProfiles, customProfile{
+			name:    name,
+			profile: prof,
+			delta:   delta,
+		})
+	}
+}
+
 // WithService specifies the service name to attach to a profile.
 func WithService(name string) Option {
 	return func(cfg *config) {
@@ -514,6 +564,24 @@ func WithUDS(socketPath string) Option {
 	})
 }
 
+// WithExporter adds an Exporter to which every batch of profiles is passed, in
+// addition to being uploaded to Datadog. Exporters are called in the order they
+// were added, once the batch is uploaded. See also WithUpload.
+func WithExporter(e Exporter) Option {
+	return func(cfg *config) {
+		cfg.exporters = append(cfg.exporters, e)
+	}
+}
+
+// WithUpload toggles uploading profiles to Datadog. Disabling uploads is useful
+// in environments without access to the Datadog agent, along with WithExporter.
+// This option is enabled by default.
+func WithUpload(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.upload = enabled
+	}
+}
+
 // withOutputDir writes a copy of all uploaded profiles to the given
 // directory. This is intended for local development or debugging uploading
 // issues. The directory will keep growing, no cleanup is performed.
@@ -555,4 +623,17 @@ type executionTraceConfig struct {
 	// of events recorded) than duration, so we use that to decide when to
 	// stop tracing.
 	Limit int
+
+	// LatencyThreshold enables latency-triggered execution traces when
+	// positive: the local root spans of LatencyEndpoints, or of all
+	// endpoints if empty, lasting longer than LatencyThreshold trigger
+	// an execution trace of LatencyDuration, which is uploaded tagged
+	// with the IDs of the span as triggered_by_trace_id and
+	// triggered_by_span_id. At most one such trace is collected per
+	// LatencyInterval. It works independently of periodic tracing, which
+	// is skipped for the profiling cycles it would overlap with.
+	LatencyThreshold time.Duration
+	LatencyEndpoints []string
+	LatencyDuration  time.Duration
+	LatencyInterval  time.Duration
 }
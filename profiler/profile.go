@@ -12,7 +12,10 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"runtime/pprof"
 	"runtime/trace"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/DataDog/gostackparse"
@@ -56,6 +59,16 @@ const (
 	// This is private, as this trace requires special explicit configuration and
 	// shouldn't just be added to WithProfileTypes
 	executionTrace
+
+	// customProfileType is the type of the profiles registered with
+	// WithCustomProfile, see customProfile.
+	customProfileType
+
+	// expGoroutineLeakProfile reports the stacks whose number of goroutines
+	// kept growing over the last profiling periods, see goroutineLeakDetector.
+	// This feature is currently experimental and only available by setting the
+	// DD_PROFILING_GOROUTINE_LEAK_PROFILE env variable.
+	expGoroutineLeakProfile
 )
 
 // profileType holds the implementation details of a ProfileType.
@@ -93,14 +106,6 @@ var profileTypes = map[ProfileType]profileType{
 			// period so that we're sure to capture the CPU usage of
 			// this library, which mostly happens at the end
 			p.interruptibleSleep(p.cfg.period - p.cfg.cpuDuration)
-			if p.cfg.cpuProfileRate != 0 {
-				// The profile has to be set each time before
-				// profiling is started. Otherwise,
-				// runtime/pprof.StartCPUProfile will set the
-				// rate itself.
-				runtime.SetCPUProfileRate(p.cfg.cpuProfileRate)
-			}
-
 			if err := p.startCPUProfile(&buf); err != nil {
 				return nil, err
 			}
@@ -173,6 +178,26 @@ var profileTypes = map[ProfileType]profileType{
 			return pprof.Bytes(), err
 		},
 	},
+	expGoroutineLeakProfile: {
+		Name:     "goroutineleak",
+		Filename: "goroutineleaks.pprof",
+		Collect: func(p *profiler) ([]byte, error) {
+			// unlike goroutinewait, not limited by the number of goroutines,
+			// as the goroutines are counted by stack, see goroutineLeakDetector
+			p.interruptibleSleep(p.cfg.period)
+
+			var (
+				now        = now()
+				goroutines = &bytes.Buffer{}
+				pprof      = &bytes.Buffer{}
+			)
+			if err := p.lookupProfile("goroutine", goroutines, 0); err != nil {
+				return nil, err
+			}
+			err := p.leaks.profile(goroutines, pprof, now)
+			return pprof.Bytes(), err
+		},
+	},
 	MetricsProfile: {
 		Name:     "metrics",
 		Filename: "metrics.json",
@@ -190,10 +215,16 @@ var profileTypes = map[ProfileType]profileType{
 			if !p.shouldTrace() {
 				return nil, errors.New("started tracing erroneously, indicating a bug in the profiler")
 			}
+			last := p.lastTrace
 			p.lastTrace = time.Now()
 			buf := new(bytes.Buffer)
 			lt := newLimitedTraceCollector(buf, int64(p.cfg.traceConfig.Limit))
 			if err := trace.Start(lt); err != nil {
+				if atomic.LoadUint32(&p.latencyTracing) == 1 {
+					// try again next profiling cycle
+					p.lastTrace = last
+					return nil, errLatencyTracing
+				}
 				return nil, err
 			}
 			select {
@@ -312,7 +343,9 @@ type profile struct {
 // batch is a collection of profiles of different types, collected at roughly the same time. It maps
 // to what the Datadog UI calls a profile.
 type batch struct {
-	seq            uint64 // seq is the value of the profile_seq tag
+	seq            uint64   // seq is the value of the profile_seq tag
+	trigger        string   // trigger is the value of the trigger tag of batches collected out of band
+	tags           []string // tags holds tags specific to the batch
 	start, end     time.Time
 	host           string
 	profiles       []*profile
@@ -323,6 +356,74 @@ func (b *batch) addProfile(p *profile) {
 	b.profiles = append(b.profiles, p)
 }
 
+// customProfile is a runtime/pprof profile registered with WithCustomProfile.
+type customProfile struct {
+	name    string
+	profile *pprof.Profile
+	delta   bool // delta reports whether delta profiles are computed, if enabled
+}
+
+// filename returns the filename used for uploading c, without the "delta-"
+// prefix of delta profiles.
+func (c customProfile) filename() string {
+	return c.name + ".pprof"
+}
+
+// valueType returns the type of the values of c's samples, which are counts of
+// the values held by c.profile.
+func (c customProfile) valueType() pprofutils.ValueType {
+	return pprofutils.ValueType{Type: c.profile.Name(), Unit: "count"}
+}
+
+// validateCustomProfiles returns an error if any of the custom profiles is
+// invalid or conflicts with another profile.
+func validateCustomProfiles(custom []customProfile) error {
+	seen := make(map[string]bool)
+	for _, t := range profileTypes {
+		seen[t.Name] = true
+		seen[strings.TrimSuffix(t.Filename, ".pprof")] = true
+	}
+	for _, c := range custom {
+		if c.name == "" || strings.ContainsAny(c.name, `/\,`) {
+			return fmt.Errorf("invalid custom profile name: %q", c.name)
+		}
+		if c.profile == nil {
+			return fmt.Errorf("custom profile %s: nil profile", c.name)
+		}
+		if seen[c.name] {
+			return fmt.Errorf("custom profile %s: name already in use", c.name)
+		}
+		seen[c.name] = true
+	}
+	return nil
+}
+
+// runCustomProfile collects the custom profile c at the end of the profiling
+// period.
+func (p *profiler) runCustomProfile(c customProfile) (*profile, error) {
+	start := now()
+	p.interruptibleSleep(p.cfg.period)
+
+	var buf bytes.Buffer
+	if err := c.profile.WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	prof := &profile{name: c.filename(), pt: customProfileType, data: buf.Bytes()}
+	tags := append(p.cfg.tags.Slice(), fmt.Sprintf("profile_type:%s", c.name))
+	if dp, ok := p.customDeltas[c.name]; ok {
+		deltaStart := time.Now()
+		delta, err := dp.Delta(prof.data)
+		p.cfg.statsd.Timing("datadog.profiling.go.delta_time", time.Since(deltaStart), tags, 1)
+		if err != nil {
+			return nil, fmt.Errorf("delta profile error: %s", err)
+		}
+		prof.name = "delta-" + prof.name
+		prof.data = delta
+	}
+	p.cfg.statsd.Timing("datadog.profiling.go.collect_time", now().Sub(start), tags, 1)
+	return prof, nil
+}
+
 func (p *profiler) runProfile(pt ProfileType) ([]*profile, error) {
 	start := now()
 	t := pt.lookup()
@@ -595,9 +696,6 @@ func goroutineDebug2ToPprof(r io.Reader, w io.Writer, t time.Time) (err error) {
 
 	goroutines, errs := gostackparse.Parse(r)
 
-	functionID := uint64(1)
-	locationID := uint64(1)
-
 	p := &pprofile.Profile{
 		TimeNanos: t.UnixNano(),
 	}
@@ -619,48 +717,8 @@ func goroutineDebug2ToPprof(r io.Reader, w io.Writer, t time.Time) (err error) {
 			},
 			NumUnit:  map[string][]string{"goid": {"id"}},
 			NumLabel: map[string][]int64{"goid": {int64(g.ID)}},
+			Location: addStack(p, goroutineStack(g)),
 		}
-
-		// Treat the frame that created this goroutine as part of the stack so it
-		// shows up in the stack trace / flame graph. Hopefully this will be more
-		// useful than confusing for people.
-		if g.CreatedBy != nil {
-			// TODO(fg) should we modify the function name to include "created by"?
-			g.Stack = append(g.Stack, g.CreatedBy)
-		}
-
-		// Based on internal discussion, the current strategy is to use virtual
-		// frames to indicate truncated stacks, see [1] for how python/jd does it.
-		// [1] https://github.com/DataDog/dd-trace-py/blob/e933d2485b9019a7afad7127f7c0eb541341cdb7/ddtrace/profiling/exporter/pprof.pyx#L117-L121
-		if g.FramesElided {
-			g.Stack = append(g.Stack, &gostackparse.Frame{
-				Func: "...additional frames elided...",
-			})
-		}
-
-		for _, call := range g.Stack {
-			function := &pprofile.Function{
-				ID:       functionID,
-				Name:     call.Func,
-				Filename: call.File,
-			}
-			p.Function = append(p.Function, function)
-			functionID++
-
-			location := &pprofile.Location{
-				ID:      locationID,
-				Mapping: m,
-				Line: []pprofile.Line{{
-					Function: function,
-					Line:     int64(call.Line),
-				}},
-			}
-			p.Location = append(p.Location, location)
-			locationID++
-
-			sample.Location = append(sample.Location, location)
-		}
-
 		p.Sample = append(p.Sample, sample)
 	}
 
@@ -679,6 +737,54 @@ func goroutineDebug2ToPprof(r io.Reader, w io.Writer, t time.Time) (err error) {
 	return nil
 }
 
+// goroutineStack returns the stack of g as reported in profiles.
+func goroutineStack(g *gostackparse.Goroutine) []*gostackparse.Frame {
+	stack := g.Stack
+	// Treat the frame that created this goroutine as part of the stack so it
+	// shows up in the stack trace / flame graph. Hopefully this will be more
+	// useful than confusing for people.
+	if g.CreatedBy != nil {
+		// TODO(fg) should we modify the function name to include "created by"?
+		stack = append(stack, g.CreatedBy)
+	}
+
+	// Based on internal discussion, the current strategy is to use virtual
+	// frames to indicate truncated stacks, see [1] for how python/jd does it.
+	// [1] https://github.com/DataDog/dd-trace-py/blob/e933d2485b9019a7afad7127f7c0eb541341cdb7/ddtrace/profiling/exporter/pprof.pyx#L117-L121
+	if g.FramesElided {
+		stack = append(stack, &gostackparse.Frame{
+			Func: "...additional frames elided...",
+		})
+	}
+	return stack
+}
+
+// addStack adds a function and a location of the first mapping of p for every
+// frame of stack to p, and returns the locations.
+func addStack(p *pprofile.Profile, stack []*gostackparse.Frame) []*pprofile.Location {
+	locations := make([]*pprofile.Location, 0, len(stack))
+	for _, call := range stack {
+		function := &pprofile.Function{
+			ID:       uint64(len(p.Function) + 1),
+			Name:     call.Func,
+			Filename: call.File,
+		}
+		p.Function = append(p.Function, function)
+
+		location := &pprofile.Location{
+			ID:      uint64(len(p.Location) + 1),
+			Mapping: p.Mapping[0],
+			Line: []pprofile.Line{{
+				Function: function,
+				Line:     int64(call.Line),
+			}},
+		}
+		p.Location = append(p.Location, location)
+		locations = append(locations, location)
+	}
+	return locations
+}
+
 // now returns current time in UTC.
 func now() time.Time {
 	return time.Now().UTC()
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"runtime/pprof"
 	"strconv"
 	"strings"
 	"testing"
@@ -336,6 +337,89 @@ main.main()
 	})
 }
 
+// testCustomProfile is the custom profile used by tests. runtime/pprof profiles
+// can't be unregistered, so it is shared by all tests.
+var testCustomProfile = pprof.NewProfile("dd-trace-go.test/connections")
+
+func TestRunCustomProfile(t *testing.T) {
+	sampleCount := func(t *testing.T, data []byte) int64 {
+		t.Helper()
+		pp, err := pprofile.ParseData(data)
+		require.NoError(t, err)
+		var n int64
+		for _, s := range pp.Sample {
+			n += s.Value[0]
+		}
+		return n
+	}
+	conns := []*int{new(int), new(int), new(int)}
+	testCustomProfile.Add(conns[0], 0)
+	testCustomProfile.Add(conns[1], 0)
+	defer func() {
+		for _, c := range conns {
+			testCustomProfile.Remove(c)
+		}
+	}()
+
+	t.Run("snapshot", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithPeriod(time.Millisecond),
+			WithCustomProfile("connections", testCustomProfile, false),
+		)
+		require.NoError(t, err)
+		prof, err := p.runCustomProfile(p.cfg.customProfiles[0])
+		require.NoError(t, err)
+		assert.Equal(t, "connections.pprof", prof.name)
+		assert.Equal(t, int64(2), sampleCount(t, prof.data))
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithPeriod(time.Millisecond),
+			WithCustomProfile("connections", testCustomProfile, true),
+		)
+		require.NoError(t, err)
+		prof, err := p.runCustomProfile(p.cfg.customProfiles[0])
+		require.NoError(t, err)
+		assert.Equal(t, "delta-connections.pprof", prof.name)
+		assert.Equal(t, int64(2), sampleCount(t, prof.data))
+
+		testCustomProfile.Add(conns[2], 0)
+		prof, err = p.runCustomProfile(p.cfg.customProfiles[0])
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), sampleCount(t, prof.data))
+	})
+
+	t.Run("delta-disabled", func(t *testing.T) {
+		p, err := unstartedProfiler(
+			WithPeriod(time.Millisecond),
+			WithDeltaProfiles(false),
+			WithCustomProfile("connections", testCustomProfile, true),
+		)
+		require.NoError(t, err)
+		prof, err := p.runCustomProfile(p.cfg.customProfiles[0])
+		require.NoError(t, err)
+		assert.Equal(t, "connections.pprof", prof.name)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		for _, opts := range [][]Option{
+			{WithCustomProfile("", testCustomProfile, false)},
+			{WithCustomProfile("a/b", testCustomProfile, false)},
+			{WithCustomProfile("connections", nil, false)},
+			{WithCustomProfile("heap", testCustomProfile, false)},
+			{WithCustomProfile("goroutines", testCustomProfile, false)},
+			{
+				WithCustomProfile("connections", testCustomProfile, false),
+				WithCustomProfile("connections", testCustomProfile, true),
+			},
+		} {
+			_, err := unstartedProfiler(opts...)
+			assert.Error(t, err)
+		}
+	})
+}
+
 func Test_goroutineDebug2ToPprof_CrashSafety(t *testing.T) {
 	err := goroutineDebug2ToPprof(panicReader{}, io.Discard, time.Time{})
 	require.NotNil(t, err)
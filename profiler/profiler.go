@@ -14,6 +14,7 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
@@ -73,8 +74,13 @@ type profiler struct {
 	wg              sync.WaitGroup    // wg waits for all goroutines to exit when stopping.
 	met             *metrics          // metric collector state
 	deltas          map[ProfileType]deltaProfiler
+	customDeltas    map[string]deltaProfiler
+	leaks           *goroutineLeakDetector
 	seq             uint64         // seq is the value of the profile_seq tag
 	pendingProfiles sync.WaitGroup // signal that profile collection is done, for stopping CPU profiling
+	capturing       uint32         // capturing is set while CaptureNow collects profiles (atomic)
+	cpuProfiling    uint32         // cpuProfiling is set while a CPU profile is collected, periodically or by CaptureNow (atomic)
+	latencyTracing  uint32         // latencyTracing is set while a latency-triggered execution trace is collected (atomic)
 
 	testHooks testHooks
 
@@ -94,14 +100,37 @@ type testHooks struct {
 	lookupProfile   func(name string, w io.Writer, debug int) error
 }
 
-func (p *profiler) startCPUProfile(w io.Writer) error {
+// errCPUProfiling is returned by startCPUProfile when the profiler is already
+// collecting a CPU profile.
+var errCPUProfiling = errors.New("cpu profiling already in use")
+
+// startCPUProfile starts collecting a CPU profile written to w, unless the
+// profiler is already collecting one, in which case the CPU profile rate must
+// not be changed either.
+func (p *profiler) startCPUProfile(w io.Writer) (err error) {
+	if !atomic.CompareAndSwapUint32(&p.cpuProfiling, 0, 1) {
+		return errCPUProfiling
+	}
+	defer func() {
+		if err != nil {
+			atomic.StoreUint32(&p.cpuProfiling, 0)
+		}
+	}()
 	if p.testHooks.startCPUProfile != nil {
 		return p.testHooks.startCPUProfile(w)
 	}
+	if p.cfg.cpuProfileRate != 0 {
+		// The profile has to be set each time before
+		// profiling is started. Otherwise,
+		// runtime/pprof.StartCPUProfile will set the
+		// rate itself.
+		runtime.SetCPUProfileRate(p.cfg.cpuProfileRate)
+	}
 	return pprof.StartCPUProfile(w)
 }
 
 func (p *profiler) stopCPUProfile() {
+	defer atomic.StoreUint32(&p.cpuProfiling, 0)
 	if p.testHooks.startCPUProfile != nil {
 		p.testHooks.stopCPUProfile()
 		return
@@ -133,6 +162,9 @@ func newProfiler(opts ...Option) (*profiler, error) {
 	if os.Getenv("DD_PROFILING_WAIT_PROFILE") != "" {
 		cfg.addProfileType(expGoroutineWaitProfile)
 	}
+	if os.Getenv("DD_PROFILING_GOROUTINE_LEAK_PROFILE") != "" {
+		cfg.addProfileType(expGoroutineLeakProfile)
+	}
 	// Agentless upload is disabled by default as of v1.30.0, but
 	// WithAgentlessUpload can be used to enable it for testing and debugging.
 	if cfg.agentless {
@@ -180,6 +212,9 @@ func newProfiler(opts ...Option) (*profiler, error) {
 			return nil, fmt.Errorf("unknown profile type: %d", pt)
 		}
 	}
+	if err := validateCustomProfiles(cfg.customProfiles); err != nil {
+		return nil, err
+	}
 	if cfg.cpuDuration > cfg.period {
 		cfg.cpuDuration = cfg.period
 	}
@@ -188,17 +223,26 @@ func newProfiler(opts ...Option) (*profiler, error) {
 	}
 
 	p := profiler{
-		cfg:    cfg,
-		out:    make(chan batch, outChannelSize),
-		exit:   make(chan struct{}),
-		met:    newMetrics(),
-		deltas: make(map[ProfileType]deltaProfiler),
+		cfg:          cfg,
+		out:          make(chan batch, outChannelSize),
+		exit:         make(chan struct{}),
+		met:          newMetrics(),
+		deltas:       make(map[ProfileType]deltaProfiler),
+		customDeltas: make(map[string]deltaProfiler),
 	}
+	p.leaks = newGoroutineLeakDetector(cfg)
 	for pt := range cfg.types {
 		if d := profileTypes[pt].DeltaValues; len(d) > 0 {
 			p.deltas[pt] = newDeltaProfiler(p.cfg, d...)
 		}
 	}
+	if cfg.deltaProfiles {
+		for _, c := range cfg.customProfiles {
+			if c.delta {
+				p.customDeltas[c.name] = newDeltaProfiler(p.cfg, c.valueType())
+			}
+		}
+	}
 	p.uploadFunc = p.upload
 	return &p, nil
 }
@@ -216,6 +260,9 @@ func (p *profiler) run() {
 		runtime.SetBlockProfileRate(p.cfg.blockRate)
 	}
 	startTelemetry(p.cfg)
+	if p.cfg.traceConfig.LatencyThreshold > 0 {
+		p.startLatencyTraces()
+	}
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
@@ -277,6 +324,7 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 				p.pendingProfiles.Add(1)
 			}
 		}
+		p.pendingProfiles.Add(len(p.cfg.customProfiles))
 		for _, t := range profileTypes {
 			wg.Add(1)
 			go func(t ProfileType) {
@@ -285,7 +333,9 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 					defer p.pendingProfiles.Done()
 				}
 				profs, err := p.runProfile(t)
-				if err != nil {
+				if err == errLatencyTracing {
+					log.Debug("Skipping %s profile: %v.", t, err)
+				} else if err != nil {
 					log.Error("Error getting %s profile: %v; skipping.", t, err)
 					tags := append(p.cfg.tags.Slice(), t.Tag())
 					p.cfg.statsd.Count("datadog.profiling.go.collect_error", 1, tags, 1)
@@ -295,6 +345,23 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 				completed = append(completed, profs...)
 			}(t)
 		}
+		for _, c := range p.cfg.customProfiles {
+			wg.Add(1)
+			go func(c customProfile) {
+				defer wg.Done()
+				defer p.pendingProfiles.Done()
+				prof, err := p.runCustomProfile(c)
+				if err != nil {
+					log.Error("Error getting %s custom profile: %v; skipping.", c.name, err)
+					tags := append(p.cfg.tags.Slice(), fmt.Sprintf("profile_type:%s", c.name))
+					p.cfg.statsd.Count("datadog.profiling.go.collect_error", 1, tags, 1)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				completed = append(completed, prof)
+			}(c)
+		}
 		wg.Wait()
 		for _, prof := range completed {
 			bat.addProfile(prof)
@@ -343,6 +410,7 @@ func (p *profiler) enabledProfileTypes() []ProfileType {
 		MutexProfile,
 		GoroutineProfile,
 		expGoroutineWaitProfile,
+		expGoroutineLeakProfile,
 		MetricsProfile,
 		executionTrace,
 	}
@@ -384,16 +452,35 @@ func (p *profiler) send() {
 		case <-p.exit:
 			return
 		case bat := <-p.out:
-			if err := p.outputDir(bat); err != nil {
-				log.Error("Failed to output profile to dir: %v", err)
-			}
-			if err := p.uploadFunc(bat); err != nil {
+			if err := p.export(bat); err != nil {
 				log.Error("Failed to upload profile: %v", err)
 			}
 		}
 	}
 }
 
+// export passes bat to the output directory, uploads it unless uploading is
+// disabled, and then passes it to the exporters, so that slow exporters don't
+// delay the upload. It returns the upload error, if any.
+func (p *profiler) export(bat batch) error {
+	if err := p.outputDir(bat); err != nil {
+		log.Error("Failed to output profile to dir: %v", err)
+	}
+	var err error
+	if p.cfg.upload {
+		err = p.uploadFunc(bat)
+	}
+	if len(p.cfg.exporters) > 0 {
+		b := p.exportedBatch(bat)
+		for _, e := range p.cfg.exporters {
+			if err := e.Export(b); err != nil {
+				log.Error("Failed to export profile: %v", err)
+			}
+		}
+	}
+	return err
+}
+
 func (p *profiler) outputDir(bat batch) error {
 	if p.cfg.outputDir == "" {
 		return nil
@@ -428,6 +515,9 @@ func (p *profiler) interruptibleSleep(d time.Duration) {
 // stop stops the profiler.
 func (p *profiler) stop() {
 	p.stopOnce.Do(func() {
+		if p.cfg.traceConfig.LatencyThreshold > 0 {
+			traceprof.GlobalLatencyTrigger().Set(0, nil, nil)
+		}
 		close(p.exit)
 	})
 	p.wg.Wait()
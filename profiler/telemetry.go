@@ -41,10 +41,12 @@ func startTelemetry(c *config) {
 			{Name: "mutex_profile_enabled", Value: profileEnabled(MutexProfile)},
 			{Name: "goroutine_profile_enabled", Value: profileEnabled(GoroutineProfile)},
 			{Name: "goroutine_wait_profile_enabled", Value: profileEnabled(expGoroutineWaitProfile)},
+			{Name: "goroutine_leak_profile_enabled", Value: profileEnabled(expGoroutineLeakProfile)},
 			{Name: "upload_timeout", Value: c.uploadTimeout.String()},
 			{Name: "execution_trace_enabled", Value: c.traceEnabled},
 			{Name: "execution_trace_period", Value: c.traceConfig.Period.String()},
 			{Name: "execution_trace_size_limit", Value: c.traceConfig.Limit},
+			{Name: "execution_trace_latency_threshold", Value: c.traceConfig.LatencyThreshold.String()},
 			{Name: "endpoint_count_enabled", Value: c.endpointCountEnabled},
 		}...))
 }
@@ -68,16 +68,20 @@ type retriableError struct{ err error }
 // Error implements error.
 func (e retriableError) Error() string { return e.err.Error() }
 
-// doRequest makes an HTTP POST request to the Datadog Profiling API with the
-// given profile.
-func (p *profiler) doRequest(bat batch) error {
-	tags := append(p.cfg.tags.Slice(),
-		fmt.Sprintf("service:%s", p.cfg.service),
+// batchTags returns the tags of bat, as uploaded in the event.json metadata.
+func (p *profiler) batchTags(bat batch) []string {
+	tags := append(p.cfg.tags.Slice(), fmt.Sprintf("service:%s", p.cfg.service))
+	if bat.trigger != "" {
+		// Batches collected out of band of the periodic collection are not
+		// part of the profile_seq sequence.
+		tags = append(tags, fmt.Sprintf("trigger:%s", bat.trigger))
+		tags = append(tags, bat.tags...)
+	} else {
 		// The profile_seq tag can be used to identify the first profile
 		// uploaded by a given runtime-id, identify missing profiles, etc.. See
 		// PROF-5612 (internal) for more details.
-		fmt.Sprintf("profile_seq:%d", bat.seq),
-	)
+		tags = append(tags, fmt.Sprintf("profile_seq:%d", bat.seq))
+	}
 	// If the user did not configure an "env" in the client, we should omit
 	// the tag so that the agent has a chance to supply a default tag.
 	// Otherwise, the tag supplied by the client will have priority.
@@ -91,7 +95,16 @@ func (p *profiler) doRequest(bat batch) error {
 			tags = append(tags, "go_execution_traced:yes")
 		}
 	}
-	contentType, body, err := encode(bat, tags)
+	if bat.host != "" {
+		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
+	}
+	return append(tags, "runtime:go")
+}
+
+// doRequest makes an HTTP POST request to the Datadog Profiling API with the
+// given profile.
+func (p *profiler) doRequest(bat batch) error {
+	contentType, body, err := encode(p.exportedBatch(bat))
 	if err != nil {
 		return err
 	}
@@ -148,33 +161,35 @@ type uploadEvent struct {
 	EndpointCounts map[string]uint64 `json:"endpoint_counts,omitempty"`
 }
 
-// encode encodes the profile as a multipart mime request.
-func encode(bat batch, tags []string) (contentType string, body io.Reader, err error) {
-	var buf bytes.Buffer
-
-	mw := multipart.NewWriter(&buf)
-
-	if bat.host != "" {
-		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
-	}
-	tags = append(tags, "runtime:go")
-
+// newUploadEvent returns the event.json metadata of bat.
+func newUploadEvent(bat Batch) *uploadEvent {
 	event := &uploadEvent{
 		Version:        "4",
 		Family:         "go",
-		Start:          bat.start.Format(time.RFC3339Nano),
-		End:            bat.end.Format(time.RFC3339Nano),
-		Tags:           strings.Join(tags, ","),
-		EndpointCounts: bat.endpointCounts,
+		Start:          bat.Start.Format(time.RFC3339Nano),
+		End:            bat.End.Format(time.RFC3339Nano),
+		Tags:           strings.Join(bat.Tags, ","),
+		EndpointCounts: bat.EndpointCounts,
 	}
+	for _, p := range bat.Profiles {
+		event.Attachments = append(event.Attachments, p.Name)
+	}
+	return event
+}
+
+// encode encodes the profile as a multipart mime request.
+func encode(bat Batch) (contentType string, body io.Reader, err error) {
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
 
-	for _, p := range bat.profiles {
-		event.Attachments = append(event.Attachments, p.name)
-		f, err := mw.CreateFormFile(p.name, p.name)
+	event := newUploadEvent(bat)
+	for _, p := range bat.Profiles {
+		f, err := mw.CreateFormFile(p.Name, p.Name)
 		if err != nil {
 			return "", nil, err
 		}
-		if _, err := f.Write(p.data); err != nil {
+		if _, err := f.Write(p.Data); err != nil {
 			return "", nil, err
 		}
 	}